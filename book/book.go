@@ -0,0 +1,113 @@
+// Package book	build per symbol limit order book from ITCH 5.0 messages
+//				delivered in order by MoldUDP.Client
+package book
+
+import (
+	"sort"
+
+	"github.com/kjx98/go-mold/itch"
+)
+
+// side of order and price level
+const (
+	Buy  = 'B'
+	Sell = 'S'
+)
+
+// Level	aggregated price level
+//	Shares	total shares of orders, 0 for level removed
+//	Orders	number of orders
+type Level struct {
+	Price  uint32
+	Shares uint64
+	Orders int
+}
+
+// Book	limit order book of one stock, bids in descending and asks in
+//		ascending price
+type Book struct {
+	Stock       itch.Stock
+	StockLocate uint16
+	bids        []*Level
+	asks        []*Level
+}
+
+func newBook(stock itch.Stock, locate uint16) *Book {
+	return &Book{Stock: stock, StockLocate: locate}
+}
+
+func (bk *Book) side(side byte) *[]*Level {
+	if side == Buy {
+		return &bk.bids
+	}
+	return &bk.asks
+}
+
+// search	index of price level or where to insert
+func (bk *Book) search(side byte, price uint32) (int, bool) {
+	levels := *bk.side(side)
+	var i int
+	if side == Buy {
+		i = sort.Search(len(levels), func(i int) bool {
+			return levels[i].Price <= price
+		})
+	} else {
+		i = sort.Search(len(levels), func(i int) bool {
+			return levels[i].Price >= price
+		})
+	}
+	return i, i < len(levels) && levels[i].Price == price
+}
+
+// update	add shares/orders to price level, remove level if empty
+func (bk *Book) update(side byte, price uint32, shares int64, orders int) Level {
+	levels := bk.side(side)
+	i, ok := bk.search(side, price)
+	if !ok {
+		lvl := &Level{Price: price}
+		*levels = append(*levels, nil)
+		copy((*levels)[i+1:], (*levels)[i:])
+		(*levels)[i] = lvl
+	}
+	lvl := (*levels)[i]
+	lvl.Shares = uint64(int64(lvl.Shares) + shares)
+	lvl.Orders += orders
+	ret := *lvl
+	if lvl.Orders <= 0 {
+		ret.Shares = 0
+		ret.Orders = 0
+		*levels = append((*levels)[:i], (*levels)[i+1:]...)
+	}
+	return ret
+}
+
+// BestBid	highest bid level
+func (bk *Book) BestBid() (Level, bool) {
+	if len(bk.bids) == 0 {
+		return Level{}, false
+	}
+	return *bk.bids[0], true
+}
+
+// BestAsk	lowest ask level
+func (bk *Book) BestAsk() (Level, bool) {
+	if len(bk.asks) == 0 {
+		return Level{}, false
+	}
+	return *bk.asks[0], true
+}
+
+// Depth	snapshot of top n price levels each side, n <= 0 for all
+func (bk *Book) Depth(n int) (bids, asks []Level) {
+	snapshot := func(levels []*Level) []Level {
+		if n > 0 && len(levels) > n {
+			levels = levels[:n]
+		}
+		ret := make([]Level, len(levels))
+		for i, lvl := range levels {
+			ret[i] = *lvl
+		}
+		return ret
+	}
+	return snapshot(bk.bids), snapshot(bk.asks)
+}
@@ -0,0 +1,119 @@
+package book
+
+import (
+	"reflect"
+	"testing"
+
+	MoldUDP "github.com/kjx98/go-mold"
+	"github.com/kjx98/go-mold/itch"
+)
+
+var aapl = itch.NewStock("AAPL")
+
+func msg(m itch.Encoder) MoldUDP.Message {
+	return MoldUDP.Message{Data: itch.Bytes(m)}
+}
+
+func add(ref uint64, side byte, shares, price uint32) MoldUDP.Message {
+	return msg(&itch.AddOrder{OrderRef: ref, BuySell: side, Shares: shares,
+		Stock: aapl, Price: price})
+}
+
+func TestBuilder(t *testing.T) {
+	feed := []MoldUDP.Message{
+		msg(&itch.SystemEvent{EventCode: 'O'}),
+		add(1, Buy, 100, 1750000),
+		add(2, Buy, 200, 1750000),
+		add(3, Buy, 300, 1749900),
+		add(4, Sell, 100, 1750100),
+		msg(&itch.AddOrderMPID{AddOrder: itch.AddOrder{OrderRef: 5, BuySell: Sell,
+			Shares: 400, Stock: aapl, Price: 1750200}}),
+		msg(&itch.OrderExecuted{OrderRef: 1, ExecutedShares: 40}),
+		msg(&itch.OrderCancel{OrderRef: 2, CancelledShares: 50}),
+		msg(&itch.OrderExecutedWithPrice{OrderExecuted: itch.OrderExecuted{
+			OrderRef: 4, ExecutedShares: 100}, ExecutionPrice: 1750000}),
+		msg(&itch.OrderReplace{OriginalOrderRef: 3, NewOrderRef: 6, Shares: 250,
+			Price: 1749800}),
+		msg(&itch.Trade{BuySell: Buy, Shares: 10, Stock: aapl, Price: 1750000}),
+		add(7, Sell, 100, 1750300),
+		msg(&itch.OrderDelete{OrderRef: 7}),
+	}
+	bd := NewBuilder()
+	changes := 0
+	bd.OnChange(func(bk *Book, side byte, lvl Level) {
+		if bk.Stock != aapl {
+			t.Errorf("change on book %s", bk.Stock)
+		}
+		changes++
+	})
+	for i, m := range feed {
+		if err := bd.Apply(uint64(i+1), m); err != nil {
+			t.Errorf("Apply(%d) error %v", i+1, err)
+		}
+	}
+	if changes != 12 {
+		t.Errorf("got %d level changes, want 12", changes)
+	}
+	bk := bd.Book("AAPL")
+	if bk == nil {
+		t.Fatal("no book for AAPL")
+	}
+	if lvl, ok := bk.BestBid(); !ok || lvl != (Level{1750000, 210, 2}) {
+		t.Errorf("BestBid() = %+v, %v", lvl, ok)
+	}
+	if lvl, ok := bk.BestAsk(); !ok || lvl != (Level{1750200, 400, 1}) {
+		t.Errorf("BestAsk() = %+v, %v", lvl, ok)
+	}
+	bids, asks := bk.Depth(0)
+	if want := []Level{{1750000, 210, 2}, {1749800, 250, 1}}; !reflect.DeepEqual(bids, want) {
+		t.Errorf("Depth() bids = %+v, want %+v", bids, want)
+	}
+	if want := []Level{{1750200, 400, 1}}; !reflect.DeepEqual(asks, want) {
+		t.Errorf("Depth() asks = %+v, want %+v", asks, want)
+	}
+	if bd.Orders() != 4 {
+		t.Errorf("Orders() = %d, want 4", bd.Orders())
+	}
+	// replayed message ignored
+	if err := bd.Apply(2, add(1, Buy, 100, 1750000)); err != nil {
+		t.Error("Apply() replayed", err)
+	}
+	if err := bd.Apply(100, msg(&itch.OrderDelete{OrderRef: 1})); err != ErrSeqGap {
+		t.Errorf("Apply() error = %v, want %v", err, ErrSeqGap)
+	}
+	// books cleared, stale till resync
+	if !bd.Stale() || bd.Books() != 0 || bd.Orders() != 0 || bd.Book("AAPL") != nil {
+		t.Errorf("after gap stale %v, %d books, %d orders", bd.Stale(), bd.Books(),
+			bd.Orders())
+	}
+	if err := bd.Apply(101, add(8, Buy, 100, 1750000)); err != ErrStale {
+		t.Errorf("Apply() error = %v, want %v", err, ErrStale)
+	}
+	// replay of session from start
+	bd.Resync(1)
+	for i, m := range feed {
+		if err := bd.Apply(uint64(i+1), m); err != nil {
+			t.Errorf("Apply(%d) after Resync error %v", i+1, err)
+		}
+	}
+	if bd.Stale() || bd.Orders() != 4 {
+		t.Errorf("after Resync stale %v, %d orders", bd.Stale(), bd.Orders())
+	}
+	bk = bd.Book("AAPL")
+	if lvl, ok := bk.BestBid(); !ok || lvl != (Level{1750000, 210, 2}) {
+		t.Errorf("BestBid() after Resync = %+v, %v", lvl, ok)
+	}
+	next := uint64(len(feed)) + 1
+	if err := bd.Apply(next, msg(&itch.OrderDelete{OrderRef: 99})); err != ErrUnknownOrder {
+		t.Errorf("Apply() error = %v, want %v", err, ErrUnknownOrder)
+	}
+}
+
+func BenchmarkApply(b *testing.B) {
+	bd := NewBuilder()
+	msgs := []MoldUDP.Message{add(1, Buy, 100, 1750000),
+		msg(&itch.OrderDelete{OrderRef: 1})}
+	for i := 0; i < b.N; i++ {
+		bd.Apply(uint64(i+1), msgs[i&1])
+	}
+}
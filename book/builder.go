@@ -0,0 +1,208 @@
+package book
+
+import (
+	"errors"
+
+	MoldUDP "github.com/kjx98/go-mold"
+	"github.com/kjx98/go-mold/itch"
+)
+
+var (
+	ErrSeqGap       = errors.New("Message sequence gap")
+	ErrUnknownOrder = errors.New("Unknown order reference number")
+	ErrDupOrder     = errors.New("Duplicate order reference number")
+	ErrStale        = errors.New("Order books stale after gap, resync required")
+)
+
+type order struct {
+	book   *Book
+	side   byte
+	price  uint32
+	shares uint32
+}
+
+// ChangeHandler	callback for price level changed, lvl.Shares 0 for
+//					level removed
+type ChangeHandler func(bk *Book, side byte, lvl Level)
+
+// Builder	maintain order books from sequenced ITCH messages, orders keyed
+//			by order reference number, not safe for concurrent use
+//			books cleared and Builder stale on sequence gap till Resync
+type Builder struct {
+	decoder  *itch.Decoder
+	books    map[itch.Stock]*Book
+	orders   map[uint64]*order
+	onChange ChangeHandler
+	nextSeq  uint64
+	nError   int
+	bStale   bool
+}
+
+func NewBuilder() *Builder {
+	return &Builder{decoder: itch.NewDecoder(),
+		books:  map[itch.Stock]*Book{},
+		orders: map[uint64]*order{},
+	}
+}
+
+// OnChange	callback fx for every price level changed
+func (b *Builder) OnChange(fx ChangeHandler) {
+	b.onChange = fx
+}
+
+// Book		order book of stock, nil if no order seen
+func (b *Builder) Book(stock string) *Book {
+	return b.books[itch.NewStock(stock)]
+}
+
+// Books	number of order books
+func (b *Builder) Books() int {
+	return len(b.books)
+}
+
+// Orders	number of orders on books
+func (b *Builder) Orders() int {
+	return len(b.orders)
+}
+
+// Errors	number of messages failed to apply via Handle
+func (b *Builder) Errors() int {
+	return b.nError
+}
+
+// Stale	books cleared for sequence gap, messages ignored till Resync
+func (b *Builder) Stale() bool {
+	return b.bStale
+}
+
+// Resync	clear books and orders, apply messages from nextSeq again,
+//			e.g. 1 for replay of session from RecordFile or SoupClient,
+//			0 for any sequence number first applied
+func (b *Builder) Resync(nextSeq uint64) {
+	b.books = map[itch.Stock]*Book{}
+	b.orders = map[uint64]*order{}
+	b.nextSeq = nextSeq
+	b.bStale = false
+}
+
+// Handle	MoldUDP.MessageHandler, could be used with Client.Subscribe
+func (b *Builder) Handle(seqNo uint64, msg MoldUDP.Message) {
+	if err := b.Apply(seqNo, msg); err != nil {
+		b.nError++
+	}
+}
+
+func (b *Builder) getBook(stock itch.Stock, locate uint16) *Book {
+	bk := b.books[stock]
+	if bk == nil {
+		bk = newBook(stock, locate)
+		b.books[stock] = bk
+	}
+	return bk
+}
+
+func (b *Builder) update(bk *Book, side byte, price uint32, shares int64, orders int) {
+	lvl := bk.update(side, price, shares, orders)
+	if b.onChange != nil {
+		b.onChange(bk, side, lvl)
+	}
+}
+
+func (b *Builder) addOrder(m *itch.AddOrder) error {
+	if _, ok := b.orders[m.OrderRef]; ok {
+		return ErrDupOrder
+	}
+	bk := b.getBook(m.Stock, m.StockLocate)
+	b.orders[m.OrderRef] = &order{book: bk, side: m.BuySell, price: m.Price,
+		shares: m.Shares}
+	b.update(bk, m.BuySell, m.Price, int64(m.Shares), 1)
+	return nil
+}
+
+func (b *Builder) delete(ref uint64) (*order, error) {
+	od, ok := b.orders[ref]
+	if !ok {
+		return nil, ErrUnknownOrder
+	}
+	delete(b.orders, ref)
+	b.update(od.book, od.side, od.price, -int64(od.shares), -1)
+	return od, nil
+}
+
+// reduce	remove shares from order, delete order if no shares left
+func (b *Builder) reduce(ref uint64, shares uint32) error {
+	od, ok := b.orders[ref]
+	if !ok {
+		return ErrUnknownOrder
+	}
+	if shares >= od.shares {
+		_, err := b.delete(ref)
+		return err
+	}
+	od.shares -= shares
+	b.update(od.book, od.side, od.price, -int64(shares), 0)
+	return nil
+}
+
+// Apply	apply message seqNo to books, messages already applied ignored
+//			books cleared without OnChange on gap, ErrSeqGap returned
+//			once then ErrStale till Resync
+func (b *Builder) Apply(seqNo uint64, msg MoldUDP.Message) error {
+	if b.bStale {
+		return ErrStale
+	}
+	if b.nextSeq != 0 {
+		if seqNo < b.nextSeq {
+			return nil
+		}
+		if seqNo > b.nextSeq {
+			// orders of messages missed unknown, books not trusted
+			b.Resync(0)
+			b.bStale = true
+			return ErrSeqGap
+		}
+	}
+	b.nextSeq = seqNo + 1
+	if len(msg.Data) == 0 {
+		return nil
+	}
+	switch msg.Data[0] {
+	case 'R', 'A', 'F', 'E', 'C', 'X', 'D', 'U':
+	default:
+		// no effect on order books
+		return nil
+	}
+	mm, err := b.decoder.Decode(msg.Data)
+	if err != nil {
+		return err
+	}
+	switch m := mm.(type) {
+	case *itch.StockDirectory:
+		b.getBook(m.Stock, m.StockLocate)
+	case *itch.AddOrder:
+		return b.addOrder(m)
+	case *itch.AddOrderMPID:
+		return b.addOrder(&m.AddOrder)
+	case *itch.OrderExecuted:
+		return b.reduce(m.OrderRef, m.ExecutedShares)
+	case *itch.OrderExecutedWithPrice:
+		return b.reduce(m.OrderRef, m.ExecutedShares)
+	case *itch.OrderCancel:
+		return b.reduce(m.OrderRef, m.CancelledShares)
+	case *itch.OrderDelete:
+		_, err := b.delete(m.OrderRef)
+		return err
+	case *itch.OrderReplace:
+		if _, ok := b.orders[m.NewOrderRef]; ok {
+			return ErrDupOrder
+		}
+		od, err := b.delete(m.OriginalOrderRef)
+		if err != nil {
+			return err
+		}
+		b.orders[m.NewOrderRef] = &order{book: od.book, side: od.side,
+			price: m.Price, shares: m.Shares}
+		b.update(od.book, od.side, m.Price, int64(m.Shares), 1)
+	}
+	return nil
+}
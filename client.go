@@ -1,9 +1,10 @@
 package MoldUDP
 
 import (
+	"context"
 	"errors"
 	"net"
-	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,79 +20,203 @@ const (
 )
 
 // Client struct for MoldUDP client
-//	Running		bool
 //	LastRecv	int64	last time recv UDP
 type Client struct {
-	dstIP            net.IP // Multicast dst IP
-	dstPort          int    // Multicast dst Port
-	connReq          *net.UDPConn
-	conn             McastConn
-	reqSrv           []net.UDPAddr
-	Running          bool
-	endSession       bool
-	bDone            bool
-	LastRecv         int64
-	seqNo            uint64
-	seqMax           uint64
-	seqEnd           uint64
-	reqLast          time.Time
-	nRecvs, nRequest int
-	nError, nMissed  int
-	nInvalid         int
-	nRepeats         int
-	lastLogTime      int64
-	lastSeq          uint64
-	lastN            int32
-	robinN           int
-	session          string
-	nMerges          int
-	readLock         sync.RWMutex
-	ch               chan msgBuf
-	ready            []Message
-	cache            msgCache
+	dstIP       net.IP // Multicast dst IP
+	dstPort     int    // Multicast dst Port
+	connReq     *net.UDPConn
+	lines       []*feedLine
+	reqSrv      []net.UDPAddr
+	ctx         context.Context
+	cancel      context.CancelFunc
+	closeOnce   sync.Once
+	closeErr    error
+	endSession  bool
+	bDone       bool
+	LastRecv    int64
+	seqNo       uint64
+	seqMax      uint64
+	seqEnd      uint64
+	reqLast     time.Time
+	lastLogTime int64
+	lastSeq     uint64
+	lastN       int32
+	robinN      int
+	session     string
+	recvLock    sync.Mutex
+	readLock    sync.RWMutex
+	readCond    *sync.Cond
+	ch          chan msgBuf
+	ready       []Message
+	readySeq    uint64
+	cache       msgCache
+	cacheTimes  []cacheTime
+	recorder    atomic.Value
+	arbRing     []arbEntry
+	mgr         *Manager
+	name        string
+	rollover    int
+	prevSession string
+	curSession  string
+	oldSession  string
+	segs        []readySeg
+	liveOnce    sync.Once
+	liveC       chan struct{}
+	liveSeq     uint64
+	recovering  int32
+	recovery    MessageStore
+	bulkStop    uint64
+	sessHint    atomic.Value
+	held        heldRing
+	spare       []Message
+	outBuf      []Message
+	outEnd      uint64
+	bRelease    bool
+	epoch       int
+	readEpoch   int
+	seqTop      uint64
+	reqAt       time.Time
+	reqEnd      uint64
+	clientCounters
 
 	startOnFirst bool
+	firstOnNew   bool
+}
+
+// readySeg	messages or event queued before session rollover
+type readySeg struct {
+	msgs  []Message
+	seqNo uint64
+	evt   *SessionChanged
+	epoch int
 }
 
 type msgBuf struct {
-	seqNo   uint64
-	msgCnt  uint16
-	dataBuf []byte
+	session  string
+	seqNo    uint64
+	recvTime int64
+	msgCnt   uint16
+	dataBuf  []byte
+	msgs     []Message
+	pb       *pktBuf
 }
 
 // Option	options for Client connection
 //	Srvs	request servers, host[:port]
 //	IfName	if nor blank, if interface for Multicast
 //	NextSeq	next sequence number for listen packet, 1 based
+//	Source	if not blank, source address of publisher for SSM join
+//	Rollover	policy for packets of new session, SessionReject default
+//	LateJoin	recover messages from NextSeq(1 if 0) missed before join,
+//				live packets cached till recovered messages stitched
+//	Recovery	if not nil, store for LateJoin instead of request servers
+//				and for gap beyond nakWindow, e.g. RecordFile of session
+//				or SoupClient
+//	Recorder	if not nil, record messages delivered in order
 type Option struct {
-	Srvs    []string
-	IfName  string
-	NextSeq uint64
+	Srvs     []string
+	IfName   string
+	NextSeq  uint64
+	Source   string
+	Rollover int
+	LateJoin bool
+	Recovery MessageStore
+	Recorder *Recorder
 }
 
+// policies for session rollover
+//	SessionReject	packets of session other than first one rejected
+//	SessionFollow	follow new session, sequence number restart from 1,
+//					End-of-Session not stop client but wait for new one
+//	SessionNotify	as SessionFollow, Read return SessionChanged once
+const (
+	SessionReject = iota
+	SessionFollow
+	SessionNotify
+)
+
+// Close	stop client and close connections
 func (c *Client) Close() error {
-	if c.conn == nil {
-		return errClosed
+	c.cancel()
+	c.closeOnce.Do(c.closeConn)
+	return c.closeErr
+}
+
+func (c *Client) closeConn() {
+	for _, line := range c.lines {
+		if err := line.conn.Close(); err != nil && c.closeErr == nil {
+			c.closeErr = err
+		}
 	}
-	err := c.conn.Close()
-	c.conn = nil
 	if c.connReq != nil {
 		c.connReq.Close()
-		c.connReq = nil
 	}
-	return err
+}
+
+// Stop		stop receiving, Read return after all ready messages consumed
+func (c *Client) Stop() {
+	c.cancel()
+}
+
+// Running	client not stopped and session not finished
+func (c *Client) Running() bool {
+	return c.ctx.Err() == nil
+}
+
+// Done		closed when client stopped or session finished
+func (c *Client) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+var (
+	// ErrEndOfSession	all messages of session read
+	ErrEndOfSession = errors.New("End of session")
+)
+
+// SessionChanged	returned by Read once for session rollover with
+//					SessionNotify, Read again for messages of New
+//	SeqNo	next sequence number of Old not received
+//	Ended	Old finished by End-of-Session, all its messages received
+type SessionChanged struct {
+	Old   string
+	New   string
+	SeqNo uint64
+	Ended bool
+}
+
+func (e *SessionChanged) Error() string {
+	return "Session changed from " + e.Old + " to " + e.New
 }
 
 var (
 	errDecodeHead    = errors.New("DecodeHead error")
 	errInvMessageCnt = errors.New("Invalid MessageCnt")
 	errSession       = errors.New("Session dismatch")
+	errDupPacket     = errors.New("Packet supplied by other line")
+	errNoFeed        = errors.New("No feed to listen")
 )
 
-func (c *Client) storeCache(buf []Message, seqNo uint64) uint64 {
+// cacheTime	receive time of packet with messages [seqNo, end) cached
+type cacheTime struct {
+	seqNo    uint64
+	end      uint64
+	recvTime int64
+}
+
+func (c *Client) storeCache(buf []Message, seqNo uint64, recvTime int64) uint64 {
 	bLen := len(buf)
 	bMerge := false
 	ret := seqNo
+	// ordered by seqNo, packet cached later after same seqNo
+	ct := c.cacheTimes
+	i := sort.Search(len(ct), func(i int) bool { return ct[i].seqNo > seqNo })
+	ct = append(ct, cacheTime{})
+	copy(ct[i+1:], ct[i:])
+	ct[i] = cacheTime{seqNo: seqNo, end: seqNo + uint64(bLen), recvTime: recvTime}
+	c.cacheTimes = ct
+	c.cache.Advance(c.seqNo)
+	// live messages kept while recovering, recovery stops at first of them
+	c.cache.bOpen = atomic.LoadInt32(&c.recovering) != 0
 	for i := 0; i < bLen; i++ {
 		if c.cache.Upset(seqNo, &buf[i]) {
 			bMerge = true
@@ -99,10 +224,11 @@ func (c *Client) storeCache(buf []Message, seqNo uint64) uint64 {
 		seqNo++
 	}
 	if bMerge {
-		c.nMerges++
+		atomic.AddInt64(&c.nMerges, 1)
 		return 0
 	}
-	if !c.cache.IsNil(seqNo - 1) {
+	// gap before following cached message already requested
+	if !c.cache.IsNil(seqNo) {
 		return 0
 	}
 	return ret
@@ -112,53 +238,186 @@ func (c *Client) popCache(seqNo uint64) []Message {
 	return c.cache.Merge(seqNo)
 }
 
-func (c *Client) gotBuff(buff []byte, n int) error {
-	c.nRecvs++
+// cachedRun	messages from seqNo, up to n, of one packet cached and its
+//				receive time, recvTime if not found
+func (c *Client) cachedRun(seqNo uint64, n int, recvTime int64) (int, int64) {
+	ct := c.cacheTimes
+	i := sort.Search(len(ct), func(i int) bool { return ct[i].seqNo > seqNo })
+	end := seqNo + uint64(n)
+	if i < len(ct) && ct[i].seqNo < end {
+		end = ct[i].seqNo
+	}
+	for i--; i >= 0; i-- {
+		if ct[i].end > seqNo {
+			if ct[i].end < end {
+				end = ct[i].end
+			}
+			return int(end - seqNo), ct[i].recvTime
+		}
+	}
+	return int(end - seqNo), recvTime
+}
+
+// dropTimes	receive times of packets delivered before seqNo dropped
+func (c *Client) dropTimes(seqNo uint64) {
+	ct := c.cacheTimes
+	i := 0
+	for i < len(ct) && ct[i].end <= seqNo {
+		i++
+	}
+	c.cacheTimes = ct[:copy(ct, ct[i:])]
+}
+
+// record	write messages delivered from seqNo to rec, first nLive of
+//			packet received at recvTime, others merged from cache with
+//			receive time of their own packets
+func (c *Client) record(rec *Recorder, seqNo uint64, msgs []Message, nLive int, recvTime int64) {
+	for len(msgs) > 0 {
+		n, tt := nLive, recvTime
+		if n == 0 {
+			n, tt = c.cachedRun(seqNo, len(msgs), recvTime)
+		}
+		nLive = 0
+		if err := rec.Write(c.curSession, seqNo, msgs[:n], tt); err != nil {
+			log.Error("Recorder Write", err)
+			return
+		}
+		seqNo += uint64(n)
+		msgs = msgs[n:]
+	}
+}
+
+// gotBuff	called by multicast and retransmission recv loops,
+//			line is nil for retransmission
+func (c *Client) gotBuff(buff []byte, n int, line *feedLine) error {
 	var head Header
-	if err := DecodeHead(buff[:n], &head); err != nil {
-		c.nError++
+	known, _ := c.sessHint.Load().(string)
+	if err := decodeHead(buff[:n], &head, known); err != nil {
+		atomic.AddInt64(&c.nRecvs, 1)
+		c.countErr(errKindDecode)
 		return errDecodeHead
 	}
+	msgBB, err := c.parseBuff(buff, n, &head, line, line != nil)
+	if err == errDupPacket {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return c.queue(msgBB)
+}
+
+// queue	msgBB to sequencing loop of client or its Manager
+func (c *Client) queue(msgBB msgBuf) error {
+	if c.mgr != nil {
+		return c.mgr.queue(c, msgBB)
+	}
+	select {
+	case c.ch <- msgBB:
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+	return nil
+}
+
+// parseBuff	msgBuf of packet with head decoded, live for multicast packet
+func (c *Client) parseBuff(buff []byte, n int, head *Header, line *feedLine, live bool) (msgBuf, error) {
+	atomic.AddInt64(&c.nRecvs, 1)
+	if !live {
+		atomic.AddInt64(&c.nRetrans, 1)
+	}
+	c.recvLock.Lock()
 	nMsg := head.MessageCnt
 	if nMsg != 0xffff && nMsg >= maxMessages {
-		c.nError++
-		return errInvMessageCnt
+		c.countErr(errKindMessageCnt)
+		c.recvLock.Unlock()
+		return msgBuf{}, errInvMessageCnt
 	}
-	c.LastRecv = time.Now().Unix()
+	tt := time.Now()
+	c.LastRecv = tt.Unix()
 	if c.session == "" {
 		c.session = head.Session
+		c.sessHint.Store(c.session)
 	} else if c.session != head.Session {
-		c.nError++
-		return errSession
+		if c.rollover == SessionReject || head.Session == c.prevSession {
+			c.countErr(errKindSession)
+			c.recvLock.Unlock()
+			return msgBuf{}, errSession
+		}
+		log.Infof("Session rollover %s -> %s", c.session, head.Session)
+		c.prevSession, c.session = c.session, head.Session
+		c.sessHint.Store(c.session)
+		atomic.AddInt64(&c.nRollover, 1)
+		// sequence of lines restart
+		c.arbRing = nil
+		for _, line := range c.lines {
+			line.seqNext = 0
+		}
+	}
+	if line != nil && c.arbitrate(line, head, tt) {
+		// copy of other line already queued, no buffer for it
+		atomic.AddInt64(&c.nRepeats, 1)
+		c.recvLock.Unlock()
+		return msgBuf{}, errDupPacket
+	}
+	if live {
+		c.liveOnce.Do(func() {
+			c.liveSeq = head.SeqNo
+			if c.liveC != nil {
+				close(c.liveC)
+			}
+		})
 	}
+	c.recvLock.Unlock()
 
-	var newBuf []byte
+	msgBB := msgBuf{session: head.Session, seqNo: head.SeqNo,
+		recvTime: tt.UnixNano(), msgCnt: nMsg}
 	if nMsg != 0xffff && nMsg != 0 {
 		if n == headSize {
-			return errMessageCnt
+			c.countErr(errKindMessageCnt)
+			return msgBuf{}, errMessageCnt
 		}
-		newBuf = make([]byte, n-headSize)
-		copy(newBuf, buff[headSize:n])
+		// payload in pooled buffer, released by worker or Release
+		msgBB.pb = getPktBuf(buff[headSize:n])
+		msgBB.dataBuf = msgBB.pb.data
 	} else {
-		// newBuf is nil for endSession or Heartbeat
+		// no payload for endSession or Heartbeat
 	}
-	msgBB := msgBuf{seqNo: head.SeqNo, msgCnt: nMsg, dataBuf: newBuf}
-	c.ch <- msgBB
-	return nil
+	return msgBB, nil
 }
 
 func (c *Client) doMsgBuf(msgBB *msgBuf) ([]byte, error) {
-	var res []Message
-	if len(msgBB.dataBuf) > 0 {
+	// recovered messages of late join without session
+	if msgBB.session != "" && msgBB.session != c.curSession {
+		if c.curSession == "" {
+			c.curSession = msgBB.session
+		} else if msgBB.session == c.oldSession {
+			// queued by other line before rollover
+			atomic.AddInt64(&c.nRepeats, 1)
+			return nil, nil
+		} else {
+			c.resetSession(msgBB.session)
+		}
+	}
+	res := msgBB.msgs
+	if len(res) > 0 {
+		atomic.AddInt64(&c.nRecovered, int64(len(res)))
+	}
+	if msgBB.pb != nil {
+		if ret, err := msgBB.pb.unmarshal(int(msgBB.msgCnt)); err != nil {
+			c.countErr(errKindUnmarshal)
+			return nil, err
+		} else {
+			res = ret
+		}
+	} else if len(msgBB.dataBuf) > 0 {
 		if ret, err := Unmarshal(msgBB.dataBuf, int(msgBB.msgCnt)); err != nil {
-			c.nError++
+			c.countErr(errKindUnmarshal)
 			//log.Error("Unmarshal msgBB", err)
 			return nil, err
 		} else {
 			res = ret
 		}
 	}
-	log.Infof("Do %d messages", len(res))
 	if msgBB.msgCnt == 0xffff {
 		if !c.endSession {
 			log.Info("Got endSession packet")
@@ -166,105 +425,168 @@ func (c *Client) doMsgBuf(msgBB *msgBuf) ([]byte, error) {
 			c.seqEnd = msgBB.seqNo
 		}
 		if c.seqNo >= c.seqMax {
+			c.readLock.RLock()
+			bReady := c.ready != nil
+			c.readLock.RUnlock()
 			if c.seqEnd > c.seqMax && c.seqEnd > c.seqNo {
-				if c.ready == nil {
+				if !bReady {
 					c.seqMax = c.seqEnd
 					log.Info("read all cache, update seqMax to EOS", c.seqMax)
 				}
 			} else {
 				log.Info("Got all messages seqNo:", c.seqNo, "to stop running")
-				//c.Running = false
-				c.bDone = true
-				if c.ready == nil {
-					c.Running = false
-				}
+				c.setDone()
 			}
 		}
 	}
 	seqNo := msgBB.seqNo
-	log.Infof("Before if c.startOnFirst msgBB: %+v, c.seqNo: %d, seqEnd: %d, seqMax: %d", msgBB, c.seqNo, c.seqEnd, c.seqMax)
 	if c.startOnFirst {
 		c.seqNo = seqNo
 		c.startOnFirst = false
 	}
-	log.Infof("Before msgCnt := msgBB.msgCnt; msgCnt != 0 && msgCnt != 0xffff %d messages", len(res))
 	if msgCnt := msgBB.msgCnt; msgCnt != 0 && msgCnt != 0xffff {
-		log.Infof("Inside msgCnt := msgBB.msgCnt; msgCnt != 0 && msgCnt != 0xffff %d messages", len(res))
+		c.trackGap(seqNo, seqNo+uint64(msgCnt))
+	} else {
+		c.trackGap(seqNo, seqNo)
+	}
+	if msgCnt := msgBB.msgCnt; msgCnt != 0 && msgCnt != 0xffff {
 		// should request for retransmit
 		if len(res) != int(msgCnt) {
-			c.nError++
+			c.countErr(errKindMessageCnt)
 			return nil, errMessageCnt
 		}
 		seqNext := seqNo + uint64(msgCnt)
-		if seqF := c.seqNo; seqNext < seqF {
+		if seqF := c.seqNo; seqNext <= seqF {
 			// already got
-			c.nRepeats++
+			atomic.AddInt64(&c.nRepeats, 1)
 			return nil, nil
 		} else if seqNo > seqF {
-			log.Infof("Inside else if seqNo > seqF  %d messages", len(res))
 			// cache or not for MessageCnt not 0, 0xffff
-			seqNo = c.storeCache(res, seqNo)
+			seqNo = c.storeCache(res, seqNo, msgBB.recvTime)
+			c.hold(msgBB)
 			if seqNo <= seqF {
-				log.Infof("Inside if seqNo <= seqF %d messages", len(res))
 				return nil, nil
 			}
 			reqBuf := c.newReq(seqNo)
-			c.nMissed++
+			atomic.AddInt64(&c.nMissed, 1)
 			return reqBuf, nil
 		}
-		log.Infof("About to leave", len(res))
 	} else {
 		// endSession
 		// or heartbeat
 		if c.seqNo < seqNo {
 			reqBuf := c.newReq(seqNo)
-			c.nMissed++
+			atomic.AddInt64(&c.nMissed, 1)
 			//log.Info("Got HB, update seqMax", seqNo)
 			return reqBuf, nil
 		}
 		return nil, nil
 	}
-	log.Infof("After msgCnt := msgBB.msgCnt; msgCnt != 0 && msgCnt != 0xffff %d messages", len(res))
 	seqNo = msgBB.seqNo
 	if c.seqNo > seqNo {
 		res = res[int(c.seqNo-seqNo):]
 	}
 	seqNo = c.seqNo + uint64(len(res))
+	nLive := len(res)
 	//c.seqNo += uint64(len(res))
 	// popCache used c.seqNo as base
 	// shall we check head cache to merge
 	if bb := c.popCache(seqNo); bb != nil {
-		res = append(res, bb...)
+		// not append to messages of pooled buffer
+		res = append(res[:len(res):len(res)], bb...)
 		seqNo += uint64(len(bb))
 	}
-	log.Infof("Before atomic.StoreUint64(&c.lastSeq, c.seqNo %d messages", len(res))
 	atomic.StoreUint64(&c.lastSeq, c.seqNo)
 	atomic.StoreInt32(&c.lastN, int32(seqNo-c.seqNo))
+	seqF := c.seqNo
 	c.seqNo = seqNo
-	log.Infof("Before c.endSession && seqNo %d messages", len(res))
+	c.trackFill()
+	atomic.AddInt64(&c.nMessages, int64(len(res)))
+	c.hold(msgBB)
+	if rec, _ := c.recorder.Load().(*Recorder); rec != nil && len(res) > 0 {
+		c.record(rec, seqF, res, nLive, msgBB.recvTime)
+	}
+	if len(c.cacheTimes) > 0 {
+		c.dropTimes(seqNo)
+	}
+	bDone := false
 	if c.endSession && seqNo >= c.seqMax {
 		if c.seqEnd > seqNo {
 			c.seqMax = c.seqEnd
 			log.Info("EOS update seqMax", c.seqEnd)
 		} else {
 			log.Info("Got all messages via retrans seqNo:", c.seqNo, " to stop running")
-			//c.Running = false
-			c.bDone = true
+			bDone = true
 		}
 	}
-	log.Infof("About to enter lock %d messages", len(res))
 	c.readLock.Lock()
 	if c.ready == nil {
-		log.Infof("Inserting %d messages", len(res))
-		c.ready = res
+		c.ready = append(c.spare[:0], res...)
+		c.spare = nil
+		c.readySeq = seqF
 	} else {
-		log.Infof("Appending %d messages", len(res))
 		c.ready = append(c.ready, res...)
 	}
+	if bDone {
+		c.bDone = true
+	}
+	c.readCond.Broadcast()
 	c.readLock.Unlock()
 	return nil, nil
 }
 
+// hold	packet buffer of msgBB held till its messages released,
+//		dropped to GC if Release never called
+func (c *Client) hold(msgBB *msgBuf) {
+	if msgBB.pb == nil {
+		return
+	}
+	c.readLock.Lock()
+	if c.bRelease {
+		c.held.push(msgBB.pb, msgBB.seqNo+uint64(msgBB.msgCnt))
+	}
+	c.readLock.Unlock()
+	msgBB.pb = nil
+}
+
+// resetSession	follow new session, messages of old session not read
+//				yet and SessionChanged queued before messages of new one
+func (c *Client) resetSession(session string) {
+	evt := &SessionChanged{Old: c.curSession, New: session, SeqNo: c.seqNo}
+	c.oldSession, c.curSession = c.curSession, session
+	c.seqNo, c.seqMax, c.seqEnd, c.seqTop = 1, 0, 0, 0
+	c.reqAt = time.Time{}
+	c.endSession = false
+	c.startOnFirst = c.firstOnNew
+	c.reqLast = time.Time{}
+	c.cache.Init()
+	c.cacheTimes = c.cacheTimes[:0]
+	c.readLock.Lock()
+	evt.Ended = c.bDone
+	c.held.reset()
+	if c.ready != nil {
+		c.segs = append(c.segs, readySeg{msgs: c.ready, seqNo: c.readySeq,
+			epoch: c.epoch})
+		c.ready = nil
+	}
+	// Release of old session not for packets of new one
+	c.epoch++
+	if c.rollover == SessionNotify {
+		c.segs = append(c.segs, readySeg{evt: evt})
+	}
+	c.bDone = false
+	c.readCond.Broadcast()
+	c.readLock.Unlock()
+}
+
+// setDone	all messages of session received
+func (c *Client) setDone() {
+	c.readLock.Lock()
+	c.bDone = true
+	c.readCond.Broadcast()
+	c.readLock.Unlock()
+}
+
 func (c *Client) newReq(seqNo uint64) []byte {
 	if c.seqNo >= seqNo {
 		return nil
@@ -273,16 +595,27 @@ func (c *Client) newReq(seqNo uint64) []byte {
 	if seqNo > c.seqMax {
 		c.seqMax = seqNo
 	}
-	if tt.Sub(c.reqLast) < reqInterval {
+	if atomic.LoadInt32(&c.recovering) != 0 {
+		// gap filled by late join recovery
+		return nil
+	}
+	if tt.Sub(c.reqLast) < reqInterval || !c.gapOnAll() {
 		return nil
 	}
 	c.reqLast = tt
 	seqF := c.seqNo
+	if c.recovery != nil && seqNo-seqF > nakWindow &&
+		seqF != atomic.LoadUint64(&c.bulkStop) {
+		// too large for retransmission request, unless store failed
+		atomic.StoreInt32(&c.recovering, 1)
+		go c.bulkRecover(seqF, seqNo)
+		return nil
+	}
 	cnt := seqNo - seqF
 	if cnt > nakWindow {
 		cnt = nakWindow
 	}
-	head := Header{Session: c.session, SeqNo: seqF}
+	head := Header{Session: c.curSession, SeqNo: seqF}
 	head.MessageCnt = uint16(cnt)
 	buff := [headSize]byte{}
 	if err := EncodeHead(buff[:], &head); err != nil {
@@ -292,28 +625,116 @@ func (c *Client) newReq(seqNo uint64) []byte {
 	return buff[:headSize]
 }
 
-// Read			Get []Message in order
+// Read			Get []Message in order, block until messages ready
 //	[]Message	messages received in order
-//	return   	nil,nil   for end of session or finished
+//	uint64		sequence number of first message
+//	return   	ErrEndOfSession for all messages of session read,
+//				context error for client stopped, *SessionChanged for
+//				session rollover with SessionNotify, blocked for next
+//				session after End-of-Session unless SessionReject
 func (c *Client) Read() ([]Message, uint64, error) {
-	for c.Running {
-		log.Infof("Reading")
-		c.readLock.Lock()
-		res := c.ready
-		c.ready = nil
-		seqNo := atomic.LoadUint64(&c.lastSeq)
-		c.readLock.Unlock()
-		if c.bDone && seqNo+uint64(len(res)) >= c.seqNo {
-			log.Info("Read all seqNo:", c.seqNo, " really stop running")
-			c.Running = false
-			//c.bDone = true
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+	for c.ready == nil && len(c.segs) == 0 {
+		if c.bDone && c.rollover == SessionReject {
+			seqNo, n := c.LastSeq()
+			log.Info("Read all seqNo:", seqNo+uint64(n), " really stop running")
+			c.cancel()
+			return nil, 0, ErrEndOfSession
+		}
+		if err := c.ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		c.readCond.Wait()
+	}
+	if len(c.segs) > 0 {
+		seg := c.segs[0]
+		c.segs = c.segs[1:]
+		if seg.evt != nil {
+			return nil, 0, seg.evt
 		}
-		if res != nil {
-			return res, seqNo, nil
+		c.readEpoch, c.outBuf = seg.epoch, nil
+		return seg.msgs, seg.seqNo, nil
+	}
+	res := c.ready
+	c.ready = nil
+	c.outBuf, c.outEnd = res, c.readySeq+uint64(len(res))
+	c.readEpoch = c.epoch
+	return res, c.readySeq, nil
+}
+
+// Release	messages before seqNo consumed, their packet buffers and
+//			slice returned by Read recycled, messages read valid till
+//			released, reclaimed by GC if Release never called
+func (c *Client) Release(seqNo uint64) {
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+	c.bRelease = true
+	if c.readEpoch != c.epoch {
+		return
+	}
+	c.held.release(seqNo)
+	if c.outBuf != nil && seqNo >= c.outEnd {
+		if cap(c.outBuf) > cap(c.spare) {
+			c.spare = c.outBuf[:0]
 		}
-		runtime.Gosched()
+		c.outBuf = nil
 	}
-	return nil, 0, nil
+}
+
+// SequencedMessage	Message with its sequence number
+type SequencedMessage struct {
+	SeqNo uint64
+	Message
+}
+
+// MessageHandler	callback for message delivered in order
+type MessageHandler func(seqNo uint64, msg Message)
+
+// Subscribe	deliver messages in order to fx, block until end of session
+//				or client stopped, Subscribe/Messages/Read share same queue
+//	return		ErrEndOfSession or context error, *SessionChanged for
+//				SessionNotify, Subscribe again for new session
+func (c *Client) Subscribe(fx MessageHandler) error {
+	for {
+		res, seqNo, err := c.Read()
+		if err != nil {
+			return err
+		}
+		for i := range res {
+			fx(seqNo, res[i])
+			seqNo++
+		}
+	}
+}
+
+// Messages	stream of messages in order, closed on end of session
+//			or client stopped
+func (c *Client) Messages() <-chan SequencedMessage {
+	ch := make(chan SequencedMessage, maxMessages)
+	go func() {
+		defer close(ch)
+		c.Subscribe(func(seqNo uint64, msg Message) {
+			select {
+			case ch <- SequencedMessage{SeqNo: seqNo, Message: msg}:
+			case <-c.ctx.Done():
+			}
+		})
+	}()
+	return ch
+}
+
+// SetRecorder	record messages delivered in order from now on,
+//				Option.Recorder to record from first packet
+func (c *Client) SetRecorder(rec *Recorder) {
+	c.recorder.Store(rec)
+}
+
+// Session	session of packets received, blank before first packet
+func (c *Client) Session() string {
+	c.recvLock.Lock()
+	defer c.recvLock.Unlock()
+	return c.session
 }
 
 func (c *Client) SeqNo() int {
@@ -326,70 +747,70 @@ func (c *Client) LastSeq() (uint64, int) {
 	return seqNo, int(ret)
 }
 
+// CacheStats	occupancy of cache for messages received out of order
+func (c *Client) CacheStats() CacheStats {
+	return c.cache.Stats()
+}
+
 func (c *Client) DumpStats() {
-	log.Infof("Total Recv:%d seqNo: %d/%d,error: %d,missed: %d, Request: %d/%d"+
-		"\nmaxCache: %d, cache merge: %d", c.nRecvs, c.seqNo, c.seqMax, c.nError,
-		c.nMissed, c.nRequest, c.nRepeats, c.cache.maxPageNo, c.nMerges)
+	st := c.Stats()
+	log.Infof("Total Recv:%d seqNo: %d, gaps: %d/%d, error: %d, missed: %d"+
+		", Request: %d/%d, reTrans latency mean: %v\ncache: %d/%d, maxCache: %d"+
+		", overflow: %d, cache merge: %d, rollover: %d, recovered: %d", st.Recvs,
+		st.SeqNo, st.Gaps, st.GapMessages, st.Errors, st.Missed, st.Requests,
+		st.Repeats, st.Latency.Mean(), st.Cache.Cached, st.Cache.Capacity,
+		st.Cache.MaxCached, st.Cache.Overflow, st.Merges, st.Rollovers,
+		st.Recovered)
+	if len(c.lines) < 2 {
+		return
+	}
+	for _, st := range c.LineStats() {
+		log.Infof("Line %s Recv: %d, first: %d, late: %d, dup: %d, lost: %d"+
+			", lag mean/max: %v/%v", st.Name, st.Recvs, st.First, st.Late, st.Dups,
+			st.Lost, st.MeanLag, st.MaxLag)
+	}
 }
 
 func NewClient(udpAddr string, port int, opt *Option, conn McastConn, startOnFirst bool) (*Client, error) {
-	var err error
-	client := Client{conn: conn, seqNo: opt.NextSeq}
-	if client.seqNo == 0 {
-		client.seqNo++
-	}
-	if startOnFirst {
-		client.startOnFirst = true
-	}
-	client.dstIP = net.ParseIP(udpAddr)
-	client.dstPort = port
-	if !client.dstIP.IsMulticast() {
-		log.Info(client.dstIP, "is not multicast IP")
-		client.dstIP = net.IPv4(224, 0, 0, 1)
-	}
-	var ifn *net.Interface
-	if opt.IfName != "" {
-		if ifn, err = net.InterfaceByName(opt.IfName); err != nil {
-			log.Errorf("Ifn(%s) error: %v\n", opt.IfName, err)
-			ifn = nil
-		}
-	}
-	if err := client.conn.Open(client.dstIP, port, ifn); err != nil {
-		log.Error("Open Multicast", err)
-		return nil, err
-	}
-	for _, daddr := range opt.Srvs {
-		ss := strings.Split(daddr, ":")
-		udpA := net.UDPAddr{IP: net.ParseIP(ss[0])}
-		if len(ss) == 1 {
-			udpA.Port = port
-		} else {
-			udpA.Port = to.Int(ss[1])
-		}
-		client.reqSrv = append(client.reqSrv, udpA)
-	}
-	client.ch = make(chan msgBuf, 5000)
-	client.cache.Init()
-	client.Running = true
-	client.LastRecv = time.Now().Unix()
-	go client.requestLoop()
-	go client.doMsgLoop()
-	return &client, nil
+	return NewClientContext(context.Background(), udpAddr, port, opt, conn,
+		startOnFirst)
+}
+
+// NewClientContext	NewClient stopped by cancel of ctx, conn closed on stop
+func NewClientContext(ctx context.Context, udpAddr string, port int, opt *Option, conn McastConn, startOnFirst bool) (*Client, error) {
+	feeds := []Feed{{Addr: udpAddr, Port: port, Conn: conn}}
+	return NewClientFeeds(ctx, feeds, opt, startOnFirst)
+}
+
+// reqSrvAddr	address of request server host[:port], IPv6 as [host]:port
+//				or bare host, port used if not given
+func reqSrvAddr(daddr string, port int) net.UDPAddr {
+	host, sPort, err := net.SplitHostPort(daddr)
+	if err != nil {
+		return net.UDPAddr{IP: net.ParseIP(strings.Trim(daddr, "[]")), Port: port}
+	}
+	return net.UDPAddr{IP: net.ParseIP(host), Port: to.Int(sPort)}
+}
+
+// waitDone	wakeup Read and unblock Recv when stopped
+func (c *Client) waitDone() {
+	<-c.ctx.Done()
+	c.readLock.Lock()
+	c.readCond.Broadcast()
+	c.readLock.Unlock()
+	c.closeOnce.Do(c.closeConn)
 }
 
 func (c *Client) requestLoop() {
 	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
 	//nextReqT := int64(0)
-	for c.Running {
+	for {
 		select {
+		case <-c.ctx.Done():
+			return
 		case <-ticker.C:
-			if c.seqNo < c.seqMax {
-				req := c.newReq(c.seqMax)
-				if req != nil {
-					// need send Request
-					c.request(req)
-				}
-			}
+			c.tick()
 			/*
 				tt := time.Now().Unix()
 				if nextReqT != 0 {
@@ -407,126 +828,165 @@ func (c *Client) requestLoop() {
 				}
 			*/
 		case msgBB, ok := <-c.ch:
-			log.Infof("about to doMsgBuf %d bytes", len(msgBB.dataBuf))
 			if ok {
-				if req, err := c.doMsgBuf(&msgBB); err != nil {
-					if c.lastLogTime < time.Now().Unix() {
-						c.lastLogTime = time.Now().Unix()
-						log.Errorf("doMsgBuf len(%d) %v", len(msgBB.dataBuf), err)
-					}
-				} else {
-					if req != nil {
-						// need send Request
-						c.request(req)
-					}
-
-				}
+				c.doMsg(&msgBB)
 			}
 		}
 	}
 }
 
-func (c *Client) doMsgLoop() {
-	if c.conn.Enabled(HasRingBuffer) {
-		c.conn.Listen(func(buff []byte, rAddr *net.UDPAddr) {
-			if err := c.gotBuff(buff, len(buff)); err != nil {
-				if c.lastLogTime != time.Now().Unix() {
-					log.Error("Packet from", rAddr, " error:", err)
-					c.lastLogTime = time.Now().Unix()
-				}
-				log.Error("Packet from", rAddr, " error:", err)
-			} else {
-				if len(c.reqSrv) == 0 {
-					// request port diff from sending source port
-					rAddr.Port = c.dstPort + 1
-					c.reqSrv = append(c.reqSrv, *rAddr)
-				}
+// tick		request for gap not filled, called periodically
+func (c *Client) tick() {
+	if c.seqNo < c.seqMax {
+		req := c.newReq(c.seqMax)
+		if req != nil {
+			// need send Request
+			c.request(req)
+		}
+	}
+}
+
+// doMsg	process packet queued, request retransmission for gap
+func (c *Client) doMsg(msgBB *msgBuf) {
+	req, err := c.doMsgBuf(msgBB)
+	if msgBB.pb != nil {
+		// not cached or delivered
+		msgBB.pb.release()
+		msgBB.pb = nil
+	}
+	if err != nil {
+		if c.lastLogTime < time.Now().Unix() {
+			c.lastLogTime = time.Now().Unix()
+			log.Errorf("doMsgBuf len(%d) %v", len(msgBB.dataBuf), err)
+		}
+	} else {
+		if req != nil {
+			// need send Request
+			c.request(req)
+		}
+
+	}
+}
+
+// doMsgLoop	recv multicast packets of line
+func (c *Client) doMsgLoop(line *feedLine) {
+	recvLoop(c.ctx, line.conn, func(buff []byte, rAddr *net.UDPAddr) error {
+		if err := c.gotBuff(buff, len(buff), line); err != nil {
+			return err
+		}
+		c.addReqSrv(rAddr, line.port+1)
+		return nil
+	})
+}
+
+// recvLoop	recv packets of conn till ctx done, fx called for each packet
+func recvLoop(ctx context.Context, conn McastConn, fx func([]byte, *net.UDPAddr) error) {
+	var lastLogTime int64
+	logErr := func(rAddr *net.UDPAddr, err error) {
+		if tt := time.Now().Unix(); lastLogTime != tt {
+			log.Error("Packet from", rAddr, " error:", err)
+			lastLogTime = tt
+		}
+	}
+	if conn.Enabled(HasRingBuffer) {
+		conn.Listen(func(buff []byte, rAddr *net.UDPAddr) {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := fx(buff, rAddr); err != nil {
+				logErr(rAddr, err)
 			}
 		})
 		return
 	}
-	bMmsg := c.conn.Enabled(HasMmsg)
+	bMmsg := conn.Enabled(HasMmsg)
 	if bMmsg {
 		log.Info("Using Recvmmsg for multicast recv")
 	}
 	buff := make([]byte, 2048)
-	for c.Running {
+	for ctx.Err() == nil {
 		if bMmsg {
-			bufs, remoteAddr, err := c.conn.MRecv()
+			bufs, remoteAddr, err := conn.MRecv()
 			if err != nil {
-				if c.lastLogTime != time.Now().Unix() {
+				if ctx.Err() != nil {
+					break
+				}
+				if tt := time.Now().Unix(); lastLogTime != tt {
 					log.Error("MRecv from", remoteAddr, " ", err)
-					c.lastLogTime = time.Now().Unix()
+					lastLogTime = tt
 				}
 				continue
 			}
 			for i := 0; i < len(bufs); i++ {
-				buf := []byte(bufs[i])
-				bLen := len(buf)
-				/*
-					if c.lastLogTime < time.Now().Unix() && i == 0 {
-						log.Infof("MRecv got %d bufs,buf0 len: %d", len(bufs), bLen)
-						c.lastLogTime = time.Now().Unix()
-					}
-				*/
-				if err := c.gotBuff(buf, bLen); err != nil {
-					if c.lastLogTime != time.Now().Unix() {
-						log.Error("Packet from", remoteAddr, " error:", err)
-						c.lastLogTime = time.Now().Unix()
-					}
-					continue
-				} else {
-					if len(c.reqSrv) == 0 {
-						// request port diff from sending source port
-						remoteAddr.Port = c.dstPort + 1
-						c.reqSrv = append(c.reqSrv, *remoteAddr)
-					}
+				if err := fx([]byte(bufs[i]), remoteAddr); err != nil {
+					logErr(remoteAddr, err)
 				}
 			}
 		} else {
-			n, remoteAddr, err := c.conn.Recv(buff)
+			n, remoteAddr, err := conn.Recv(buff)
 			if err != nil {
+				if ctx.Err() != nil {
+					break
+				}
 				log.Error("Recv from", remoteAddr, " ", err)
 				continue
 			}
-			if err := c.gotBuff(buff, n); err != nil {
-				if c.lastLogTime != time.Now().Unix() {
-					log.Error("Packet from", remoteAddr, " error:", err)
-					c.lastLogTime = time.Now().Unix()
-				}
-				continue
-			} else {
-				if len(c.reqSrv) == 0 {
-					// request port diff from sending source port
-					remoteAddr.Port = c.dstPort + 1
-					c.reqSrv = append(c.reqSrv, *remoteAddr)
-				}
+			if err := fx(buff[:n], remoteAddr); err != nil {
+				logErr(remoteAddr, err)
 			}
 		}
 	}
 }
 
 func (c *Client) request(buff []byte) {
-	if len(c.reqSrv) == 0 {
+	c.recvLock.Lock()
+	reqSrv := c.reqSrv
+	c.recvLock.Unlock()
+	if len(reqSrv) == 0 {
 		return
 	}
-	c.nRequest++
-	if c.nRequest < 5 {
-		log.Info("Send reTrans seq:", c.seqNo, " req to", c.reqSrv[c.robinN])
+	if atomic.AddInt64(&c.nRequest, 1) < 5 {
+		log.Info("Send reTrans seq:", c.seqNo, " req to", reqSrv[c.robinN])
 	}
+	c.trackReq(buff)
+	// own request socket of each channel of Manager too, replies of
+	// channels with same session never mixed
 	if c.connReq == nil {
-		if conn, err := net.DialUDP("udp", nil, &c.reqSrv[c.robinN]); err != nil {
+		if conn, err := net.DialUDP("udp", nil, &reqSrv[c.robinN]); err != nil {
 			log.Error("DialUDP requestSrv", err)
 			return
 		} else {
 			c.connReq = conn
+			go c.retransLoop(conn)
 		}
 	}
-	if _, err := c.connReq.Write(buff[:]); err != nil {
-		log.Error("Req WriteToUDP", err)
+	if c.connReq != nil {
+		if _, err := c.connReq.Write(buff[:]); err != nil {
+			log.Error("Req WriteToUDP", err)
+		}
 	}
 	c.robinN++
-	if c.robinN >= len(c.reqSrv) {
+	if c.robinN >= len(reqSrv) {
 		c.robinN = 0
 	}
 }
+
+// retransLoop	recv retransmission reply from request server
+func (c *Client) retransLoop(conn *net.UDPConn) {
+	buff := make([]byte, 2048)
+	for c.ctx.Err() == nil {
+		n, rAddr, err := conn.ReadFromUDP(buff)
+		if err != nil {
+			if c.ctx.Err() == nil {
+				log.Error("Recv reTrans", err)
+			}
+			return
+		}
+		if err := c.gotBuff(buff, n, nil); err != nil {
+			if c.lastLogTime != time.Now().Unix() {
+				log.Error("reTrans packet from", rAddr, " error:", err)
+				c.lastLogTime = time.Now().Unix()
+			}
+		}
+	}
+}
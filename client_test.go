@@ -0,0 +1,388 @@
+package MoldUDP
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kjx98/go-mold/itch"
+)
+
+// chanConn	McastConn recv packets from channel
+type chanConn struct {
+	fakeConn
+	rx       chan Packet
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newChanConn() *chanConn {
+	return &chanConn{rx: make(chan Packet, 64), done: make(chan struct{})}
+}
+
+func (c *chanConn) Close() error {
+	c.doneOnce.Do(func() { close(c.done) })
+	return nil
+}
+
+func (c *chanConn) Recv(buff []byte) (int, *net.UDPAddr, error) {
+	select {
+	case pkt := <-c.rx:
+		return copy(buff, pkt), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, nil
+	case <-c.done:
+		return 0, nil, errClosed
+	}
+}
+
+func buildPacket(session string, seqNo uint64, msgCnt uint16, msgs []Message) Packet {
+	buff := make([]byte, maxUDPSize)
+	if len(msgs) > 0 {
+		_, n := packMessages(buff, session, seqNo, msgs)
+		return buff[:n]
+	}
+	EncodeHead(buff, &Header{Session: session, SeqNo: seqNo, MessageCnt: msgCnt})
+	return buff[:headSize]
+}
+
+func TestClientEndOfSession(t *testing.T) {
+	conn := newChanConn()
+	cc, err := NewClientContext(context.Background(), "239.192.168.1", 5858,
+		&Option{}, conn, false)
+	if err != nil {
+		t.Fatal("NewClientContext", err)
+	}
+	defer cc.Close()
+	msgs := []Message{{Data: []byte("a")}, {Data: []byte("b")}, {Data: []byte("c")}}
+	conn.rx <- buildPacket("test0", 1, 0, msgs[:2])
+	conn.rx <- buildPacket("test0", 3, 0, msgs[2:])
+	conn.rx <- buildPacket("test0", 4, 0xffff, nil)
+	seqNo := uint64(1)
+	for {
+		res, seqF, err := cc.Read()
+		if err != nil {
+			if err != ErrEndOfSession {
+				t.Error("Read()", err)
+			}
+			break
+		}
+		if seqF != seqNo {
+			t.Errorf("Read() seqNo = %d, want %d", seqF, seqNo)
+		}
+		for _, msg := range res {
+			if string(msg.Data) != string(msgs[seqNo-1].Data) {
+				t.Errorf("message %d = %s, want %s", seqNo, msg.Data,
+					msgs[seqNo-1].Data)
+			}
+			seqNo++
+		}
+	}
+	if seqNo != 4 {
+		t.Errorf("Read %d messages, want 3", seqNo-1)
+	}
+	if cc.Running() {
+		t.Error("Client still running after End-of-Session")
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	conn := newChanConn()
+	ctx, cancel := context.WithCancel(context.Background())
+	cc, err := NewClientContext(ctx, "239.192.168.1", 5858, &Option{}, conn, false)
+	if err != nil {
+		t.Fatal("NewClientContext", err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, _, err := cc.Read(); err != context.Canceled {
+		t.Errorf("Read() error = %v, want %v", err, context.Canceled)
+	}
+	select {
+	case <-conn.done:
+	case <-time.After(time.Second):
+		t.Error("conn not closed after cancel")
+	}
+	cc.Close()
+}
+
+func TestClientMessages(t *testing.T) {
+	conn := newChanConn()
+	cc, err := NewClient("239.192.168.1", 5858, &Option{NextSeq: 5}, conn, false)
+	if err != nil {
+		t.Fatal("NewClient", err)
+	}
+	defer cc.Close()
+	msgs := []Message{{Data: []byte("e")}, {Data: []byte("f")}, {Data: []byte("g")}}
+	conn.rx <- buildPacket("test0", 5, 0, msgs)
+	conn.rx <- buildPacket("test0", 8, 0xffff, nil)
+	seqNo := uint64(5)
+	for msg := range cc.Messages() {
+		if msg.SeqNo != seqNo || string(msg.Data) != string(msgs[seqNo-5].Data) {
+			t.Errorf("got message %d: %s, want %d: %s", msg.SeqNo, msg.Data,
+				seqNo, msgs[seqNo-5].Data)
+		}
+		seqNo++
+	}
+	if seqNo != 8 {
+		t.Errorf("got %d messages, want 3", seqNo-5)
+	}
+}
+
+func TestClientRollover(t *testing.T) {
+	msgs := []Message{{Data: []byte("a")}, {Data: []byte("b")}, {Data: []byte("c")},
+		{Data: []byte("d")}}
+	tests := []struct {
+		name     string
+		rollover int
+		eos      bool
+		want     string
+		nRoll    int64
+	}{
+		{"reject", SessionReject, false, "1a 2b", 0},
+		{"follow", SessionFollow, false, "1a 2b 1c 2d", 1},
+		{"notify", SessionNotify, false, "1a 2b !test0>test1@3 1c 2d", 1},
+		{"reject eos", SessionReject, true, "1a 2b", 0},
+		{"follow eos", SessionFollow, true, "1a 2b 1c 2d", 1},
+		{"notify eos", SessionNotify, true, "1a 2b !test0>test1@3/eos 1c 2d", 1},
+	}
+	for _, tt := range tests {
+		conn := newChanConn()
+		cc, err := NewClient("239.192.168.1", 5858, &Option{Rollover: tt.rollover},
+			conn, false)
+		if err != nil {
+			t.Fatal("NewClient", err)
+		}
+		conn.rx <- buildPacket("test0", 1, 0, msgs[:2])
+		var delay time.Duration
+		if tt.eos {
+			// daily roll, old session read to its end before new one starts
+			conn.rx <- buildPacket("test0", 3, 0xffff, nil)
+			delay = 50 * time.Millisecond
+		}
+		go func(conn *chanConn) {
+			time.Sleep(delay)
+			conn.rx <- buildPacket("test1", 1, 0, msgs[2:3])
+			conn.rx <- buildPacket("test1", 2, 0, msgs[3:])
+			conn.rx <- buildPacket("test1", 3, 0xffff, nil)
+			// previous session not followed again
+			conn.rx <- buildPacket("test0", 3, 0xffff, nil)
+		}(conn)
+		tm := time.AfterFunc(2*time.Second, cc.Stop)
+		nWant := len(strings.Fields(tt.want))
+		var got []string
+		for {
+			res, seqNo, err := cc.Read()
+			if evt, ok := err.(*SessionChanged); ok {
+				ss := fmt.Sprintf("!%s>%s@%d", evt.Old, evt.New, evt.SeqNo)
+				if evt.Ended {
+					ss += "/eos"
+				}
+				got = append(got, ss)
+				continue
+			} else if err != nil {
+				// followed sessions never end, stopped after all read
+				want := ErrEndOfSession
+				if tt.rollover != SessionReject {
+					want = context.Canceled
+				}
+				if err != want {
+					t.Errorf("%s: Read() %v, want %v", tt.name, err, want)
+				}
+				break
+			}
+			for _, msg := range res {
+				got = append(got, fmt.Sprintf("%d%s", seqNo, msg.Data))
+				seqNo++
+			}
+			if tt.rollover != SessionReject && len(got) >= nWant {
+				if !cc.Running() {
+					t.Errorf("%s: client stopped by End-of-Session", tt.name)
+				}
+				cc.Stop()
+			}
+		}
+		tm.Stop()
+		if s := strings.Join(got, " "); s != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, s, tt.want)
+		}
+		if cc.nRollover != tt.nRoll {
+			t.Errorf("%s: %d rollover, want %d", tt.name, cc.nRollover, tt.nRoll)
+		}
+		cc.Close()
+	}
+}
+
+func TestServerClientItch(t *testing.T) {
+	sConn := &fakeConn{}
+	srv, err := NewServer("239.192.168.1", 5858, "itch0", &Option{}, sConn, false)
+	if err != nil {
+		t.Fatal("NewServer", err)
+	}
+	feed := itchFeed(300)
+	if _, err := srv.Send(feed); err != nil {
+		t.Fatal("Server Send", err)
+	}
+	srv.Close()
+	conn := newChanConn()
+	cc, err := NewClient("239.192.168.1", 5858, &Option{}, conn, false)
+	if err != nil {
+		t.Fatal("NewClient", err)
+	}
+	defer cc.Close()
+	for _, pkt := range sConn.pkts {
+		conn.rx <- pkt
+	}
+	d := itch.NewDecoder()
+	nAdd, nDel := 0, 0
+	d.Handle('A', func(msg itch.Message) {
+		if m := msg.(*itch.AddOrder); m.OrderRef != uint64(nAdd+1) || m.Stock != aaplStock {
+			t.Errorf("AddOrder %+v, want OrderRef %d", m, nAdd+1)
+		}
+		nAdd++
+	})
+	d.Handle('D', func(msg itch.Message) {
+		if m := msg.(*itch.OrderDelete); m.OrderRef != uint64(nDel+1) {
+			t.Errorf("OrderDelete %+v, want OrderRef %d", m, nDel+1)
+		}
+		nDel++
+	})
+	err = cc.Subscribe(func(seqNo uint64, msg Message) {
+		if err := d.Dispatch(msg.Data); err != nil {
+			t.Errorf("Dispatch message %d error %v", seqNo, err)
+		}
+	})
+	if err != ErrEndOfSession {
+		t.Errorf("Subscribe() error = %v, want %v", err, ErrEndOfSession)
+	}
+	if nAdd != 150 || nDel != 150 {
+		t.Errorf("got %d AddOrder, %d OrderDelete, want 150 each", nAdd, nDel)
+	}
+}
+
+// newTestClient	Client without recv/request loops for doMsgBuf
+func newTestClient(seqNo uint64) *Client {
+	c := &Client{seqNo: seqNo, session: "test0", curSession: "test0"}
+	c.sessHint.Store(c.session)
+	c.cache.Init()
+	c.readCond = sync.NewCond(&c.readLock)
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	return c
+}
+
+func TestDoMsgBuf(t *testing.T) {
+	msgs := make([]Message, 8)
+	for i := range msgs {
+		msgs[i].Data = []byte{byte('a' + i)}
+	}
+	// packet of messages seqNo to seqNo+cnt-1, cnt 0 or 0xffff header only
+	pkt := func(seqNo uint64, cnt int) *msgBuf {
+		var pp Packet
+		if cnt == 0 || cnt == 0xffff {
+			pp = buildPacket("test0", seqNo, uint16(cnt), nil)
+		} else {
+			pp = buildPacket("test0", seqNo, 0, msgs[seqNo-1:int(seqNo)-1+cnt])
+		}
+		return &msgBuf{session: "test0", seqNo: seqNo, msgCnt: uint16(cnt),
+			dataBuf: pp[headSize:]}
+	}
+	tests := []struct {
+		name   string
+		msgBB  *msgBuf
+		req    *Header
+		ready  string
+		seqNo  uint64
+		bDone  bool
+		repeat int64
+	}{
+		{"in order", pkt(1, 2), nil, "ab", 3, false, 0},
+		{"gap", pkt(5, 1), &Header{"test0", 3, 2}, "", 3, false, 0},
+		{"fill gap", pkt(3, 2), nil, "cde", 6, false, 0},
+		{"repeat", pkt(2, 1), nil, "", 6, false, 1},
+		{"overlap", pkt(5, 2), nil, "f", 7, false, 1},
+		{"heartbeat gap", pkt(8, 0), &Header{"test0", 7, 1}, "", 7, false, 1},
+		{"end of session", pkt(9, 0xffff), &Header{"test0", 7, 2}, "", 7, false, 1},
+		{"last", pkt(7, 2), nil, "gh", 9, true, 1},
+	}
+	c := newTestClient(1)
+	for _, tt := range tests {
+		// no request interval limit
+		c.reqLast = time.Time{}
+		req, err := c.doMsgBuf(tt.msgBB)
+		if err != nil {
+			t.Fatalf("%s: doMsgBuf() %v", tt.name, err)
+		}
+		if tt.req == nil && req != nil {
+			t.Errorf("%s: unexpected request %v", tt.name, req)
+		} else if tt.req != nil {
+			var head Header
+			if err := DecodeHead(req, &head); err != nil || head != *tt.req {
+				t.Errorf("%s: request %+v, want %+v", tt.name, head, *tt.req)
+			}
+		}
+		var ready string
+		for _, msg := range c.ready {
+			ready += string(msg.Data)
+		}
+		c.ready = nil
+		if ready != tt.ready || c.seqNo != tt.seqNo {
+			t.Errorf("%s: ready %q seqNo %d, want %q %d", tt.name, ready, c.seqNo,
+				tt.ready, tt.seqNo)
+		}
+		if c.bDone != tt.bDone || c.nRepeats != tt.repeat {
+			t.Errorf("%s: bDone %v repeats %d, want %v %d", tt.name, c.bDone,
+				c.nRepeats, tt.bDone, tt.repeat)
+		}
+	}
+	if _, err := c.doMsgBuf(&msgBuf{session: "test0", seqNo: 9, msgCnt: 2,
+		dataBuf: []byte{0, 1}}); err == nil {
+		t.Error("doMsgBuf() with corrupt data no error")
+	}
+}
+
+func TestNewReq(t *testing.T) {
+	c := newTestClient(10)
+	if req := c.newReq(10); req != nil {
+		t.Errorf("newReq(10) = %v, want nil for nothing missed", req)
+	}
+	req := c.newReq(20)
+	var head Header
+	if err := DecodeHead(req, &head); err != nil || head.SeqNo != 10 ||
+		head.MessageCnt != 10 {
+		t.Errorf("newReq(20) = %+v, want seqNo 10 count 10", head)
+	}
+	if req := c.newReq(30); req != nil {
+		t.Error("newReq() within reqInterval not nil")
+	}
+	if c.seqMax != 30 {
+		t.Errorf("seqMax = %d, want 30", c.seqMax)
+	}
+	c.reqLast = time.Time{}
+	req = c.newReq(10 + 2*nakWindow)
+	if err := DecodeHead(req, &head); err != nil || head.MessageCnt != nakWindow {
+		t.Errorf("newReq() count %d, want %d", head.MessageCnt, nakWindow)
+	}
+}
+
+func TestReqSrvAddr(t *testing.T) {
+	tests := []struct {
+		daddr string
+		want  string
+	}{
+		{"127.0.0.1", "127.0.0.1:5859"},
+		{"127.0.0.1:6000", "127.0.0.1:6000"},
+		{"::1", "[::1]:5859"},
+		{"[::1]", "[::1]:5859"},
+		{"[fe80::1]:6000", "[fe80::1]:6000"},
+	}
+	for _, tt := range tests {
+		adr := reqSrvAddr(tt.daddr, 5859)
+		if got := adr.String(); got != tt.want {
+			t.Errorf("reqSrvAddr(%s) = %s, want %s", tt.daddr, got, tt.want)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"encoding/binary"
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -11,6 +11,8 @@ import (
 
 	ats "github.com/kjx98/go-ats"
 	MoldUDP "github.com/kjx98/go-mold"
+	"github.com/kjx98/go-mold/itch"
+	"github.com/kjx98/go-mold/metrics"
 	logging "github.com/op/go-logging"
 )
 
@@ -18,20 +20,6 @@ var log = logging.MustGetLogger("mold-client")
 
 var opt MoldUDP.Option
 
-var coder = binary.BigEndian
-
-type contents struct {
-	trackingN uint16
-	timeStamp uint64
-	stock string
-	secClass string
-	bidPrice uint32
-	bidSize uint32
-	askPrice uint32
-	askSize uint32
-}
-
-
 func main() {
 	var maddr string
 	var port int
@@ -40,28 +28,127 @@ func main() {
 	var firstTic, lastTic *ats.TickFX
 	var fTic, lTic ats.TickFX
 
-	flag.StringVar(&maddr, "m", "239.192.168.1", "Multicast IPv4 to listen")
+	flag.StringVar(&maddr, "m", "239.192.168.1", "Multicast IPv4/IPv6 group to listen")
 	flag.StringVar(&opt.IfName, "i", "", "Interface name for multicast")
+	flag.StringVar(&opt.Source, "src", "", "Source address of publisher for SSM, blank for any-source")
 	flag.IntVar(&port, "p", 5858, "UDP port to listen")
+	flag.IntVar(&opt.Rollover, "rollover", MoldUDP.SessionReject, "Session rollover: 0 reject, 1 follow, 2 follow and notify")
 	flag.IntVar(&waits, "w", 30, "seconds wait for UDP packet, 0 unlimited")
-	flag.StringVar(&netMode, "net", "net", "Multicast Recv network interface, net/sock/zsock")
+	flag.StringVar(&netMode, "net", "net", "Multicast Recv network interface, net/sock/zsock or pcap:file")
+	var maddrB, ifNameB, netModeB string
+	var portB int
+	flag.StringVar(&maddrB, "mb", "", "Multicast group of B line, blank for single line")
+	flag.IntVar(&portB, "pb", 0, "UDP port of B line, 0 same as -p")
+	flag.StringVar(&ifNameB, "ib", "", "Interface name for B line, blank same as -i")
+	flag.StringVar(&netModeB, "netb", "", "Multicast Recv network interface of B line, blank same as -net")
 	var reqServ string
 	flag.StringVar(&reqServ, "req", "", "Multicast Req address:port")
 	opt.Srvs = []string{reqServ}
+	flag.BoolVar(&opt.LateJoin, "late", false, "Late join, recover messages from sequence 1 or -seq")
+	flag.Uint64Var(&opt.NextSeq, "seq", 0, "Next sequence number to receive, 1 based")
+	var snapFile string
+	flag.StringVar(&snapFile, "snap", "", "Recorded session file for late join recovery")
+	var soupAddr, soupUser, soupPass string
+	flag.StringVar(&soupAddr, "soup", "", "SoupBinTCP server host:port for recovery, instead of -snap")
+	flag.StringVar(&soupUser, "soupuser", "", "SoupBinTCP login username")
+	flag.StringVar(&soupPass, "souppass", "", "SoupBinTCP login password")
+	var recFile string
+	flag.StringVar(&recFile, "rec", "", "Record session to file")
+	var pcapFile string
+	flag.StringVar(&pcapFile, "pcap", "", "Write packets received to pcap file")
+	var metricsAddr string
+	flag.StringVar(&metricsAddr, "metrics", "", "Serve Prometheus metrics on host:port/metrics, blank disabled")
+	var imp MoldUDP.Impairment
+	flag.Float64Var(&imp.Drop, "drop", 0, "Impairment: probability of packet loss")
+	flag.Float64Var(&imp.Burst, "burst", 0, "Impairment: mean length of loss burst")
+	flag.Float64Var(&imp.Reorder, "reorder", 0, "Impairment: probability of packet reordered")
+	flag.IntVar(&imp.Window, "window", 1, "Impairment: max packets a reordered packet delayed")
+	flag.Float64Var(&imp.Dup, "dup", 0, "Impairment: probability of packet duplicated")
+	flag.DurationVar(&imp.Delay, "delay", 0, "Impairment: delay of each packet")
+	flag.DurationVar(&imp.Jitter, "jitter", 0, "Impairment: max random delay of packet")
+	flag.Int64Var(&imp.Seed, "seed", 0, "Impairment: random seed")
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: client [options]\n")
 		flag.PrintDefaults()
 		os.Exit(2)
 	}
 	flag.Parse()
-	netif := MoldUDP.NewIf(netMode)
-	log.Info("Client listen", maddr, "via", netif)
-	cc, err := MoldUDP.NewClient(maddr, port, &opt, netif, true)
+	if snapFile != "" {
+		rf, err := MoldUDP.OpenRecord(snapFile)
+		if err != nil {
+			log.Error("OpenRecord", err)
+			os.Exit(1)
+		}
+		defer rf.Close()
+		opt.Recovery = rf
+	} else if soupAddr != "" {
+		sc := MoldUDP.NewSoupClient(soupAddr, soupUser, soupPass, "")
+		defer sc.Close()
+		opt.Recovery = sc
+	}
+	var wrapIf func(MoldUDP.McastConn) MoldUDP.McastConn
+	if pcapFile != "" {
+		pw, err := MoldUDP.NewPcapWriter(pcapFile)
+		if err != nil {
+			log.Error("NewPcapWriter", err)
+			os.Exit(1)
+		}
+		defer pw.Close()
+		wrapIf = func(netif MoldUDP.McastConn) MoldUDP.McastConn {
+			return MoldUDP.NewPcapTee(netif, pw)
+		}
+	}
+	newIf := func(mode string) MoldUDP.McastConn {
+		netif := MoldUDP.NewIf(mode)
+		if wrapIf != nil {
+			netif = wrapIf(netif)
+		}
+		if imp.Enabled() {
+			netif = MoldUDP.NewImpairIf(netif, &imp)
+		}
+		return netif
+	}
+	feeds := []MoldUDP.Feed{{Addr: maddr, Port: port, Conn: newIf(netMode)}}
+	if maddrB != "" {
+		if portB == 0 {
+			portB = port
+		}
+		if netModeB == "" {
+			netModeB = netMode
+		}
+		feeds = append(feeds, MoldUDP.Feed{Addr: maddrB, Port: portB,
+			IfName: ifNameB, Conn: newIf(netModeB)})
+	}
+	for _, feed := range feeds {
+		log.Info("Client listen", feed.Addr, "via", feed.Conn)
+	}
+	if recFile != "" {
+		rec, err := MoldUDP.NewRecorder(recFile)
+		if err != nil {
+			log.Error("NewRecorder", err)
+			os.Exit(1)
+		}
+		// closed after client
+		defer rec.Close()
+		opt.Recorder = rec
+	}
+	cc, err := MoldUDP.NewClientFeeds(context.Background(), feeds, &opt, true)
 	if err != nil {
-		log.Error("NewClient", err)
+		log.Error("NewClientFeeds", err)
 		os.Exit(1)
 	}
 	defer cc.Close()
+	if metricsAddr != "" {
+		reg := metrics.NewRegistry()
+		reg.AddClient(maddr, cc)
+		ln, err := reg.Listen(metricsAddr)
+		if err != nil {
+			log.Error("metrics Listen", err)
+			os.Exit(1)
+		}
+		defer ln.Close()
+		log.Info("Serve metrics on", ln.Addr())
+	}
 	// catch  SIGTERM, SIGINT, SIGUP
 	sigC := make(chan os.Signal, 10)
 	signal.Notify(sigC)
@@ -70,11 +157,11 @@ func main() {
 			switch s {
 			case os.Kill, os.Interrupt, syscall.SIGTERM:
 				log.Info("退出", s)
-				cc.Running = false
+				cc.Stop()
 				ExitFunc()
 			case syscall.SIGQUIT:
 				log.Info("Quit", s)
-				cc.Running = false
+				cc.Stop()
 				ExitFunc()
 			default:
 				//log.Info("Got signal", s)
@@ -82,18 +169,27 @@ func main() {
 		}
 	}()
 
-	cc.Running = true
 	lastSeq := uint64(0)
+	decoder := itch.NewDecoder()
+	// deliberately replace ITCH CrossTrade, 'Q' of our feed is quote
+	decoder.Register(&quote{})
+	var j int
+	decoder.Handle('Q', func(msg itch.Message) {
+		fmt.Printf("  Message: %d \n    Contents: %+v \n ", j, *msg.(*quote))
+	})
 	go func() {
-		for cc.Running {
+		for {
 			log.Infof("About to read")
 			mess, lastS, err := cc.Read()
 			log.Infof("Got %d messages", len(mess))
-			if err != nil {
-				log.Error("Client Read", err)
+			if evt, ok := err.(*MoldUDP.SessionChanged); ok {
+				log.Info(evt, "at seqNo", evt.SeqNo)
 				continue
 			}
-			if mess == nil {
+			if err != nil {
+				if err != MoldUDP.ErrEndOfSession {
+					log.Error("Client Read", err)
+				}
 				break
 			}
 			if len(mess) == 0 {
@@ -116,50 +212,32 @@ func main() {
 				}
 			}
 
-			var j int
 			for j = 0; j < len(mess); j++ {
-				msg := mess[j]
-				msgType := msg.Data[0:1]
-				if string(msgType) == "Q" {
-					if len(msg.Data) != 34 {
-						log.Fatal("Wrong message length!")
-					}
-
-					var timestamp uint64
-					factor := 1
-					for k := 8; k >= 3; k-- {
-						timestamp = uint64(int(msg.Data[k])*factor)
-						factor *= 256
-					}
-					cnts := contents{
-						trackingN : coder.Uint16(msg.Data[1:3]),
-						timeStamp : timestamp,
-						stock : string(msg.Data[9:17]),
-						secClass : string(msg.Data[17:18]),
-						bidPrice : coder.Uint32(msg.Data[18:22]),
-						bidSize : coder.Uint32(msg.Data[22:26]),
-						askPrice : coder.Uint32(msg.Data[26:30]),
-						askSize : coder.Uint32(msg.Data[30:34]),
-					}
-					fmt.Printf("  Message: %d \n    Contents: %+v \n ", j, cnts)
+				if len(mess[j].Data) == 0 {
+					continue
+				}
+				if err := decoder.Dispatch(mess[j].Data); err != nil {
+					log.Fatal("Decode message", j, err)
 				}
 			}
 		}
 		// should we stop?
-		cc.Running = false
+		cc.Stop()
 	}()
 	tick := time.NewTicker(time.Second)
 	if cc.LastRecv == 0 {
 		cc.LastRecv = time.Now().Unix()
 	}
 	nextDisp := int64(0)
-	for cc.Running {
+	for cc.Running() {
 		var tt int64
 		select {
+		case <-cc.Done():
+			continue
 		case <-tick.C:
 			tt = time.Now().Unix()
 			if waits > 0 && cc.LastRecv+int64(waits) < tt {
-				cc.Running = false
+				cc.Stop()
 				log.Errorf("No UDP recv for %d seconds", waits)
 			}
 		}
@@ -179,6 +257,11 @@ func main() {
 	lastSeqN, lastN := cc.LastSeq()
 	log.Infof("Last Block seqNo: %d/%d number: %d", lastSeq, lastSeqN, lastN)
 	cc.DumpStats()
+	for _, feed := range feeds {
+		if ds, ok := feed.Conn.(interface{ DumpStats() }); ok {
+			ds.DumpStats()
+		}
+	}
 	log.Info("exit client")
 	//os.Exit(0);
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/binary"
+
+	"github.com/kjx98/go-mold/itch"
+)
+
+var coder = binary.BigEndian
+
+const quoteLen = 34
+
+// quote	34 bytes 'Q' quote message of our feed, replace ITCH CrossTrade
+//			stock and secClass strings as printed by %+v before
+type quote struct {
+	trackingN uint16
+	timeStamp uint64
+	stock     string
+	secClass  string
+	bidPrice  uint32
+	bidSize   uint32
+	askPrice  uint32
+	askSize   uint32
+}
+
+func (m *quote) Type() byte {
+	return 'Q'
+}
+
+func (m *quote) Decode(b []byte) error {
+	if len(b) != quoteLen {
+		return itch.ErrTooShort
+	}
+	if b[0] != 'Q' {
+		return itch.ErrMsgType
+	}
+	m.trackingN = coder.Uint16(b[1:3])
+	m.timeStamp = 0
+	for k := 3; k < 9; k++ {
+		m.timeStamp = m.timeStamp<<8 | uint64(b[k])
+	}
+	m.stock = string(b[9:17])
+	m.secClass = string(b[17:18])
+	m.bidPrice = coder.Uint32(b[18:22])
+	m.bidSize = coder.Uint32(b[22:26])
+	m.askPrice = coder.Uint32(b[26:30])
+	m.askSize = coder.Uint32(b[30:34])
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	MoldUDP "github.com/kjx98/go-mold"
+	logging "github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("mold-replay")
+
+var opt MoldUDP.Option
+
+func main() {
+	var maddr string
+	var port int
+	var netMode string
+	var recFile, pcapFile string
+	var speed float64
+	var bLoop, bRewind bool
+
+	flag.StringVar(&maddr, "m", "239.192.168.1", "Multicast IPv4/IPv6 group to publish")
+	flag.StringVar(&opt.IfName, "i", "", "Interface name for multicast")
+	flag.IntVar(&port, "p", 5858, "UDP port to publish")
+	flag.StringVar(&netMode, "net", "net", "Multicast Send network interface, net/sock/zsock")
+	flag.StringVar(&recFile, "f", "", "Recorded session file to replay")
+	flag.Float64Var(&speed, "speed", 1, "Speed multiplier of original timing, 0 as fast as possible")
+	flag.Uint64Var(&opt.NextSeq, "seq", 0, "Sequence number start replay, default first recorded")
+	flag.BoolVar(&bLoop, "l", false, "Enable multicast loopback")
+	flag.StringVar(&pcapFile, "pcap", "", "Write packets sent to pcap file")
+	flag.BoolVar(&bRewind, "r", true, "Answer retransmission request on port+1")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: replay [options] -f file\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	flag.Parse()
+	if recFile == "" {
+		flag.Usage()
+	}
+	rf, err := MoldUDP.OpenRecord(recFile)
+	if err != nil {
+		log.Error("OpenRecord", err)
+		os.Exit(1)
+	}
+	defer rf.Close()
+	if opt.NextSeq == 0 {
+		opt.NextSeq = rf.FirstSeq()
+	}
+	if bRewind {
+		// Rewinder use its own reader
+		rs, err := MoldUDP.OpenRecord(recFile)
+		if err != nil {
+			log.Error("OpenRecord", err)
+			os.Exit(1)
+		}
+		defer rs.Close()
+		rr, err := MoldUDP.NewRewinder(rs.Session(), port+1, rs)
+		if err != nil {
+			log.Error("NewRewinder", err)
+			os.Exit(1)
+		}
+		defer rr.Close()
+		go rr.Serve()
+	}
+	netif := MoldUDP.NewIf(netMode)
+	if pcapFile != "" {
+		pw, err := MoldUDP.NewPcapWriter(pcapFile)
+		if err != nil {
+			log.Error("NewPcapWriter", err)
+			os.Exit(1)
+		}
+		defer pw.Close()
+		netif = MoldUDP.NewPcapTee(netif, pw)
+	}
+	log.Infof("Replay session %s from seqNo %d to %s:%d via %s", rf.Session(),
+		opt.NextSeq, maddr, port, netif)
+	srv, err := MoldUDP.NewServer(maddr, port, rf.Session(), &opt, netif, bLoop)
+	if err != nil {
+		log.Error("NewServer", err)
+		os.Exit(1)
+	}
+	defer srv.Close()
+	srv.StartHeartbeat(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigC := make(chan os.Signal, 10)
+	signal.Notify(sigC, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		log.Info("Quit", <-sigC)
+		cancel()
+	}()
+	n, err := MoldUDP.Replay(ctx, rf, srv, speed)
+	if err != nil {
+		log.Error("Replay", err)
+	}
+	log.Infof("Replayed %d messages", n)
+	srv.DumpStats()
+}
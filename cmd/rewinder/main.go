@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	MoldUDP "github.com/kjx98/go-mold"
+	logging "github.com/op/go-logging"
+)
+
+var log = logging.MustGetLogger("mold-rewinder")
+
+var opt MoldUDP.Option
+
+func main() {
+	var maddr string
+	var port, reqPort int
+	var netMode string
+	var session string
+	var recFile string
+	var soupPort int
+
+	flag.StringVar(&maddr, "m", "239.192.168.1", "Multicast IPv4/IPv6 group to listen")
+	flag.StringVar(&opt.IfName, "i", "", "Interface name for multicast")
+	flag.StringVar(&opt.Source, "src", "", "Source address of publisher for SSM, blank for any-source")
+	flag.IntVar(&port, "p", 5858, "UDP port to listen")
+	flag.IntVar(&reqPort, "r", 0, "UDP port for retransmission request, default port+1")
+	flag.StringVar(&netMode, "net", "net", "Multicast Recv network interface, net/sock/zsock")
+	flag.StringVar(&session, "s", "", "Session to serve, blank for first seen")
+	flag.StringVar(&recFile, "f", "", "Serve recorded session file instead of multicast feed")
+	flag.IntVar(&soupPort, "soup", 0, "TCP port of SoupBinTCP server for the same store, 0 for none")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: rewinder [options]\n")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	flag.Parse()
+	if reqPort == 0 {
+		reqPort = port + 1
+	}
+	if recFile != "" {
+		serveRecord(recFile, reqPort, soupPort)
+		return
+	}
+	store := MoldUDP.NewMemStore(1)
+	rr, err := MoldUDP.NewRewinder(session, reqPort, store)
+	if err != nil {
+		log.Error("NewRewinder", err)
+		os.Exit(1)
+	}
+	defer rr.Close()
+	go rr.Serve()
+	if soupPort != 0 {
+		ss, err := MoldUDP.NewSoupServer(session, soupPort, store)
+		if err != nil {
+			log.Error("NewSoupServer", err)
+			os.Exit(1)
+		}
+		defer ss.Close()
+		go ss.Serve()
+	}
+
+	netif := MoldUDP.NewIf(netMode)
+	log.Info("Rewinder record", maddr, "via", netif)
+	cc, err := MoldUDP.NewClient(maddr, port, &opt, netif, false)
+	if err != nil {
+		log.Error("NewClient", err)
+		os.Exit(1)
+	}
+	defer cc.Close()
+	sigC := make(chan os.Signal, 10)
+	signal.Notify(sigC, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	go func() {
+		s := <-sigC
+		log.Info("Quit", s)
+		cc.Stop()
+	}()
+
+	go func() {
+		for {
+			mess, seqNo, err := cc.Read()
+			if err != nil {
+				if err != MoldUDP.ErrEndOfSession {
+					log.Error("Client Read", err)
+				}
+				break
+			}
+			if err := store.Append(seqNo, mess); err != nil {
+				log.Error("Store Append", err)
+			}
+		}
+		cc.Stop()
+	}()
+	tick := time.NewTicker(time.Second)
+	nextDisp := time.Now().Unix() + 30
+	for cc.Running() {
+		select {
+		case <-cc.Done():
+		case <-tick.C:
+			if tt := time.Now().Unix(); nextDisp < tt {
+				cc.DumpStats()
+				rr.DumpStats()
+				nextDisp = tt + 30
+			}
+		}
+	}
+	cc.DumpStats()
+	rr.DumpStats()
+	log.Info("exit rewinder")
+}
+
+// serveRecord	answer retransmission request from recorded session file
+func serveRecord(recFile string, reqPort, soupPort int) {
+	rf, err := MoldUDP.OpenRecord(recFile)
+	if err != nil {
+		log.Error("OpenRecord", err)
+		os.Exit(1)
+	}
+	defer rf.Close()
+	log.Infof("Serve session %s up to seqNo %d from %s", rf.Session(),
+		rf.NextSeq(), recFile)
+	rr, err := MoldUDP.NewRewinder(rf.Session(), reqPort, rf)
+	if err != nil {
+		log.Error("NewRewinder", err)
+		os.Exit(1)
+	}
+	defer rr.Close()
+	go rr.Serve()
+	if soupPort != 0 {
+		ss, err := MoldUDP.NewSoupServer(rf.Session(), soupPort, rf)
+		if err != nil {
+			log.Error("NewSoupServer", err)
+			os.Exit(1)
+		}
+		defer ss.Close()
+		// recorded session complete
+		ss.EndSession()
+		go ss.Serve()
+	}
+	sigC := make(chan os.Signal, 10)
+	signal.Notify(sigC, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	log.Info("Quit", <-sigC)
+	rr.DumpStats()
+}
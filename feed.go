@@ -0,0 +1,261 @@
+package MoldUDP
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const arbRingSize = 1024
+
+// lineIdle	line without packets for lineIdle not waited for gap fill
+var lineIdle = 100 * time.Millisecond
+
+// Feed		one line of redundant multicast feeds, e.g. A or B line
+//	Name	name of line in stats, default A, B ...
+//	Addr	multicast group
+//	Port	UDP port
+//	IfName	if not blank, interface instead of Option.IfName
+//	Source	if not blank, SSM source instead of Option.Source
+//	Conn	McastConn to receive the line
+type Feed struct {
+	Name   string
+	Addr   string
+	Port   int
+	IfName string
+	Source string
+	Conn   McastConn
+}
+
+// LineStats	statistics of one feed line
+//	Recvs	packets received
+//	First	packets arrived before copy from other lines
+//	Late	packets arrived after copy from other line
+//	Dups	packets repeated on same line
+//	Lost	messages skipped in sequence of line
+//	MeanLag	mean delay of late packets behind first copy
+//	MaxLag	max delay of late packets behind first copy
+type LineStats struct {
+	Name    string
+	Recvs   int
+	First   int
+	Late    int
+	Dups    int
+	Lost    uint64
+	MeanLag time.Duration
+	MaxLag  time.Duration
+}
+
+type feedLine struct {
+	LineStats
+	conn     McastConn
+	dstIP    net.IP
+	port     int
+	seqNext  uint64
+	lastRecv time.Time
+	lagSum   time.Duration
+}
+
+// arbEntry	first copy of packet with seqNo among lines
+type arbEntry struct {
+	seqNo uint64
+	at    time.Time
+	line  *feedLine
+}
+
+// NewClientFeeds	Client of redundant feeds of same session, e.g. A/B
+//			lines on different interfaces or McastConn types, packets
+//			de-duplicated by sequence number with first copy taken,
+//			retransmission requested only for gap on all lines
+func NewClientFeeds(ctx context.Context, feeds []Feed, opt *Option, startOnFirst bool) (*Client, error) {
+	if len(feeds) == 0 {
+		return nil, errNoFeed
+	}
+	client := &Client{seqNo: opt.NextSeq}
+	if client.seqNo == 0 {
+		client.seqNo++
+	}
+	if startOnFirst {
+		client.startOnFirst = true
+	}
+	client.firstOnNew = startOnFirst
+	client.rollover = opt.Rollover
+	tt := time.Now()
+	for i := range feeds {
+		line, err := openFeed(&feeds[i], opt)
+		if err != nil {
+			client.closeConn()
+			return nil, err
+		}
+		if line.Name == "" {
+			line.Name = string(rune('A' + i))
+		}
+		line.lastRecv = tt
+		client.lines = append(client.lines, line)
+	}
+	client.dstIP = client.lines[0].dstIP
+	client.dstPort = client.lines[0].port
+	for _, daddr := range opt.Srvs {
+		client.reqSrv = append(client.reqSrv, reqSrvAddr(daddr, client.dstPort))
+	}
+	client.ch = make(chan msgBuf, 5000)
+	client.cache.Init()
+	client.readCond = sync.NewCond(&client.readLock)
+	client.ctx, client.cancel = context.WithCancel(ctx)
+	client.LastRecv = tt.Unix()
+	client.recovery = opt.Recovery
+	if opt.Recorder != nil {
+		client.recorder.Store(opt.Recorder)
+	}
+	client.startLateJoin(opt, opt.Recovery)
+	go client.waitDone()
+	go client.requestLoop()
+	for _, line := range client.lines {
+		go client.doMsgLoop(line)
+	}
+	return client, nil
+}
+
+// openFeed	open McastConn of feed, interface and source of opt as default
+func openFeed(feed *Feed, opt *Option) (*feedLine, error) {
+	line := feedLine{conn: feed.Conn, port: feed.Port}
+	line.Name = feed.Name
+	line.dstIP = net.ParseIP(feed.Addr)
+	if !line.dstIP.IsMulticast() {
+		log.Info(line.dstIP, "is not multicast IP")
+		line.dstIP = allNodes(line.dstIP)
+	}
+	ifName, source := opt.IfName, opt.Source
+	if feed.IfName != "" {
+		ifName = feed.IfName
+	}
+	if feed.Source != "" {
+		source = feed.Source
+	}
+	var ifn *net.Interface
+	if ifName != "" {
+		var err error
+		if ifn, err = net.InterfaceByName(ifName); err != nil {
+			log.Errorf("Ifn(%s) error: %v\n", ifName, err)
+			ifn = nil
+		}
+	}
+	var src net.IP
+	if source != "" {
+		if src = net.ParseIP(source); src == nil {
+			log.Errorf("Source(%s) not IP address", source)
+			return nil, errSource
+		}
+	}
+	if err := line.conn.Open(line.dstIP, line.port, src, ifn); err != nil {
+		log.Error("Open Multicast", err)
+		return nil, err
+	}
+	return &line, nil
+}
+
+// arbitrate	account packet of line, called with recvLock held
+//			true if copy of packet already supplied by any line
+func (c *Client) arbitrate(line *feedLine, head *Header, tt time.Time) bool {
+	line.Recvs++
+	line.lastRecv = tt
+	seqNo, seqNext := head.SeqNo, head.SeqNo
+	bData := head.MessageCnt != 0 && head.MessageCnt != 0xffff
+	if bData {
+		seqNext += uint64(head.MessageCnt)
+	}
+	bDup, bLate := false, false
+	if bData {
+		if c.arbRing == nil {
+			c.arbRing = make([]arbEntry, arbRingSize)
+		}
+		ent := &c.arbRing[seqNo%arbRingSize]
+		switch {
+		case ent.line == nil || ent.seqNo != seqNo:
+			*ent = arbEntry{seqNo: seqNo, at: tt, line: line}
+			line.First++
+		case ent.line == line:
+			line.Dups++
+			bDup = true
+		default:
+			bLate = true
+			lag := tt.Sub(ent.at)
+			line.Late++
+			line.lagSum += lag
+			if lag > line.MaxLag {
+				line.MaxLag = lag
+			}
+		}
+	}
+	switch {
+	case line.seqNext == 0:
+	case seqNo > line.seqNext:
+		line.Lost += seqNo - line.seqNext
+	case bData && !bDup && seqNo < line.seqNext:
+		// reordered on line, skipped messages arrived
+		n := seqNext - seqNo
+		if n > line.Lost {
+			n = line.Lost
+		}
+		line.Lost -= n
+	}
+	if seqNext > line.seqNext {
+		line.seqNext = seqNext
+	}
+	return bDup || bLate
+}
+
+// gapOnAll	all lines passed c.seqNo, idle lines ignored
+//		packet of c.seqNo received by any line still in queue is no gap
+func (c *Client) gapOnAll() bool {
+	c.recvLock.Lock()
+	defer c.recvLock.Unlock()
+	if c.arbRing != nil {
+		if ent := &c.arbRing[c.seqNo%arbRingSize]; ent.line != nil &&
+			ent.seqNo == c.seqNo {
+			return false
+		}
+	}
+	tt := time.Now()
+	for _, line := range c.lines {
+		if line.seqNext <= c.seqNo && tt.Sub(line.lastRecv) < lineIdle {
+			return false
+		}
+	}
+	return true
+}
+
+// LineStats	statistics of each feed line
+func (c *Client) LineStats() []LineStats {
+	c.recvLock.Lock()
+	defer c.recvLock.Unlock()
+	res := make([]LineStats, len(c.lines))
+	for i, line := range c.lines {
+		res[i] = line.LineStats
+		if line.Late > 0 {
+			res[i].MeanLag = line.lagSum / time.Duration(line.Late)
+		}
+	}
+	return res
+}
+
+// ConnStats	statistics of McastConn of each line
+func (c *Client) ConnStats() []ConnStats {
+	res := make([]ConnStats, len(c.lines))
+	for i, line := range c.lines {
+		res[i] = GetConnStats(line.conn)
+		res[i].Name = line.Name
+	}
+	return res
+}
+
+// addReqSrv	request server from source of multicast, if not given
+func (c *Client) addReqSrv(rAddr *net.UDPAddr, port int) {
+	c.recvLock.Lock()
+	if len(c.reqSrv) == 0 {
+		// request port diff from sending source port
+		c.reqSrv = append(c.reqSrv, net.UDPAddr{IP: rAddr.IP, Port: port})
+	}
+	c.recvLock.Unlock()
+}
@@ -0,0 +1,247 @@
+package MoldUDP
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestArbitrate(t *testing.T) {
+	c := newTestClient(1)
+	lineA, lineB := &feedLine{}, &feedLine{}
+	lineA.Name, lineB.Name = "A", "B"
+	c.lines = []*feedLine{lineA, lineB}
+	tt := time.Now()
+	steps := []struct {
+		line   *feedLine
+		seqNo  uint64
+		msgCnt uint16
+		delay  time.Duration
+	}{
+		{lineA, 1, 2, 0},
+		{lineB, 1, 2, 3 * time.Millisecond},
+		{lineA, 3, 2, 0},
+		{lineA, 3, 2, 0},
+		// B lost 3,4
+		{lineB, 5, 2, time.Millisecond},
+		{lineA, 5, 2, 0},
+		// A lost 7,8 then reordered
+		{lineA, 9, 1, 0},
+		{lineB, 7, 2, 0},
+		{lineA, 7, 2, time.Millisecond},
+		{lineB, 9, 1, 5 * time.Millisecond},
+		// heartbeat
+		{lineB, 10, 0, 0},
+	}
+	for _, st := range steps {
+		tt = tt.Add(st.delay)
+		c.arbitrate(st.line, &Header{SeqNo: st.seqNo, MessageCnt: st.msgCnt}, tt)
+	}
+	want := []LineStats{
+		{Name: "A", Recvs: 6, First: 3, Late: 2, Dups: 1, Lost: 0,
+			MeanLag: 500 * time.Microsecond, MaxLag: time.Millisecond},
+		{Name: "B", Recvs: 5, First: 2, Late: 2, Dups: 0, Lost: 2,
+			MeanLag: 4500 * time.Microsecond, MaxLag: 6 * time.Millisecond},
+	}
+	got := c.LineStats()
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %s stats %+v, want %+v", want[i].Name, got[i], want[i])
+		}
+	}
+	if lineA.seqNext != 10 || lineB.seqNext != 10 {
+		t.Errorf("seqNext A/B = %d/%d, want 10", lineA.seqNext, lineB.seqNext)
+	}
+}
+
+func TestGapOnAll(t *testing.T) {
+	c := newTestClient(10)
+	if !c.gapOnAll() {
+		t.Error("gapOnAll() without line false")
+	}
+	lineA := &feedLine{seqNext: 20, lastRecv: time.Now()}
+	lineB := &feedLine{seqNext: 10, lastRecv: time.Now()}
+	c.lines = []*feedLine{lineA, lineB}
+	if c.gapOnAll() {
+		t.Error("gapOnAll() true before B passed gap")
+	}
+	if c.newReq(20) != nil {
+		t.Error("newReq() before B passed gap")
+	}
+	lineB.seqNext = 12
+	if !c.gapOnAll() {
+		t.Error("gapOnAll() false after B passed gap")
+	}
+	c.arbitrate(lineB, &Header{SeqNo: 10, MessageCnt: 2}, time.Now())
+	if c.gapOnAll() {
+		t.Error("gapOnAll() true with packet of B queued")
+	}
+	c.arbRing = nil
+	lineB.seqNext = 10
+	lineB.lastRecv = time.Now().Add(-2 * lineIdle)
+	if !c.gapOnAll() {
+		t.Error("gapOnAll() false with B idle")
+	}
+	if c.newReq(20) == nil {
+		t.Error("newReq() nil with B idle")
+	}
+}
+
+func TestClientFeeds(t *testing.T) {
+	tests := []struct {
+		name     string
+		dropA    []uint64
+		dropB    []uint64
+		bRetrans bool
+		lostA    uint64
+		lostB    uint64
+		nFirst   int
+		groupB   string
+	}{
+		{"no loss", nil, nil, false, 0, 0, 10, "239.192.168.14"},
+		{"disjoint loss", []uint64{30, 50, 51}, []uint64{70, 150}, false, 40, 40, 10,
+			"239.192.168.14"},
+		{"common loss", []uint64{30, 90}, []uint64{90, 150}, true, 40, 40, 9,
+			"239.192.168.14"},
+		{"IPv6 line B", []uint64{30}, []uint64{50}, false, 20, 20, 10, "ff15::14"},
+	}
+	// slow scheduling not taken as idle line
+	defer func(idle time.Duration) { lineIdle = idle }(lineIdle)
+	lineIdle = 2 * time.Second
+	msgs := make([]Message, 200)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	for i, tt := range tests {
+		port := 6200 + 2*i
+		store := NewMemStore(1)
+		rr, err := NewRewinder("", 0, store)
+		if err != nil {
+			t.Fatal("NewRewinder", err)
+		}
+		served := make(chan struct{})
+		go func() {
+			rr.Serve()
+			close(served)
+		}()
+		srvA, err := NewServer("239.192.168.13", port, "feed0", &Option{},
+			NewIf("mem"), false)
+		if err != nil {
+			t.Fatal("NewServer", err)
+		}
+		srvA.SetStore(store)
+		srvB, err := NewServer(tt.groupB, port, "feed0", &Option{}, NewIf("mem"),
+			false)
+		if err != nil {
+			t.Fatal("NewServer", err)
+		}
+		feeds := []Feed{
+			{Addr: "239.192.168.13", Port: port,
+				Conn: NewMemIf(&MemOption{Drop: dropSeq(tt.dropA...)})},
+			{Addr: tt.groupB, Port: port,
+				Conn: NewMemIf(&MemOption{Drop: dropSeq(tt.dropB...)})},
+		}
+		opt := Option{Srvs: []string{fmt.Sprintf("127.0.0.1:%d", rr.LocalAddr().Port)}}
+		cc, err := NewClientFeeds(context.Background(), feeds, &opt, false)
+		if err != nil {
+			t.Fatal("NewClientFeeds", err)
+		}
+		go func() {
+			for i := 0; i < len(msgs); i += 20 {
+				srvA.Send(msgs[i : i+20])
+				srvB.Send(msgs[i : i+20])
+			}
+			srvA.Close()
+			srvB.Close()
+		}()
+		tm := time.AfterFunc(5*time.Second, cc.Stop)
+		seqNo := uint64(1)
+		err = cc.Subscribe(func(sn uint64, msg Message) {
+			if sn != seqNo || string(msg.Data) != string(msgs[seqNo-1].Data) {
+				t.Errorf("%s: message %d: %s, want %d", tt.name, sn, msg.Data, seqNo)
+			}
+			seqNo++
+		})
+		tm.Stop()
+		if err != ErrEndOfSession || seqNo != uint64(len(msgs))+1 {
+			t.Errorf("%s: Subscribe() %v, got %d messages", tt.name, err, seqNo-1)
+		}
+		stats := cc.LineStats()
+		cc.Close()
+		rr.Close()
+		<-served
+		if (rr.nRetrans != 0) != tt.bRetrans {
+			t.Errorf("%s: %d retransmission", tt.name, rr.nRetrans)
+		}
+		if len(stats) != 2 || stats[0].Name != "A" || stats[1].Name != "B" {
+			t.Fatalf("%s: LineStats() = %+v", tt.name, stats)
+		}
+		if stats[0].Lost != tt.lostA || stats[1].Lost != tt.lostB {
+			t.Errorf("%s: lost A/B %d/%d, want %d/%d", tt.name, stats[0].Lost,
+				stats[1].Lost, tt.lostA, tt.lostB)
+		}
+		if n := stats[0].First + stats[1].First; n != tt.nFirst {
+			t.Errorf("%s: %d packets first, want %d", tt.name, n, tt.nFirst)
+		}
+	}
+}
+
+func TestClientDupLines(t *testing.T) {
+	msgs := make([]Message, 40)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	pkts := make([]Packet, 4)
+	for i := range pkts {
+		pkts[i] = buildPacket("test0", uint64(10*i+1), 0, msgs[10*i:10*i+10])
+	}
+	c := newTestClient(1)
+	lineA, lineB := &feedLine{}, &feedLine{}
+	lineA.Name, lineB.Name = "A", "B"
+	c.lines = []*feedLine{lineA, lineB}
+	c.ch = make(chan msgBuf, 8)
+	drain := func() {
+		for len(c.ch) > 0 {
+			msgBB := <-c.ch
+			c.doMsg(&msgBB)
+		}
+	}
+	// empty slice ready wakes up Read for nothing
+	noEmpty := func(step string) {
+		c.readLock.Lock()
+		defer c.readLock.Unlock()
+		if c.ready != nil && len(c.ready) == 0 {
+			t.Errorf("%s: empty slice ready to Read", step)
+		}
+	}
+	for i, pkt := range pkts {
+		first, second := lineA, lineB
+		if i%2 == 1 {
+			first, second = lineB, lineA
+		}
+		c.gotBuff(pkt, len(pkt), first)
+		drain()
+		// identical copy after first delivered, ends at c.seqNo
+		c.gotBuff(pkt, len(pkt), second)
+		if n := len(c.ch); n != 0 {
+			t.Errorf("packet %d: copy of line %s queued", i, second.Name)
+		}
+		drain()
+		noEmpty(fmt.Sprintf("packet %d", i))
+		res, seqNo, err := c.Read()
+		if err != nil || seqNo != uint64(10*i+1) || len(res) != 10 {
+			t.Fatalf("packet %d: Read() %d messages from %d, %v", i, len(res),
+				seqNo, err)
+		}
+		// slice read recycled as spare of next ready
+		c.Release(seqNo + uint64(len(res)))
+	}
+	// retransmission of packet ending at c.seqNo, not arbitrated
+	c.gotBuff(pkts[3], len(pkts[3]), nil)
+	drain()
+	noEmpty("retransmission")
+	if st := c.Stats(); st.Repeats != int64(len(pkts))+1 || st.Messages != 40 {
+		t.Errorf("repeats %d, messages %d", st.Repeats, st.Messages)
+	}
+}
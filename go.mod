@@ -1,7 +1,14 @@
 module github.com/kjx98/go-mold
 
+go 1.21
+
 require (
 	github.com/kjx98/go-ats v0.1.2
 	github.com/kjx98/golib v0.1.4
 	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7
 )
+
+require (
+	github.com/go-sql-driver/mysql v1.4.1 // indirect
+	github.com/kjx98/avl v0.1.2 // indirect
+)
@@ -0,0 +1,318 @@
+package MoldUDP
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	impairQueueLen = 4096
+	// reorderHold	packet reordered delivered anyway if no later packet
+	//				received for so long
+	reorderHold = 20 * time.Millisecond
+)
+
+// Impairment	network impairment on packets received
+//	Drop	probability of packet loss, start of loss burst
+//	Burst	mean length of loss burst, 0 or 1 for independent loss
+//	Reorder	probability of packet delivered after later packets, at most
+//			Window packets held, held packet delivered after reorderHold
+//			if no later packet
+//	Window	max number of later packets delivered before, default 1
+//	Dup		probability of packet delivered twice
+//	Delay	delay of each packet delivered
+//	Jitter	max random delay of packet delivered, order kept
+//	Seed	seed of random, same seed for same impairment
+type Impairment struct {
+	Drop    float64
+	Burst   float64
+	Reorder float64
+	Window  int
+	Dup     float64
+	Delay   time.Duration
+	Jitter  time.Duration
+	Seed    int64
+}
+
+// Enabled	any impairment configured
+func (imp *Impairment) Enabled() bool {
+	return imp.Drop > 0 || imp.Reorder > 0 || imp.Dup > 0 || imp.Delay > 0 ||
+		imp.Jitter > 0
+}
+
+type impPkt struct {
+	pkt    Packet
+	rAddr  *net.UDPAddr
+	at     time.Time
+	after  int
+	heldAt time.Time
+}
+
+// impairIf	McastConn wrap conn, impair packets received via
+//			Recv/MRecv/Listen, Send/MSend untouched
+type impairIf struct {
+	conn     McastConn
+	imp      Impairment
+	rnd      *rand.Rand
+	bLoss    bool
+	held     []impPkt
+	rx       chan impPkt
+	done     chan struct{}
+	bRead    bool
+	closed   int32
+	lock     sync.Mutex
+	buffs    [maxBatch]Packet
+	nRecvs   int
+	nDrops   int
+	nReorder int
+	nDups    int
+}
+
+// NewImpairIf	wrap conn with impairment imp on packets received
+func NewImpairIf(conn McastConn, imp *Impairment) McastConn {
+	c := impairIf{conn: conn, imp: *imp}
+	if c.imp.Window <= 0 {
+		c.imp.Window = 1
+	}
+	c.rnd = rand.New(rand.NewSource(c.imp.Seed))
+	return &c
+}
+
+func (c *impairIf) Enabled(opts int) bool {
+	return c.conn.Enabled(opts)
+}
+
+func (c *impairIf) String() string {
+	return fmt.Sprintf("%v with impairment %+v", c.conn, c.imp)
+}
+
+// connStats	drops of conn and impairment
+func (c *impairIf) connStats() ConnStats {
+	res := GetConnStats(c.conn)
+	c.lock.Lock()
+	res.Drops += uint64(c.nDrops)
+	c.lock.Unlock()
+	return res
+}
+
+func (c *impairIf) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) && c.done != nil {
+		close(c.done)
+	}
+	return c.conn.Close()
+}
+
+func (c *impairIf) Open(ip net.IP, port int, src net.IP, ifn *net.Interface) error {
+	if err := c.conn.Open(ip, port, src, ifn); err != nil {
+		return err
+	}
+	c.bRead = true
+	c.rx = make(chan impPkt, impairQueueLen)
+	c.done = make(chan struct{})
+	go c.pump()
+	return nil
+}
+
+func (c *impairIf) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface) error {
+	return c.conn.OpenSend(ip, port, bLoop, ifn)
+}
+
+func (c *impairIf) Send(buff []byte) (int, error) {
+	return c.conn.Send(buff)
+}
+
+func (c *impairIf) MSend(buffs []Packet) (int, error) {
+	return c.conn.MSend(buffs)
+}
+
+// pump		recv from conn via same method Client would use
+func (c *impairIf) pump() {
+	if c.conn.Enabled(HasRingBuffer) {
+		c.conn.Listen(c.impair)
+		return
+	}
+	if c.conn.Enabled(HasMmsg) {
+		for atomic.LoadInt32(&c.closed) == 0 {
+			bufs, rAddr, err := c.conn.MRecv()
+			if err == errClosed {
+				return
+			} else if err != nil {
+				continue
+			}
+			for _, buf := range bufs {
+				c.impair(buf, rAddr)
+			}
+		}
+		return
+	}
+	buff := make([]byte, maxDatagramSize)
+	for atomic.LoadInt32(&c.closed) == 0 {
+		n, rAddr, err := c.conn.Recv(buff)
+		if err == errClosed {
+			return
+		} else if err != nil {
+			continue
+		}
+		c.impair(buff[:n], rAddr)
+	}
+}
+
+// loss		Gilbert model, stay in loss burst with probability 1-1/Burst
+func (c *impairIf) loss() bool {
+	if c.bLoss && c.imp.Burst > 1 {
+		c.bLoss = c.rnd.Float64() >= 1/c.imp.Burst
+	} else {
+		c.bLoss = c.imp.Drop > 0 && c.rnd.Float64() < c.imp.Drop
+	}
+	return c.bLoss
+}
+
+// impair	apply impairment to packet received, queue packets to deliver
+func (c *impairIf) impair(buff []byte, rAddr *net.UDPAddr) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.nRecvs++
+	if c.loss() {
+		c.nDrops++
+		return
+	}
+	ip := impPkt{pkt: append(Packet{}, buff...)}
+	if rAddr != nil {
+		adr := *rAddr
+		ip.rAddr = &adr
+	}
+	if c.imp.Delay > 0 || c.imp.Jitter > 0 {
+		ip.at = time.Now().Add(c.imp.Delay)
+		if c.imp.Jitter > 0 {
+			ip.at = ip.at.Add(time.Duration(c.rnd.Int63n(int64(c.imp.Jitter))))
+		}
+	}
+	if len(c.held) < c.imp.Window && c.imp.Reorder > 0 &&
+		c.rnd.Float64() < c.imp.Reorder {
+		c.nReorder++
+		ip.after = 1 + c.rnd.Intn(c.imp.Window)
+		ip.heldAt = time.Now()
+		c.held = append(c.held, ip)
+		time.AfterFunc(reorderHold, c.releaseHeld)
+		return
+	}
+	c.queue(ip)
+	if c.imp.Dup > 0 && c.rnd.Float64() < c.imp.Dup {
+		c.nDups++
+		c.queue(ip)
+	}
+	// release held packets after enough later packets delivered
+	held := c.held[:0]
+	for _, hp := range c.held {
+		if hp.after--; hp.after <= 0 {
+			c.queue(hp)
+		} else {
+			held = append(held, hp)
+		}
+	}
+	c.held = held
+}
+
+// releaseHeld	deliver packets held for reorderHold, no later packet
+//				received to release them
+func (c *impairIf) releaseHeld() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	held := c.held[:0]
+	for _, hp := range c.held {
+		if time.Since(hp.heldAt) >= reorderHold {
+			c.queue(hp)
+		} else {
+			held = append(held, hp)
+		}
+	}
+	c.held = held
+}
+
+func (c *impairIf) queue(ip impPkt) {
+	select {
+	case c.rx <- ip:
+	default:
+		c.nDrops++
+	}
+}
+
+// wait		wait for deliver time of packet with jitter
+func (c *impairIf) wait(ip *impPkt) bool {
+	if d := time.Until(ip.at); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-c.done:
+			return false
+		}
+	}
+	return true
+}
+
+func (c *impairIf) Recv(buff []byte) (int, *net.UDPAddr, error) {
+	if !c.bRead {
+		return 0, nil, errModeRW
+	}
+	select {
+	case ip := <-c.rx:
+		if !c.wait(&ip) {
+			return 0, nil, errClosed
+		}
+		return copy(buff, ip.pkt), ip.rAddr, nil
+	case <-c.done:
+		return 0, nil, errClosed
+	}
+}
+
+// MRecv	packets ready, source address of first packet, single reader
+func (c *impairIf) MRecv() ([]Packet, *net.UDPAddr, error) {
+	if !c.bRead {
+		return nil, nil, errModeRW
+	}
+	var ip impPkt
+	select {
+	case ip = <-c.rx:
+	case <-c.done:
+		return nil, nil, errClosed
+	}
+	if !c.wait(&ip) {
+		return nil, nil, errClosed
+	}
+	rAddr := ip.rAddr
+	c.buffs[0] = ip.pkt
+	n := 1
+	for ; n < maxBatch && len(c.rx) > 0; n++ {
+		ip = <-c.rx
+		if !c.wait(&ip) {
+			return nil, nil, errClosed
+		}
+		c.buffs[n] = ip.pkt
+	}
+	return c.buffs[:n], rAddr, nil
+}
+
+func (c *impairIf) Listen(fx func([]byte, *net.UDPAddr)) {
+	for {
+		select {
+		case ip := <-c.rx:
+			if !c.wait(&ip) {
+				return
+			}
+			fx(ip.pkt, ip.rAddr)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// DumpStats	log counts of impairment
+func (c *impairIf) DumpStats() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	log.Infof("Impairment Recv: %d, drop: %d, reorder: %d, dup: %d", c.nRecvs,
+		c.nDrops, c.nReorder, c.nDups)
+}
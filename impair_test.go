@@ -0,0 +1,160 @@
+package MoldUDP
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestImpairIf(t *testing.T) {
+	tests := []struct {
+		name string
+		imp  Impairment
+		want []string
+	}{
+		{"none", Impairment{}, []string{"0", "1", "2", "3", "4", "5"}},
+		{"drop", Impairment{Drop: 1}, nil},
+		{"dup", Impairment{Dup: 1},
+			[]string{"0", "0", "1", "1", "2", "2", "3", "3", "4", "4", "5", "5"}},
+		{"reorder", Impairment{Reorder: 0.5, Window: 3, Seed: 1}, nil},
+		{"jitter", Impairment{Jitter: 10 * time.Millisecond},
+			[]string{"0", "1", "2", "3", "4", "5"}},
+	}
+	for _, tt := range tests {
+		inner := newChanConn()
+		conn := NewImpairIf(inner, &tt.imp)
+		if err := conn.Open(net.IPv4(239, 192, 168, 1), 5858, nil, nil); err != nil {
+			t.Fatal("Open", err)
+		}
+		for i := 0; i < 6; i++ {
+			inner.rx <- Packet(fmt.Sprint(i))
+		}
+		// wait all packets impaired
+		for len(inner.rx) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(5 * time.Millisecond)
+		c := conn.(*impairIf)
+		c.lock.Lock()
+		nQueued, nHeld := len(c.rx), len(c.held)
+		c.lock.Unlock()
+		got := memRecvAll(t, conn, nQueued)
+		if tt.want != nil || nQueued == 0 {
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			}
+		} else if len(got) != 6-nHeld || nHeld == 6 {
+			t.Errorf("%s: got %v, %d held", tt.name, got, nHeld)
+		} else if fmt.Sprint(got) == "[0 1 2 3 4 5]" {
+			t.Errorf("%s: packets not reordered", tt.name)
+		}
+		conn.Close()
+		if _, _, err := conn.Recv(make([]byte, 16)); err != errClosed {
+			t.Errorf("%s: Recv() after Close error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestImpairBurst(t *testing.T) {
+	const nPkts = 100000
+	for _, burst := range []float64{0, 4} {
+		c := NewImpairIf(newChanConn(), &Impairment{Drop: 0.01, Burst: burst,
+			Seed: 1}).(*impairIf)
+		nLoss, nBurst := 0, 0
+		bLoss := false
+		for i := 0; i < nPkts; i++ {
+			loss := c.loss()
+			if loss {
+				nLoss++
+				if !bLoss {
+					nBurst++
+				}
+			}
+			bLoss = loss
+		}
+		mean := float64(nLoss) / float64(nBurst)
+		if burst <= 1 {
+			burst = 1
+		}
+		if mean < burst*0.8 || mean > burst*1.2 {
+			t.Errorf("burst %v: mean burst length %.2f", burst, mean)
+		}
+		if rate := float64(nBurst) / nPkts; rate < 0.008 || rate > 0.012 {
+			t.Errorf("burst %v: burst start rate %.4f, want about 0.01", burst, rate)
+		}
+	}
+}
+
+func TestImpairGapFill(t *testing.T) {
+	msgs := make([]Message, 1000)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	store := NewMemStore(1)
+	rr, err := NewRewinder("", 0, store)
+	if err != nil {
+		t.Fatal("NewRewinder", err)
+	}
+	defer rr.Close()
+	go rr.Serve()
+	srv, err := NewServer("239.192.168.12", 6100, "imp0", &Option{}, NewIf("mem"), false)
+	if err != nil {
+		t.Fatal("NewServer", err)
+	}
+	srv.SetStore(store)
+	imp := Impairment{Drop: 0.05, Burst: 3, Reorder: 0.05, Window: 4, Dup: 0.05,
+		Jitter: time.Millisecond, Seed: 7}
+	opt := Option{Srvs: []string{fmt.Sprintf("127.0.0.1:%d", rr.LocalAddr().Port)}}
+	cc, err := NewClient("239.192.168.12", 6100, &opt,
+		NewImpairIf(NewIf("mem"), &imp), false)
+	if err != nil {
+		t.Fatal("NewClient", err)
+	}
+	defer cc.Close()
+	go func() {
+		for i := 0; i < len(msgs); i += 10 {
+			srv.Send(msgs[i : i+10])
+		}
+		// heartbeat in case all End-of-Session packets lost
+		srv.StartHeartbeat(10 * time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		srv.Close()
+	}()
+	tm := time.AfterFunc(10*time.Second, cc.Stop)
+	defer tm.Stop()
+	seqNo := uint64(1)
+	err = cc.Subscribe(func(sn uint64, msg Message) {
+		if sn != seqNo || string(msg.Data) != string(msgs[seqNo-1].Data) {
+			t.Errorf("message %d: %s, want %d", sn, msg.Data, seqNo)
+		}
+		seqNo++
+	})
+	if err != ErrEndOfSession || seqNo != uint64(len(msgs))+1 {
+		t.Errorf("Subscribe() %v, got %d messages, want %d", err, seqNo-1, len(msgs))
+	}
+}
+
+func TestImpairHeldRelease(t *testing.T) {
+	inner := newChanConn()
+	conn := NewImpairIf(inner, &Impairment{Reorder: 1})
+	if err := conn.Open(net.IPv4(239, 192, 168, 1), 5858, nil, nil); err != nil {
+		t.Fatal("Open", err)
+	}
+	defer conn.Close()
+	// last packet held, no later packet to release it
+	inner.rx <- Packet("0")
+	tm := time.Now()
+	if got := memRecvAll(t, conn, 1); got[0] != "0" {
+		t.Errorf("Recv() = %v", got)
+	}
+	if d := time.Since(tm); d < reorderHold {
+		t.Errorf("held packet delivered after %v, before %v", d, reorderHold)
+	}
+	c := conn.(*impairIf)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.nReorder != 1 || len(c.held) != 0 {
+		t.Errorf("%d packets reordered, %d held", c.nReorder, len(c.held))
+	}
+}
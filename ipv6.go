@@ -0,0 +1,132 @@
+package MoldUDP
+
+import (
+	"fmt"
+	"net"
+)
+
+const (
+	ip6HeadSize = 40
+	ethIPv6     = 0x86dd
+	protoUDP    = 17
+)
+
+// SockaddrInet6	IPv6 socket address, ZoneId as interface index
+type SockaddrInet6 struct {
+	Port   int
+	ZoneId uint32
+	Addr   [16]byte
+}
+
+func (adr *SockaddrInet6) IP() string {
+	return net.IP(adr.Addr[:]).String()
+}
+
+func (adr *SockaddrInet6) String() string {
+	return fmt.Sprintf("[%s]:%d", adr.IP(), adr.Port)
+}
+
+func (adr *SockaddrInet6) UDPAddr() *net.UDPAddr {
+	rAddr := net.UDPAddr{IP: make(net.IP, net.IPv6len), Port: adr.Port}
+	copy(rAddr.IP, adr.Addr[:])
+	return &rAddr
+}
+
+// isIPv6	ip is IPv6 address, not IPv4 mapped
+func isIPv6(ip net.IP) bool {
+	return len(ip) == net.IPv6len && ip.To4() == nil
+}
+
+// allNodes	all nodes multicast group of same family as ip
+func allNodes(ip net.IP) net.IP {
+	if isIPv6(ip) {
+		return net.IPv6linklocalallnodes
+	}
+	return net.IPv4(224, 0, 0, 1)
+}
+
+// getIfAddr6	first IPv6 address of ifn, link local preferred
+func getIfAddr6(ifn *net.Interface) (net.IP, error) {
+	ret := net.IPv6unspecified
+	if ifn == nil {
+		return ret, nil
+	}
+	addrs, err := ifn.Addrs()
+	if err != nil {
+		log.Info("Get if Addr", err)
+		return ret, err
+	}
+	for _, adr := range addrs {
+		ipn, ok := adr.(*net.IPNet)
+		if !ok || !isIPv6(ipn.IP) {
+			continue
+		}
+		if ipn.IP.IsLinkLocalUnicast() {
+			return ipn.IP, nil
+		}
+		if ret.IsUnspecified() {
+			ret = ipn.IP
+		}
+	}
+	if ret.IsUnspecified() {
+		log.Infof("No IPv6 addrs in if(%s)", ifn.Name)
+		return ret, errNoIP
+	}
+	return ret, nil
+}
+
+// GetMulticastHWAddr	ethernet multicast address of group
+//	IPv4	01:00:5e + low 23 bits of group
+//	IPv6	33:33 + low 32 bits of group
+func GetMulticastHWAddr(adr net.IP) HardwareAddr {
+	if ip4 := adr.To4(); ip4 != nil {
+		return HardwareAddr{1, 0, 0x5e, ip4[1] & 0x7f, ip4[2], ip4[3]}
+	}
+	if ip6 := adr.To16(); ip6 != nil {
+		return HardwareAddr{0x33, 0x33, ip6[12], ip6[13], ip6[14], ip6[15]}
+	}
+	return nil
+}
+
+// buildRawUDP6	build ethernet type, IPv6 and UDP headers at buff[12:]
+//				copy pay after headers with UDP checksum,
+//	return		frame length
+func buildRawUDP6(buff []byte, pay []byte, port int, src, dst []byte) int {
+	udpLen := len(pay) + 8
+	coder.PutUint16(buff[12:], ethIPv6)
+	ip := buff[14 : 14+ip6HeadSize]
+	// version 6, traffic class and flow label 0
+	ip[0], ip[1], ip[2], ip[3] = 0x60, 0, 0, 0
+	coder.PutUint16(ip[4:], uint16(udpLen))
+	ip[6] = protoUDP
+	// hop limit, same as IPv4 ttl of buildIP
+	ip[7] = 2
+	copy(ip[8:24], src)
+	copy(ip[24:40], dst)
+	udp := buff[14+ip6HeadSize : 14+ip6HeadSize+udpLen]
+	coder.PutUint16(udp, uint16(port+1))
+	coder.PutUint16(udp[2:], uint16(port))
+	coder.PutUint16(udp[4:], uint16(udpLen))
+	coder.PutUint16(udp[6:], 0)
+	copy(udp[8:], pay)
+	// checksum mandatory for IPv6
+	coder.PutUint16(udp[6:], udpChecksum(udp, src, dst))
+	return 14 + ip6HeadSize + udpLen
+}
+
+// ip6UDP	UDP packet of IPv6 packet ip, nil if not UDP or corrupt
+//			extension headers not supported
+func ip6UDP(ip []byte) []byte {
+	if len(ip) < ip6HeadSize+8 || ip[0]>>4 != 6 || ip[6] != protoUDP {
+		return nil
+	}
+	pLen := int(coder.Uint16(ip[4:]))
+	if pLen < 8 || ip6HeadSize+pLen > len(ip) {
+		return nil
+	}
+	udp := ip[ip6HeadSize : ip6HeadSize+pLen]
+	if uLen := int(coder.Uint16(udp[4:])); uLen >= 8 && uLen < pLen {
+		udp = udp[:uLen]
+	}
+	return udp
+}
@@ -0,0 +1,171 @@
+package MoldUDP
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetMulticastHWAddr(t *testing.T) {
+	tests := []struct {
+		ip   net.IP
+		want string
+	}{
+		{net.IPv4(239, 192, 168, 1), "01:00:5e:40:a8:01"},
+		{net.IPv4(224, 128, 0, 251), "01:00:5e:00:00:fb"},
+		{net.ParseIP("ff02::1"), "33:33:00:00:00:01"},
+		{net.ParseIP("ff15::1:ff12:3456"), "33:33:ff:12:34:56"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		got := GetMulticastHWAddr(tt.ip)
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("GetMulticastHWAddr(%v) = %v, want nil", tt.ip, got)
+			}
+		} else if got == nil || got.String() != tt.want {
+			t.Errorf("GetMulticastHWAddr(%v) = %v, want %s", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestAllNodes(t *testing.T) {
+	if ip := allNodes(net.ParseIP("192.168.0.1")); !ip.Equal(net.IPv4(224, 0, 0, 1)) {
+		t.Errorf("allNodes(IPv4) = %v", ip)
+	}
+	if ip := allNodes(net.ParseIP("fe80::1")); !ip.Equal(net.ParseIP("ff02::1")) {
+		t.Errorf("allNodes(IPv6) = %v", ip)
+	}
+	if ip := allNodes(nil); !ip.Equal(net.IPv4(224, 0, 0, 1)) {
+		t.Errorf("allNodes(nil) = %v", ip)
+	}
+}
+
+func TestBuildRawUDP6(t *testing.T) {
+	src := net.ParseIP("fe80::1")
+	dst := net.ParseIP("ff15::1234")
+	pay := []byte("hello")
+	buff := make([]byte, 128)
+	n := buildRawUDP6(buff, pay, 5858, src, dst)
+	if n != 14+ip6HeadSize+8+len(pay) {
+		t.Fatalf("buildRawUDP6() = %d", n)
+	}
+	if ether := coder.Uint16(buff[12:]); ether != ethIPv6 {
+		t.Errorf("ethernet type %x", ether)
+	}
+	ip := buff[14:n]
+	udp := ip6UDP(ip)
+	if udp == nil {
+		t.Fatal("ip6UDP() nil")
+	}
+	if !net.IP(ip[8:24]).Equal(src) || !net.IP(ip[24:40]).Equal(dst) {
+		t.Errorf("IPv6 address %v -> %v", net.IP(ip[8:24]), net.IP(ip[24:40]))
+	}
+	if sp, dp := coder.Uint16(udp), coder.Uint16(udp[2:]); sp != 5859 || dp != 5858 {
+		t.Errorf("UDP port %d -> %d", sp, dp)
+	}
+	if string(udp[8:]) != string(pay) {
+		t.Errorf("UDP payload %q", udp[8:])
+	}
+	// checksum calculated by hand
+	if ck := coder.Uint16(udp[6:]); ck != 0x7e71 {
+		t.Errorf("UDP checksum %x, want 7e71", ck)
+	}
+	if ip6UDP(ip[:ip6HeadSize+4]) != nil {
+		t.Error("ip6UDP() accept truncated packet")
+	}
+	ip[6] = 6
+	if ip6UDP(ip) != nil {
+		t.Error("ip6UDP() accept TCP packet")
+	}
+}
+
+func TestPcapIPv6(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ipv6.pcap")
+	pw, err := NewPcapWriter(fileName)
+	if err != nil {
+		t.Fatal("NewPcapWriter", err)
+	}
+	group := net.ParseIP("ff15::1234")
+	src := &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 5859}
+	for i := 0; i < 5; i++ {
+		dst := &net.UDPAddr{IP: group, Port: 5858}
+		switch i {
+		case 1:
+			dst.IP = net.ParseIP("ff15::1235")
+		case 3:
+			dst.Port = 5860
+		}
+		if err := pw.WritePacket([]byte(fmt.Sprint(i)), src, dst,
+			time.Now()); err != nil {
+			t.Fatal("WritePacket", err)
+		}
+	}
+	// IPv4 packet to same port filtered
+	pw.WritePacket([]byte("v4"), &net.UDPAddr{IP: pcapSrcIP, Port: 5859},
+		&net.UDPAddr{IP: pcapDstIP, Port: 5858}, time.Now())
+	pw.Close()
+
+	conn := NewPcapIf(fileName)
+	if err := conn.Open(group, 5858, nil, nil); err != nil {
+		t.Fatal("Open", err)
+	}
+	defer conn.Close()
+	var got []string
+	conn.Listen(func(buff []byte, rAddr *net.UDPAddr) {
+		if !rAddr.IP.Equal(src.IP) || rAddr.Port != src.Port {
+			t.Errorf("packet from %v, want %v", rAddr, src)
+		}
+		got = append(got, string(buff))
+	})
+	if fmt.Sprint(got) != "[0 2 4]" {
+		t.Errorf("got %v, want [0 2 4]", got)
+	}
+}
+
+func TestMcastIPv6(t *testing.T) {
+	group := net.ParseIP("ff12::4d4f:4c44")
+	ifn, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no loopback interface", err)
+	}
+	for i, name := range []string{"net", "sock"} {
+		port := 5870 + 2*i
+		rConn := NewIf(name)
+		if err := rConn.Open(group, port, nil, ifn); err != nil {
+			t.Logf("%s: IPv6 multicast not available: %v", name, err)
+			continue
+		}
+		sConn := NewIf(name)
+		if err := sConn.OpenSend(group, port, true, ifn); err != nil {
+			t.Errorf("%s: OpenSend() %v", name, err)
+			rConn.Close()
+			continue
+		}
+		if _, err := sConn.Send([]byte("hello")); err != nil {
+			t.Logf("%s: IPv6 multicast send: %v", name, err)
+			rConn.Close()
+			sConn.Close()
+			continue
+		}
+		res := make(chan string, 1)
+		go func() {
+			buff := make([]byte, 64)
+			if n, _, err := rConn.Recv(buff); err == nil {
+				res <- string(buff[:n])
+			}
+		}()
+		select {
+		case s := <-res:
+			if s != "hello" {
+				t.Errorf("%s: Recv() = %q", name, s)
+			}
+		case <-time.After(time.Second):
+			t.Logf("%s: no IPv6 multicast loopback", name)
+		}
+		rConn.Close()
+		sConn.Close()
+	}
+}
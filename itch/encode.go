@@ -0,0 +1,300 @@
+package itch
+
+func putUint48(b []byte, v uint64) {
+	b[0] = byte(v >> 40)
+	b[1] = byte(v >> 32)
+	b[2] = byte(v >> 24)
+	b[3] = byte(v >> 16)
+	b[4] = byte(v >> 8)
+	b[5] = byte(v)
+}
+
+func (h *Header) encode(b []byte, msgType byte, size int) error {
+	if len(b) < size {
+		return ErrTooShort
+	}
+	b[0] = msgType
+	coder.PutUint16(b[1:3], h.StockLocate)
+	coder.PutUint16(b[3:5], h.TrackingNumber)
+	putUint48(b[5:11], h.Timestamp)
+	return nil
+}
+
+// Encoder	ITCH message could be encoded, round trip with Decode
+type Encoder interface {
+	Message
+	// Encode	encode message to buf, return message length
+	Encode(buf []byte) (int, error)
+}
+
+// Bytes	encode message to new allocated buffer
+func Bytes(m Encoder) []byte {
+	var buff [64]byte
+	n, err := m.Encode(buff[:])
+	if err != nil {
+		return nil
+	}
+	return append([]byte{}, buff[:n]...)
+}
+
+func (m *SystemEvent) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'S', SystemEventLen); err != nil {
+		return 0, err
+	}
+	b[11] = m.EventCode
+	return SystemEventLen, nil
+}
+
+func (m *StockDirectory) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'R', StockDirectoryLen); err != nil {
+		return 0, err
+	}
+	copy(b[11:19], m.Stock[:])
+	b[19] = m.MarketCategory
+	b[20] = m.FinancialStatusIndicator
+	coder.PutUint32(b[21:25], m.RoundLotSize)
+	b[25] = m.RoundLotsOnly
+	b[26] = m.IssueClassification
+	copy(b[27:29], m.IssueSubType[:])
+	b[29] = m.Authenticity
+	b[30] = m.ShortSaleThresholdIndicator
+	b[31] = m.IPOFlag
+	b[32] = m.LULDReferencePriceTier
+	b[33] = m.ETPFlag
+	coder.PutUint32(b[34:38], m.ETPLeverageFactor)
+	b[38] = m.InverseIndicator
+	return StockDirectoryLen, nil
+}
+
+func (m *StockTradingAction) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'H', StockTradingActionLen); err != nil {
+		return 0, err
+	}
+	copy(b[11:19], m.Stock[:])
+	b[19] = m.TradingState
+	b[20] = m.Reserved
+	copy(b[21:25], m.Reason[:])
+	return StockTradingActionLen, nil
+}
+
+func (m *RegSHORestriction) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'Y', RegSHORestrictionLen); err != nil {
+		return 0, err
+	}
+	copy(b[11:19], m.Stock[:])
+	b[19] = m.RegSHOAction
+	return RegSHORestrictionLen, nil
+}
+
+func (m *MarketParticipantPosition) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'L', MarketParticipantPositionLen); err != nil {
+		return 0, err
+	}
+	copy(b[11:15], m.MPID[:])
+	copy(b[15:23], m.Stock[:])
+	b[23] = m.PrimaryMarketMaker
+	b[24] = m.MarketMakerMode
+	b[25] = m.MarketParticipantState
+	return MarketParticipantPositionLen, nil
+}
+
+func (m *MWCBDeclineLevel) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'V', MWCBDeclineLevelLen); err != nil {
+		return 0, err
+	}
+	coder.PutUint64(b[11:19], m.Level1)
+	coder.PutUint64(b[19:27], m.Level2)
+	coder.PutUint64(b[27:35], m.Level3)
+	return MWCBDeclineLevelLen, nil
+}
+
+func (m *MWCBStatus) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'W', MWCBStatusLen); err != nil {
+		return 0, err
+	}
+	b[11] = m.BreachedLevel
+	return MWCBStatusLen, nil
+}
+
+func (m *IPOQuotingPeriod) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'K', IPOQuotingPeriodLen); err != nil {
+		return 0, err
+	}
+	copy(b[11:19], m.Stock[:])
+	coder.PutUint32(b[19:23], m.IPOQuotationReleaseTime)
+	b[23] = m.IPOQuotationReleaseQualifier
+	coder.PutUint32(b[24:28], m.IPOPrice)
+	return IPOQuotingPeriodLen, nil
+}
+
+func (m *LULDAuctionCollar) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'J', LULDAuctionCollarLen); err != nil {
+		return 0, err
+	}
+	copy(b[11:19], m.Stock[:])
+	coder.PutUint32(b[19:23], m.AuctionCollarReferencePrice)
+	coder.PutUint32(b[23:27], m.UpperAuctionCollarPrice)
+	coder.PutUint32(b[27:31], m.LowerAuctionCollarPrice)
+	coder.PutUint32(b[31:35], m.AuctionCollarExtension)
+	return LULDAuctionCollarLen, nil
+}
+
+func (m *OperationalHalt) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'h', OperationalHaltLen); err != nil {
+		return 0, err
+	}
+	copy(b[11:19], m.Stock[:])
+	b[19] = m.MarketCode
+	b[20] = m.OperationalHaltAction
+	return OperationalHaltLen, nil
+}
+
+func (m *AddOrder) encode(b []byte) {
+	coder.PutUint64(b[11:19], m.OrderRef)
+	b[19] = m.BuySell
+	coder.PutUint32(b[20:24], m.Shares)
+	copy(b[24:32], m.Stock[:])
+	coder.PutUint32(b[32:36], m.Price)
+}
+
+func (m *AddOrder) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'A', AddOrderLen); err != nil {
+		return 0, err
+	}
+	m.encode(b)
+	return AddOrderLen, nil
+}
+
+func (m *AddOrderMPID) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'F', AddOrderMPIDLen); err != nil {
+		return 0, err
+	}
+	m.AddOrder.encode(b)
+	copy(b[36:40], m.Attribution[:])
+	return AddOrderMPIDLen, nil
+}
+
+func (m *OrderExecuted) encode(b []byte) {
+	coder.PutUint64(b[11:19], m.OrderRef)
+	coder.PutUint32(b[19:23], m.ExecutedShares)
+	coder.PutUint64(b[23:31], m.MatchNumber)
+}
+
+func (m *OrderExecuted) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'E', OrderExecutedLen); err != nil {
+		return 0, err
+	}
+	m.encode(b)
+	return OrderExecutedLen, nil
+}
+
+func (m *OrderExecutedWithPrice) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'C', OrderExecutedWithPriceLen); err != nil {
+		return 0, err
+	}
+	m.OrderExecuted.encode(b)
+	b[31] = m.Printable
+	coder.PutUint32(b[32:36], m.ExecutionPrice)
+	return OrderExecutedWithPriceLen, nil
+}
+
+func (m *OrderCancel) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'X', OrderCancelLen); err != nil {
+		return 0, err
+	}
+	coder.PutUint64(b[11:19], m.OrderRef)
+	coder.PutUint32(b[19:23], m.CancelledShares)
+	return OrderCancelLen, nil
+}
+
+func (m *OrderDelete) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'D', OrderDeleteLen); err != nil {
+		return 0, err
+	}
+	coder.PutUint64(b[11:19], m.OrderRef)
+	return OrderDeleteLen, nil
+}
+
+func (m *OrderReplace) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'U', OrderReplaceLen); err != nil {
+		return 0, err
+	}
+	coder.PutUint64(b[11:19], m.OriginalOrderRef)
+	coder.PutUint64(b[19:27], m.NewOrderRef)
+	coder.PutUint32(b[27:31], m.Shares)
+	coder.PutUint32(b[31:35], m.Price)
+	return OrderReplaceLen, nil
+}
+
+func (m *Trade) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'P', TradeLen); err != nil {
+		return 0, err
+	}
+	coder.PutUint64(b[11:19], m.OrderRef)
+	b[19] = m.BuySell
+	coder.PutUint32(b[20:24], m.Shares)
+	copy(b[24:32], m.Stock[:])
+	coder.PutUint32(b[32:36], m.Price)
+	coder.PutUint64(b[36:44], m.MatchNumber)
+	return TradeLen, nil
+}
+
+func (m *CrossTrade) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'Q', CrossTradeLen); err != nil {
+		return 0, err
+	}
+	coder.PutUint64(b[11:19], m.Shares)
+	copy(b[19:27], m.Stock[:])
+	coder.PutUint32(b[27:31], m.CrossPrice)
+	coder.PutUint64(b[31:39], m.MatchNumber)
+	b[39] = m.CrossType
+	return CrossTradeLen, nil
+}
+
+func (m *BrokenTrade) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'B', BrokenTradeLen); err != nil {
+		return 0, err
+	}
+	coder.PutUint64(b[11:19], m.MatchNumber)
+	return BrokenTradeLen, nil
+}
+
+func (m *NOII) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'I', NOIILen); err != nil {
+		return 0, err
+	}
+	coder.PutUint64(b[11:19], m.PairedShares)
+	coder.PutUint64(b[19:27], m.ImbalanceShares)
+	b[27] = m.ImbalanceDirection
+	copy(b[28:36], m.Stock[:])
+	coder.PutUint32(b[36:40], m.FarPrice)
+	coder.PutUint32(b[40:44], m.NearPrice)
+	coder.PutUint32(b[44:48], m.CurrentReferencePrice)
+	b[48] = m.CrossType
+	b[49] = m.PriceVariationIndicator
+	return NOIILen, nil
+}
+
+func (m *RPII) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'N', RPIILen); err != nil {
+		return 0, err
+	}
+	copy(b[11:19], m.Stock[:])
+	b[19] = m.InterestFlag
+	return RPIILen, nil
+}
+
+func (m *DLCRPriceDiscovery) Encode(b []byte) (int, error) {
+	if err := m.Header.encode(b, 'O', DLCRPriceDiscoveryLen); err != nil {
+		return 0, err
+	}
+	copy(b[11:19], m.Stock[:])
+	b[19] = m.OpenEligibilityStatus
+	coder.PutUint32(b[20:24], m.MinimumAllowablePrice)
+	coder.PutUint32(b[24:28], m.MaximumAllowablePrice)
+	coder.PutUint32(b[28:32], m.NearExecutionPrice)
+	coder.PutUint64(b[32:40], m.NearExecutionTime)
+	coder.PutUint32(b[40:44], m.LowerPriceRangeCollar)
+	coder.PutUint32(b[44:48], m.UpperPriceRangeCollar)
+	return DLCRPriceDiscoveryLen, nil
+}
@@ -0,0 +1,101 @@
+package itch
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func hdr(msgType byte) Header {
+	return Header{MsgType: msgType, StockLocate: 7, TrackingNumber: 3,
+		Timestamp: 34200000000123}
+}
+
+var (
+	aapl = NewStock("AAPL")
+	msft = NewStock("MSFT")
+)
+
+var testMsgs = []struct {
+	msg  Encoder
+	size int
+}{
+	{&SystemEvent{hdr('S'), 'O'}, SystemEventLen},
+	{&StockDirectory{hdr('R'), aapl, 'Q', 'N', 100, 'N', 'C', [2]byte{'Z', ' '},
+		'P', 'N', 'N', '1', 'N', 0, 'N'}, StockDirectoryLen},
+	{&StockTradingAction{hdr('H'), msft, 'T', ' ', [4]byte{'M', 'W', 'C', '1'}},
+		StockTradingActionLen},
+	{&RegSHORestriction{hdr('Y'), aapl, '1'}, RegSHORestrictionLen},
+	{&MarketParticipantPosition{hdr('L'), [4]byte{'N', 'S', 'D', 'Q'}, aapl,
+		'Y', 'N', 'A'}, MarketParticipantPositionLen},
+	{&MWCBDeclineLevel{hdr('V'), 300000000000, 280000000000, 250000000000},
+		MWCBDeclineLevelLen},
+	{&MWCBStatus{hdr('W'), '2'}, MWCBStatusLen},
+	{&IPOQuotingPeriod{hdr('K'), msft, 36000, 'A', 250000}, IPOQuotingPeriodLen},
+	{&LULDAuctionCollar{hdr('J'), aapl, 1750000, 1800000, 1700000, 1},
+		LULDAuctionCollarLen},
+	{&OperationalHalt{hdr('h'), aapl, 'Q', 'H'}, OperationalHaltLen},
+	{&AddOrder{hdr('A'), 0x1001, 'B', 500, aapl, 1750000}, AddOrderLen},
+	{&AddOrderMPID{AddOrder{hdr('F'), 0x1002, 'S', 300, msft, 3100500},
+		[4]byte{'G', 'S', 'C', 'O'}}, AddOrderMPIDLen},
+	{&OrderExecuted{hdr('E'), 0x1001, 200, 99001}, OrderExecutedLen},
+	{&OrderExecutedWithPrice{OrderExecuted{hdr('C'), 0x1002, 100, 99002}, 'Y',
+		3100400}, OrderExecutedWithPriceLen},
+	{&OrderCancel{hdr('X'), 0x1001, 100}, OrderCancelLen},
+	{&OrderDelete{hdr('D'), 0x1001}, OrderDeleteLen},
+	{&OrderReplace{hdr('U'), 0x1002, 0x1003, 400, 3100600}, OrderReplaceLen},
+	{&Trade{hdr('P'), 0, 'B', 1000, aapl, 1750100, 99003}, TradeLen},
+	{&CrossTrade{hdr('Q'), 123456, aapl, 1750000, 99004, 'O'}, CrossTradeLen},
+	{&BrokenTrade{hdr('B'), 99003}, BrokenTradeLen},
+	{&NOII{hdr('I'), 100000, 2000, 'B', aapl, 1760000, 1755000, 1750000, 'O', 'L'},
+		NOIILen},
+	{&RPII{hdr('N'), msft, 'A'}, RPIILen},
+	{&DLCRPriceDiscovery{hdr('O'), aapl, 'Y', 1000000, 3000000, 2000000,
+		34200000000999, 1800000, 2200000}, DLCRPriceDiscoveryLen},
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	d := NewDecoder()
+	buff := make([]byte, 64)
+	for _, tt := range testMsgs {
+		n, err := tt.msg.Encode(buff)
+		if err != nil {
+			t.Errorf("Encode(%c) error %v", tt.msg.Type(), err)
+			continue
+		}
+		if n != tt.size {
+			t.Errorf("Encode(%c) length %d, want %d", tt.msg.Type(), n, tt.size)
+		}
+		if _, err := tt.msg.Encode(buff[:n-1]); err != ErrTooShort {
+			t.Errorf("Encode(%c) short buffer error = %v", tt.msg.Type(), err)
+		}
+		got, err := d.Decode(buff[:n])
+		if err != nil {
+			t.Errorf("Decode(%c) error %v", tt.msg.Type(), err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.msg) {
+			t.Errorf("round trip %c got %+v, want %+v", tt.msg.Type(), got, tt.msg)
+		}
+		if bb := Bytes(tt.msg); !bytes.Equal(bb, buff[:n]) {
+			t.Errorf("Bytes(%c) = %v, want %v", tt.msg.Type(), bb, buff[:n])
+		}
+	}
+}
+
+func TestEncodeAddOrder(t *testing.T) {
+	msg := AddOrder{Header: Header{StockLocate: 1, TrackingNumber: 2,
+		Timestamp: 12345}, OrderRef: 0x1001, BuySell: 'B', Shares: 500,
+		Stock: aapl, Price: 1750000}
+	if bb := Bytes(&msg); !bytes.Equal(bb, rawAddOrder) {
+		t.Errorf("Bytes() = %v, want %v", bb, rawAddOrder)
+	}
+}
+
+func BenchmarkEncodeAddOrder(b *testing.B) {
+	msg := testMsgs[10].msg
+	buff := make([]byte, 64)
+	for i := 0; i < b.N; i++ {
+		msg.Encode(buff)
+	}
+}
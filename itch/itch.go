@@ -0,0 +1,165 @@
+// Package itch	decode Nasdaq TotalView-ITCH 5.0 messages carried by MoldUDP64
+//
+// Specification could be downloaded from
+// http://www.nasdaqtrader.com/Trader.aspx?id=DPSpecs
+package itch
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var coder = binary.BigEndian
+
+const (
+	headSize = 11
+)
+
+var (
+	ErrTooShort    = errors.New("ITCH message too short")
+	ErrMsgType     = errors.New("ITCH message type dismatch")
+	ErrUnknownType = errors.New("Unknown ITCH message type")
+)
+
+// Message	ITCH message decoded in place, no allocation
+type Message interface {
+	// Type		message type, the first byte of message
+	Type() byte
+	// Decode	decode message from buf, buf[0] is message type
+	Decode(buf []byte) error
+}
+
+// Handler	callback for decoded message, msg only valid in callback
+type Handler func(msg Message)
+
+// Stock	stock symbol, left justified padded with spaces
+type Stock [8]byte
+
+func (s Stock) String() string {
+	i := len(s)
+	for ; i > 0; i-- {
+		if s[i-1] != ' ' {
+			break
+		}
+	}
+	return string(s[:i])
+}
+
+// NewStock	Stock from symbol, truncated to 8 bytes
+func NewStock(sym string) (s Stock) {
+	for i := range s {
+		s[i] = ' '
+	}
+	copy(s[:], sym)
+	return
+}
+
+// Header	common header of ITCH 5.0 messages
+//	Timestamp	nanoseconds since midnight
+type Header struct {
+	MsgType        byte
+	StockLocate    uint16
+	TrackingNumber uint16
+	Timestamp      uint64
+}
+
+func getUint48(b []byte) uint64 {
+	return uint64(b[0])<<40 | uint64(b[1])<<32 | uint64(b[2])<<24 |
+		uint64(b[3])<<16 | uint64(b[4])<<8 | uint64(b[5])
+}
+
+func (h *Header) decode(b []byte, msgType byte, size int) error {
+	if len(b) == 0 {
+		return ErrTooShort
+	}
+	if b[0] != msgType {
+		return ErrMsgType
+	}
+	if len(b) < size {
+		return ErrTooShort
+	}
+	h.MsgType = b[0]
+	h.StockLocate = coder.Uint16(b[1:3])
+	h.TrackingNumber = coder.Uint16(b[3:5])
+	h.Timestamp = getUint48(b[5:11])
+	return nil
+}
+
+// Decoder	dispatch message on first byte of Message.Data, one message
+//			instance for each type reused, not safe for concurrent use
+type Decoder struct {
+	msgs     [256]Message
+	handlers [256]Handler
+}
+
+// NewDecoder	Decoder with all ITCH 5.0 message types registered
+func NewDecoder() *Decoder {
+	d := Decoder{}
+	d.Register(&SystemEvent{})
+	d.Register(&StockDirectory{})
+	d.Register(&StockTradingAction{})
+	d.Register(&RegSHORestriction{})
+	d.Register(&MarketParticipantPosition{})
+	d.Register(&MWCBDeclineLevel{})
+	d.Register(&MWCBStatus{})
+	d.Register(&IPOQuotingPeriod{})
+	d.Register(&LULDAuctionCollar{})
+	d.Register(&OperationalHalt{})
+	d.Register(&AddOrder{})
+	d.Register(&AddOrderMPID{})
+	d.Register(&OrderExecuted{})
+	d.Register(&OrderExecutedWithPrice{})
+	d.Register(&OrderCancel{})
+	d.Register(&OrderDelete{})
+	d.Register(&OrderReplace{})
+	d.Register(&Trade{})
+	d.Register(&CrossTrade{})
+	d.Register(&BrokenTrade{})
+	d.Register(&NOII{})
+	d.Register(&RPII{})
+	d.Register(&DLCRPriceDiscovery{})
+	return &d
+}
+
+// Register	register or replace message type, msg reused for decoding
+func (d *Decoder) Register(msg Message) {
+	d.msgs[msg.Type()] = msg
+}
+
+// Handle	callback fx for message type, nil to remove
+func (d *Decoder) Handle(msgType byte, fx Handler) {
+	d.handlers[msgType] = fx
+}
+
+// Decode	decode message, valid until next Decode of same type
+func (d *Decoder) Decode(data []byte) (Message, error) {
+	if len(data) == 0 {
+		return nil, ErrTooShort
+	}
+	msg := d.msgs[data[0]]
+	if msg == nil {
+		return nil, ErrUnknownType
+	}
+	if err := msg.Decode(data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Dispatch	decode message and callback handler of its type, message
+//			without handler is not decoded
+func (d *Decoder) Dispatch(data []byte) error {
+	if len(data) == 0 {
+		return ErrTooShort
+	}
+	fx := d.handlers[data[0]]
+	if fx == nil {
+		return nil
+	}
+	msg, err := d.Decode(data)
+	if err != nil {
+		return err
+	}
+	fx(msg)
+	return nil
+}
@@ -0,0 +1,88 @@
+package itch
+
+import (
+	"testing"
+)
+
+// raw ITCH 5.0 messages build from spec offsets
+var (
+	rawAddOrder = []byte{'A', 0, 1, 0, 2, 0, 0, 0, 0, 0x30, 0x39,
+		0, 0, 0, 0, 0, 0, 0x10, 0x01, 'B', 0, 0, 0x01, 0xf4,
+		'A', 'A', 'P', 'L', ' ', ' ', ' ', ' ', 0, 0x1a, 0xb3, 0xf0}
+	rawOrderDelete = []byte{'D', 0, 1, 0, 3, 0, 0, 0, 0, 0x30, 0x3a,
+		0, 0, 0, 0, 0, 0, 0x10, 0x01}
+	rawSystemEvent = []byte{'S', 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 'O'}
+)
+
+func TestDecodeAddOrder(t *testing.T) {
+	var msg AddOrder
+	if err := msg.Decode(rawAddOrder); err != nil {
+		t.Fatal("Decode()", err)
+	}
+	want := AddOrder{Header: Header{MsgType: 'A', StockLocate: 1,
+		TrackingNumber: 2, Timestamp: 12345}, OrderRef: 0x1001, BuySell: 'B',
+		Shares: 500, Stock: NewStock("AAPL"), Price: 1750000}
+	if msg != want {
+		t.Errorf("Decode() = %+v, want %+v", msg, want)
+	}
+	if ss := msg.Stock.String(); ss != "AAPL" {
+		t.Errorf("Stock.String() = %q, want AAPL", ss)
+	}
+	if err := msg.Decode(rawAddOrder[:20]); err != ErrTooShort {
+		t.Errorf("Decode() short error = %v, want %v", err, ErrTooShort)
+	}
+	if err := msg.Decode(rawOrderDelete); err != ErrMsgType {
+		t.Errorf("Decode() type error = %v, want %v", err, ErrMsgType)
+	}
+}
+
+func TestDecoderDispatch(t *testing.T) {
+	d := NewDecoder()
+	var nAdd, nDel int
+	d.Handle('A', func(msg Message) {
+		if m, ok := msg.(*AddOrder); !ok || m.OrderRef != 0x1001 {
+			t.Errorf("Dispatch 'A' got %+v", msg)
+		}
+		nAdd++
+	})
+	d.Handle('D', func(msg Message) {
+		if m, ok := msg.(*OrderDelete); !ok || m.OrderRef != 0x1001 {
+			t.Errorf("Dispatch 'D' got %+v", msg)
+		}
+		nDel++
+	})
+	for _, raw := range [][]byte{rawAddOrder, rawSystemEvent, rawOrderDelete} {
+		if err := d.Dispatch(raw); err != nil {
+			t.Error("Dispatch()", err)
+		}
+	}
+	if nAdd != 1 || nDel != 1 {
+		t.Errorf("Dispatch() handled %d add, %d delete, want 1, 1", nAdd, nDel)
+	}
+	if msg, err := d.Decode(rawSystemEvent); err != nil {
+		t.Error("Decode()", err)
+	} else if m := msg.(*SystemEvent); m.EventCode != 'O' || m.Timestamp != 1 {
+		t.Errorf("Decode() = %+v", m)
+	}
+	if _, err := d.Decode([]byte{'z', 0}); err != ErrUnknownType {
+		t.Errorf("Decode() error = %v, want %v", err, ErrUnknownType)
+	}
+	if n := testing.AllocsPerRun(100, func() { d.Dispatch(rawAddOrder) }); n != 0 {
+		t.Errorf("Dispatch() %v allocs, want 0", n)
+	}
+}
+
+func BenchmarkDecodeAddOrder(b *testing.B) {
+	var msg AddOrder
+	for i := 0; i < b.N; i++ {
+		msg.Decode(rawAddOrder)
+	}
+}
+
+func BenchmarkDispatch(b *testing.B) {
+	d := NewDecoder()
+	d.Handle('A', func(msg Message) {})
+	for i := 0; i < b.N; i++ {
+		d.Dispatch(rawAddOrder)
+	}
+}
@@ -0,0 +1,596 @@
+package itch
+
+// message length of ITCH 5.0 messages
+const (
+	SystemEventLen               = 12
+	StockDirectoryLen            = 39
+	StockTradingActionLen        = 25
+	RegSHORestrictionLen         = 20
+	MarketParticipantPositionLen = 26
+	MWCBDeclineLevelLen          = 35
+	MWCBStatusLen                = 12
+	IPOQuotingPeriodLen          = 28
+	LULDAuctionCollarLen         = 35
+	OperationalHaltLen           = 21
+	AddOrderLen                  = 36
+	AddOrderMPIDLen              = 40
+	OrderExecutedLen             = 31
+	OrderExecutedWithPriceLen    = 36
+	OrderCancelLen               = 23
+	OrderDeleteLen               = 19
+	OrderReplaceLen              = 35
+	TradeLen                     = 44
+	CrossTradeLen                = 40
+	BrokenTradeLen               = 19
+	NOIILen                      = 50
+	RPIILen                      = 20
+	DLCRPriceDiscoveryLen        = 48
+)
+
+// SystemEvent	'S' market or data feed handling event
+//	EventCode	O,S,Q,M,E,C
+type SystemEvent struct {
+	Header
+	EventCode byte
+}
+
+func (m *SystemEvent) Type() byte {
+	return 'S'
+}
+
+func (m *SystemEvent) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'S', SystemEventLen); err != nil {
+		return err
+	}
+	m.EventCode = b[11]
+	return nil
+}
+
+// StockDirectory	'R' security listed on Nasdaq at start of day
+type StockDirectory struct {
+	Header
+	Stock                       Stock
+	MarketCategory              byte
+	FinancialStatusIndicator    byte
+	RoundLotSize                uint32
+	RoundLotsOnly               byte
+	IssueClassification         byte
+	IssueSubType                [2]byte
+	Authenticity                byte
+	ShortSaleThresholdIndicator byte
+	IPOFlag                     byte
+	LULDReferencePriceTier      byte
+	ETPFlag                     byte
+	ETPLeverageFactor           uint32
+	InverseIndicator            byte
+}
+
+func (m *StockDirectory) Type() byte {
+	return 'R'
+}
+
+func (m *StockDirectory) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'R', StockDirectoryLen); err != nil {
+		return err
+	}
+	copy(m.Stock[:], b[11:19])
+	m.MarketCategory = b[19]
+	m.FinancialStatusIndicator = b[20]
+	m.RoundLotSize = coder.Uint32(b[21:25])
+	m.RoundLotsOnly = b[25]
+	m.IssueClassification = b[26]
+	copy(m.IssueSubType[:], b[27:29])
+	m.Authenticity = b[29]
+	m.ShortSaleThresholdIndicator = b[30]
+	m.IPOFlag = b[31]
+	m.LULDReferencePriceTier = b[32]
+	m.ETPFlag = b[33]
+	m.ETPLeverageFactor = coder.Uint32(b[34:38])
+	m.InverseIndicator = b[38]
+	return nil
+}
+
+// StockTradingAction	'H' trading status of security
+//	TradingState	H halted, P paused, Q quotation only, T trading
+type StockTradingAction struct {
+	Header
+	Stock        Stock
+	TradingState byte
+	Reserved     byte
+	Reason       [4]byte
+}
+
+func (m *StockTradingAction) Type() byte {
+	return 'H'
+}
+
+func (m *StockTradingAction) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'H', StockTradingActionLen); err != nil {
+		return err
+	}
+	copy(m.Stock[:], b[11:19])
+	m.TradingState = b[19]
+	m.Reserved = b[20]
+	copy(m.Reason[:], b[21:25])
+	return nil
+}
+
+// RegSHORestriction	'Y' Reg SHO short sale price test restriction
+type RegSHORestriction struct {
+	Header
+	Stock        Stock
+	RegSHOAction byte
+}
+
+func (m *RegSHORestriction) Type() byte {
+	return 'Y'
+}
+
+func (m *RegSHORestriction) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'Y', RegSHORestrictionLen); err != nil {
+		return err
+	}
+	copy(m.Stock[:], b[11:19])
+	m.RegSHOAction = b[19]
+	return nil
+}
+
+// MarketParticipantPosition	'L' market participant registration
+type MarketParticipantPosition struct {
+	Header
+	MPID                   [4]byte
+	Stock                  Stock
+	PrimaryMarketMaker     byte
+	MarketMakerMode        byte
+	MarketParticipantState byte
+}
+
+func (m *MarketParticipantPosition) Type() byte {
+	return 'L'
+}
+
+func (m *MarketParticipantPosition) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'L', MarketParticipantPositionLen); err != nil {
+		return err
+	}
+	copy(m.MPID[:], b[11:15])
+	copy(m.Stock[:], b[15:23])
+	m.PrimaryMarketMaker = b[23]
+	m.MarketMakerMode = b[24]
+	m.MarketParticipantState = b[25]
+	return nil
+}
+
+// MWCBDeclineLevel	'V' market wide circuit breaker levels
+//	price with 8 decimal places
+type MWCBDeclineLevel struct {
+	Header
+	Level1 uint64
+	Level2 uint64
+	Level3 uint64
+}
+
+func (m *MWCBDeclineLevel) Type() byte {
+	return 'V'
+}
+
+func (m *MWCBDeclineLevel) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'V', MWCBDeclineLevelLen); err != nil {
+		return err
+	}
+	m.Level1 = coder.Uint64(b[11:19])
+	m.Level2 = coder.Uint64(b[19:27])
+	m.Level3 = coder.Uint64(b[27:35])
+	return nil
+}
+
+// MWCBStatus	'W' market wide circuit breaker level breached
+type MWCBStatus struct {
+	Header
+	BreachedLevel byte
+}
+
+func (m *MWCBStatus) Type() byte {
+	return 'W'
+}
+
+func (m *MWCBStatus) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'W', MWCBStatusLen); err != nil {
+		return err
+	}
+	m.BreachedLevel = b[11]
+	return nil
+}
+
+// IPOQuotingPeriod	'K' anticipated IPO quotation release time
+type IPOQuotingPeriod struct {
+	Header
+	Stock                        Stock
+	IPOQuotationReleaseTime      uint32
+	IPOQuotationReleaseQualifier byte
+	IPOPrice                     uint32
+}
+
+func (m *IPOQuotingPeriod) Type() byte {
+	return 'K'
+}
+
+func (m *IPOQuotingPeriod) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'K', IPOQuotingPeriodLen); err != nil {
+		return err
+	}
+	copy(m.Stock[:], b[11:19])
+	m.IPOQuotationReleaseTime = coder.Uint32(b[19:23])
+	m.IPOQuotationReleaseQualifier = b[23]
+	m.IPOPrice = coder.Uint32(b[24:28])
+	return nil
+}
+
+// LULDAuctionCollar	'J' auction collar thresholds for paused security
+type LULDAuctionCollar struct {
+	Header
+	Stock                       Stock
+	AuctionCollarReferencePrice uint32
+	UpperAuctionCollarPrice     uint32
+	LowerAuctionCollarPrice     uint32
+	AuctionCollarExtension      uint32
+}
+
+func (m *LULDAuctionCollar) Type() byte {
+	return 'J'
+}
+
+func (m *LULDAuctionCollar) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'J', LULDAuctionCollarLen); err != nil {
+		return err
+	}
+	copy(m.Stock[:], b[11:19])
+	m.AuctionCollarReferencePrice = coder.Uint32(b[19:23])
+	m.UpperAuctionCollarPrice = coder.Uint32(b[23:27])
+	m.LowerAuctionCollarPrice = coder.Uint32(b[27:31])
+	m.AuctionCollarExtension = coder.Uint32(b[31:35])
+	return nil
+}
+
+// OperationalHalt	'h' operational halt of security on a market
+type OperationalHalt struct {
+	Header
+	Stock                 Stock
+	MarketCode            byte
+	OperationalHaltAction byte
+}
+
+func (m *OperationalHalt) Type() byte {
+	return 'h'
+}
+
+func (m *OperationalHalt) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'h', OperationalHaltLen); err != nil {
+		return err
+	}
+	copy(m.Stock[:], b[11:19])
+	m.MarketCode = b[19]
+	m.OperationalHaltAction = b[20]
+	return nil
+}
+
+// AddOrder	'A' new order accepted, no MPID attribution
+//	BuySell	'B' for buy, 'S' for sell
+//	Price	4 decimal places
+type AddOrder struct {
+	Header
+	OrderRef uint64
+	BuySell  byte
+	Shares   uint32
+	Stock    Stock
+	Price    uint32
+}
+
+func (m *AddOrder) Type() byte {
+	return 'A'
+}
+
+func (m *AddOrder) decode(b []byte) {
+	m.OrderRef = coder.Uint64(b[11:19])
+	m.BuySell = b[19]
+	m.Shares = coder.Uint32(b[20:24])
+	copy(m.Stock[:], b[24:32])
+	m.Price = coder.Uint32(b[32:36])
+}
+
+func (m *AddOrder) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'A', AddOrderLen); err != nil {
+		return err
+	}
+	m.decode(b)
+	return nil
+}
+
+// AddOrderMPID	'F' new order accepted with MPID attribution
+type AddOrderMPID struct {
+	AddOrder
+	Attribution [4]byte
+}
+
+func (m *AddOrderMPID) Type() byte {
+	return 'F'
+}
+
+func (m *AddOrderMPID) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'F', AddOrderMPIDLen); err != nil {
+		return err
+	}
+	m.AddOrder.decode(b)
+	copy(m.Attribution[:], b[36:40])
+	return nil
+}
+
+// OrderExecuted	'E' order on book executed in whole or in part
+type OrderExecuted struct {
+	Header
+	OrderRef       uint64
+	ExecutedShares uint32
+	MatchNumber    uint64
+}
+
+func (m *OrderExecuted) Type() byte {
+	return 'E'
+}
+
+func (m *OrderExecuted) decode(b []byte) {
+	m.OrderRef = coder.Uint64(b[11:19])
+	m.ExecutedShares = coder.Uint32(b[19:23])
+	m.MatchNumber = coder.Uint64(b[23:31])
+}
+
+func (m *OrderExecuted) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'E', OrderExecutedLen); err != nil {
+		return err
+	}
+	m.decode(b)
+	return nil
+}
+
+// OrderExecutedWithPrice	'C' order executed at price differ from
+//							initial display price
+type OrderExecutedWithPrice struct {
+	OrderExecuted
+	Printable      byte
+	ExecutionPrice uint32
+}
+
+func (m *OrderExecutedWithPrice) Type() byte {
+	return 'C'
+}
+
+func (m *OrderExecutedWithPrice) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'C', OrderExecutedWithPriceLen); err != nil {
+		return err
+	}
+	m.OrderExecuted.decode(b)
+	m.Printable = b[31]
+	m.ExecutionPrice = coder.Uint32(b[32:36])
+	return nil
+}
+
+// OrderCancel	'X' order on book partially cancelled
+type OrderCancel struct {
+	Header
+	OrderRef        uint64
+	CancelledShares uint32
+}
+
+func (m *OrderCancel) Type() byte {
+	return 'X'
+}
+
+func (m *OrderCancel) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'X', OrderCancelLen); err != nil {
+		return err
+	}
+	m.OrderRef = coder.Uint64(b[11:19])
+	m.CancelledShares = coder.Uint32(b[19:23])
+	return nil
+}
+
+// OrderDelete	'D' order on book cancelled in full
+type OrderDelete struct {
+	Header
+	OrderRef uint64
+}
+
+func (m *OrderDelete) Type() byte {
+	return 'D'
+}
+
+func (m *OrderDelete) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'D', OrderDeleteLen); err != nil {
+		return err
+	}
+	m.OrderRef = coder.Uint64(b[11:19])
+	return nil
+}
+
+// OrderReplace	'U' order on book cancel-replaced, new order keeps side
+//				and stock of original order
+type OrderReplace struct {
+	Header
+	OriginalOrderRef uint64
+	NewOrderRef      uint64
+	Shares           uint32
+	Price            uint32
+}
+
+func (m *OrderReplace) Type() byte {
+	return 'U'
+}
+
+func (m *OrderReplace) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'U', OrderReplaceLen); err != nil {
+		return err
+	}
+	m.OriginalOrderRef = coder.Uint64(b[11:19])
+	m.NewOrderRef = coder.Uint64(b[19:27])
+	m.Shares = coder.Uint32(b[27:31])
+	m.Price = coder.Uint32(b[31:35])
+	return nil
+}
+
+// Trade	'P' execution of non-displayed order
+type Trade struct {
+	Header
+	OrderRef    uint64
+	BuySell     byte
+	Shares      uint32
+	Stock       Stock
+	Price       uint32
+	MatchNumber uint64
+}
+
+func (m *Trade) Type() byte {
+	return 'P'
+}
+
+func (m *Trade) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'P', TradeLen); err != nil {
+		return err
+	}
+	m.OrderRef = coder.Uint64(b[11:19])
+	m.BuySell = b[19]
+	m.Shares = coder.Uint32(b[20:24])
+	copy(m.Stock[:], b[24:32])
+	m.Price = coder.Uint32(b[32:36])
+	m.MatchNumber = coder.Uint64(b[36:44])
+	return nil
+}
+
+// CrossTrade	'Q' bulk print of opening, closing, IPO or halt cross
+type CrossTrade struct {
+	Header
+	Shares      uint64
+	Stock       Stock
+	CrossPrice  uint32
+	MatchNumber uint64
+	CrossType   byte
+}
+
+func (m *CrossTrade) Type() byte {
+	return 'Q'
+}
+
+func (m *CrossTrade) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'Q', CrossTradeLen); err != nil {
+		return err
+	}
+	m.Shares = coder.Uint64(b[11:19])
+	copy(m.Stock[:], b[19:27])
+	m.CrossPrice = coder.Uint32(b[27:31])
+	m.MatchNumber = coder.Uint64(b[31:39])
+	m.CrossType = b[39]
+	return nil
+}
+
+// BrokenTrade	'B' execution broken
+type BrokenTrade struct {
+	Header
+	MatchNumber uint64
+}
+
+func (m *BrokenTrade) Type() byte {
+	return 'B'
+}
+
+func (m *BrokenTrade) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'B', BrokenTradeLen); err != nil {
+		return err
+	}
+	m.MatchNumber = coder.Uint64(b[11:19])
+	return nil
+}
+
+// NOII	'I' net order imbalance indicator
+type NOII struct {
+	Header
+	PairedShares            uint64
+	ImbalanceShares         uint64
+	ImbalanceDirection      byte
+	Stock                   Stock
+	FarPrice                uint32
+	NearPrice               uint32
+	CurrentReferencePrice   uint32
+	CrossType               byte
+	PriceVariationIndicator byte
+}
+
+func (m *NOII) Type() byte {
+	return 'I'
+}
+
+func (m *NOII) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'I', NOIILen); err != nil {
+		return err
+	}
+	m.PairedShares = coder.Uint64(b[11:19])
+	m.ImbalanceShares = coder.Uint64(b[19:27])
+	m.ImbalanceDirection = b[27]
+	copy(m.Stock[:], b[28:36])
+	m.FarPrice = coder.Uint32(b[36:40])
+	m.NearPrice = coder.Uint32(b[40:44])
+	m.CurrentReferencePrice = coder.Uint32(b[44:48])
+	m.CrossType = b[48]
+	m.PriceVariationIndicator = b[49]
+	return nil
+}
+
+// RPII	'N' retail price improvement indicator
+type RPII struct {
+	Header
+	Stock        Stock
+	InterestFlag byte
+}
+
+func (m *RPII) Type() byte {
+	return 'N'
+}
+
+func (m *RPII) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'N', RPIILen); err != nil {
+		return err
+	}
+	copy(m.Stock[:], b[11:19])
+	m.InterestFlag = b[19]
+	return nil
+}
+
+// DLCRPriceDiscovery	'O' direct listing with capital raise price
+//						discovery
+type DLCRPriceDiscovery struct {
+	Header
+	Stock                 Stock
+	OpenEligibilityStatus byte
+	MinimumAllowablePrice uint32
+	MaximumAllowablePrice uint32
+	NearExecutionPrice    uint32
+	NearExecutionTime     uint64
+	LowerPriceRangeCollar uint32
+	UpperPriceRangeCollar uint32
+}
+
+func (m *DLCRPriceDiscovery) Type() byte {
+	return 'O'
+}
+
+func (m *DLCRPriceDiscovery) Decode(b []byte) error {
+	if err := m.Header.decode(b, 'O', DLCRPriceDiscoveryLen); err != nil {
+		return err
+	}
+	copy(m.Stock[:], b[11:19])
+	m.OpenEligibilityStatus = b[19]
+	m.MinimumAllowablePrice = coder.Uint32(b[20:24])
+	m.MaximumAllowablePrice = coder.Uint32(b[24:28])
+	m.NearExecutionPrice = coder.Uint32(b[28:32])
+	m.NearExecutionTime = coder.Uint64(b[32:40])
+	m.LowerPriceRangeCollar = coder.Uint32(b[40:44])
+	m.UpperPriceRangeCollar = coder.Uint32(b[44:48])
+	return nil
+}
@@ -0,0 +1,452 @@
+package MoldUDP
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const mgrQueueLen = 8192
+
+var (
+	errNoChannel  = errors.New("No channel to listen")
+	errDupChannel = errors.New("Duplicate channel session on group/port")
+)
+
+// mcastJoiner	McastConn opened could receive more groups on same port
+type mcastJoiner interface {
+	Join(ip net.IP, src net.IP, ifn *net.Interface) error
+}
+
+// Channel	one MoldUDP64 session of Manager
+//	Name	name of channel, default session or group:port
+//	Addr	multicast group
+//	Port	UDP port
+//	Session	session of channel, blank for any session of group/port,
+//			only channel of any session follow rollover
+//	NextSeq	if not 0, next sequence number instead of Option.NextSeq
+//	Srvs	if not empty, request servers instead of Option.Srvs
+//	Recovery	if not nil, store for Option.LateJoin of channel instead
+//				of request servers and for gap beyond nakWindow,
+//				Option.Recovery not used by Manager
+type Channel struct {
+	Name     string
+	Addr     string
+	Port     int
+	Session  string
+	NextSeq  uint64
+	Srvs     []string
+	Recovery MessageStore
+}
+
+// SessionStats	statistics of one channel of Manager
+//	SeqMax	max sequence number seen
+//	ClientStats	statistics of client of channel
+type SessionStats struct {
+	Name   string
+	SeqMax uint64
+	ClientStats
+}
+
+// mgrSock	receive McastConn shared by channels on same port
+type mgrSock struct {
+	conn     McastConn
+	port     int
+	groups   []net.IP
+	sessions map[string]*Client
+	anyC     *Client
+	// sessions of all channels on groups, blank for any session
+	names map[string]bool
+	// last session decoded, owned by recvLoop of sock
+	hint string
+}
+
+type mgrBuf struct {
+	c *Client
+	msgBuf
+}
+
+// Manager	clients of channels multiplexed over shared receive sockets,
+//			one queue and goroutine for sequencing of all channels,
+//			retransmission via request socket of each channel
+type Manager struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	socks     []*mgrSock
+	clients   []*Client
+	ch        chan mgrBuf
+	statC     chan chan []SessionStats
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	nUnknown  int64
+}
+
+// NewManager	open shared sockets via netMode for channels, channels on
+//			same port share one socket if McastConn could join more
+//			groups and sessions of groups differ, none of any session,
+//			sessions on same group/port dispatched by session
+func NewManager(ctx context.Context, chans []Channel, netMode string, opt *Option, startOnFirst bool) (*Manager, error) {
+	if len(chans) == 0 {
+		return nil, errNoChannel
+	}
+	var ifn *net.Interface
+	if opt.IfName != "" {
+		var err error
+		if ifn, err = net.InterfaceByName(opt.IfName); err != nil {
+			log.Errorf("Ifn(%s) error: %v\n", opt.IfName, err)
+			ifn = nil
+		}
+	}
+	var src net.IP
+	if opt.Source != "" {
+		if src = net.ParseIP(opt.Source); src == nil {
+			log.Errorf("Source(%s) not IP address", opt.Source)
+			return nil, errSource
+		}
+	}
+	// sessions of each group/port, checked before any group joined
+	names := map[string]map[string]bool{}
+	for i := range chans {
+		key := memKey(chanGroup(&chans[i]), chans[i].Port)
+		if names[key] == nil {
+			names[key] = map[string]bool{}
+		}
+		if names[key][chans[i].Session] {
+			return nil, errDupChannel
+		}
+		names[key][chans[i].Session] = true
+	}
+	m := &Manager{ch: make(chan mgrBuf, mgrQueueLen),
+		statC: make(chan chan []SessionStats), done: make(chan struct{})}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	for i := range chans {
+		ch := &chans[i]
+		sess := names[memKey(chanGroup(ch), ch.Port)]
+		if err := m.addChannel(ch, sess, netMode, opt, src, ifn, startOnFirst); err != nil {
+			m.closeConn()
+			return nil, err
+		}
+	}
+	for i, c := range m.clients {
+		c.recovery = chans[i].Recovery
+		c.startLateJoin(opt, chans[i].Recovery)
+	}
+	go func() {
+		<-m.ctx.Done()
+		m.closeOnce.Do(m.closeConn)
+	}()
+	go m.worker()
+	m.wg.Add(len(m.socks))
+	for _, sock := range m.socks {
+		go m.recvLoop(sock)
+	}
+	return m, nil
+}
+
+// chanGroup	multicast group of ch
+func chanGroup(ch *Channel) net.IP {
+	group := net.ParseIP(ch.Addr)
+	if !group.IsMulticast() {
+		group = allNodes(group)
+	}
+	return group
+}
+
+// addChannel	new Client of ch, receive via shared socket
+//	sess	sessions of all channels on group/port of ch
+func (m *Manager) addChannel(ch *Channel, sess map[string]bool, netMode string, opt *Option, src net.IP, ifn *net.Interface, startOnFirst bool) error {
+	group := chanGroup(ch)
+	if !group.Equal(net.ParseIP(ch.Addr)) {
+		log.Info(ch.Addr, "is not multicast IP")
+	}
+	sock, err := m.getSock(group, ch.Port, sess, netMode, src, ifn)
+	if err != nil {
+		return err
+	}
+	c := &Client{seqNo: opt.NextSeq, dstIP: group, dstPort: ch.Port, mgr: m}
+	if ch.NextSeq != 0 {
+		c.seqNo = ch.NextSeq
+	}
+	if c.seqNo == 0 {
+		c.seqNo++
+	}
+	c.startOnFirst = startOnFirst
+	c.firstOnNew = startOnFirst
+	c.rollover = opt.Rollover
+	srvs := opt.Srvs
+	if len(ch.Srvs) != 0 {
+		srvs = ch.Srvs
+	}
+	for _, daddr := range srvs {
+		if daddr != "" {
+			c.reqSrv = append(c.reqSrv, reqSrvAddr(daddr, ch.Port))
+		}
+	}
+	// sessions unique on sock as checked by NewManager and getSock
+	if ch.Session == "" {
+		sock.anyC = c
+	} else {
+		sock.sessions[ch.Session] = c
+		c.session = ch.Session
+	}
+	c.name = ch.Name
+	if c.name == "" {
+		c.name = ch.Session
+		if c.name == "" {
+			c.name = memKey(group, ch.Port)
+		}
+	}
+	c.cache.Init()
+	c.readCond = sync.NewCond(&c.readLock)
+	c.ctx, c.cancel = context.WithCancel(m.ctx)
+	c.LastRecv = time.Now().Unix()
+	context.AfterFunc(c.ctx, func() {
+		c.readLock.Lock()
+		c.readCond.Broadcast()
+		c.readLock.Unlock()
+	})
+	m.clients = append(m.clients, c)
+	return nil
+}
+
+// getSock	socket for group/port, join group on socket of port if possible
+//			and sessions tell packets of groups apart, packets
+//			dispatched by session only
+func (m *Manager) getSock(group net.IP, port int, sess map[string]bool, netMode string, src net.IP, ifn *net.Interface) (*mgrSock, error) {
+	for _, sock := range m.socks {
+		if sock.port != port {
+			continue
+		}
+		for _, ip := range sock.groups {
+			if ip.Equal(group) {
+				return sock, nil
+			}
+		}
+	}
+	for _, sock := range m.socks {
+		if sock.port != port || isIPv6(sock.groups[0]) != isIPv6(group) ||
+			!sock.distinct(sess) {
+			continue
+		}
+		if jc, ok := sock.conn.(mcastJoiner); ok {
+			if err := jc.Join(group, src, ifn); err == nil {
+				sock.groups = append(sock.groups, group)
+				for name := range sess {
+					sock.names[name] = true
+				}
+				return sock, nil
+			}
+		}
+	}
+	sock := &mgrSock{conn: NewIf(netMode), port: port, groups: []net.IP{group},
+		sessions: map[string]*Client{}, names: map[string]bool{}}
+	for name := range sess {
+		sock.names[name] = true
+	}
+	if err := sock.conn.Open(group, port, src, ifn); err != nil {
+		log.Error("Open Multicast", err)
+		return nil, err
+	}
+	m.socks = append(m.socks, sock)
+	return sock, nil
+}
+
+// distinct	sessions of group tell its packets apart from packets of
+//			groups on sock, no any session on both
+func (sock *mgrSock) distinct(sess map[string]bool) bool {
+	if sock.names[""] || sess[""] {
+		return false
+	}
+	for name := range sess {
+		if sock.names[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// closeConn	close sockets, recv loops exit
+func (m *Manager) closeConn() {
+	for _, sock := range m.socks {
+		sock.conn.Close()
+	}
+}
+
+// Close	stop all channels and close sockets
+func (m *Manager) Close() error {
+	m.cancel()
+	m.closeOnce.Do(m.closeConn)
+	m.wg.Wait()
+	<-m.done
+	return nil
+}
+
+// Clients	clients of channels in order of NewManager, read via
+//			Read/Subscribe/Messages of each
+func (m *Manager) Clients() []*Client {
+	return m.clients
+}
+
+// Client	client of channel named name, nil if not found
+func (m *Manager) Client(name string) *Client {
+	for _, c := range m.clients {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// dispatch	queue packet to client of session
+func (m *Manager) dispatch(sock *mgrSock, buff []byte, rAddr *net.UDPAddr) error {
+	var head Header
+	if err := decodeHead(buff, &head, sock.hint); err != nil {
+		return errDecodeHead
+	}
+	sock.hint = head.Session
+	c := m.lookup(sock, head.Session)
+	if c == nil {
+		atomic.AddInt64(&m.nUnknown, 1)
+		return nil
+	}
+	if err := m.queuePacket(c, buff, &head, true); err != nil {
+		return err
+	}
+	c.addReqSrv(rAddr, sock.port+1)
+	return nil
+}
+
+// lookup	client of session on sock, channel of any session bound to
+//			session of first packet
+func (m *Manager) lookup(sock *mgrSock, session string) *Client {
+	if c, ok := sock.sessions[session]; ok {
+		return c
+	}
+	if c := sock.anyC; c != nil {
+		c.recvLock.Lock()
+		defer c.recvLock.Unlock()
+		if c.session == "" || c.session == session || c.rollover != SessionReject {
+			return c
+		}
+	}
+	return nil
+}
+
+// queuePacket	queue packet of client, live for multicast packet
+func (m *Manager) queuePacket(c *Client, buff []byte, head *Header, live bool) error {
+	if c.ctx.Err() != nil {
+		return nil
+	}
+	msgBB, err := c.parseBuff(buff, len(buff), head, nil, live)
+	if err != nil {
+		return err
+	}
+	return m.queue(c, msgBB)
+}
+
+func (m *Manager) queue(c *Client, msgBB msgBuf) error {
+	select {
+	case m.ch <- mgrBuf{c: c, msgBuf: msgBB}:
+	case <-m.ctx.Done():
+		return m.ctx.Err()
+	}
+	return nil
+}
+
+func (m *Manager) recvLoop(sock *mgrSock) {
+	defer m.wg.Done()
+	recvLoop(m.ctx, sock.conn, func(buff []byte, rAddr *net.UDPAddr) error {
+		return m.dispatch(sock, buff, rAddr)
+	})
+}
+
+// worker	sequencing and requests of all channels
+func (m *Manager) worker() {
+	defer func() {
+		// request sockets of channels opened by worker
+		for _, c := range m.clients {
+			if c.connReq != nil {
+				c.connReq.Close()
+			}
+		}
+		close(m.done)
+	}()
+	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			for _, c := range m.clients {
+				if c.ctx.Err() == nil {
+					c.tick()
+				}
+			}
+		case mb := <-m.ch:
+			if mb.c.ctx.Err() == nil {
+				mb.c.doMsg(&mb.msgBuf)
+			}
+		case res := <-m.statC:
+			res <- m.stats()
+		}
+	}
+}
+
+// Stats	statistics of each channel
+func (m *Manager) Stats() []SessionStats {
+	res := make(chan []SessionStats, 1)
+	select {
+	case m.statC <- res:
+		return <-res
+	case <-m.done:
+		return m.stats()
+	}
+}
+
+// stats	called by worker, or after worker exited
+func (m *Manager) stats() []SessionStats {
+	res := make([]SessionStats, len(m.clients))
+	for i, c := range m.clients {
+		res[i] = SessionStats{Name: c.name, SeqMax: c.seqMax, ClientStats: c.Stats()}
+		res[i].SeqNo = c.seqNo
+	}
+	return res
+}
+
+// ConnStats	statistics of each shared receive socket, named by its
+//			group:port list
+func (m *Manager) ConnStats() []ConnStats {
+	res := make([]ConnStats, len(m.socks))
+	for i, sock := range m.socks {
+		res[i] = GetConnStats(sock.conn)
+		res[i].Name = sock.name()
+	}
+	return res
+}
+
+// name	group:port of each group joined, unique of sockets
+func (sock *mgrSock) name() string {
+	keys := make([]string, len(sock.groups))
+	for i, ip := range sock.groups {
+		keys[i] = memKey(ip, sock.port)
+	}
+	return strings.Join(keys, ",")
+}
+
+// DumpStats	log statistics of each channel
+func (m *Manager) DumpStats() {
+	log.Infof("Manager sockets: %d, channels: %d, unknown session: %d",
+		len(m.socks), len(m.clients), atomic.LoadInt64(&m.nUnknown))
+	for _, st := range m.Stats() {
+		log.Infof("Channel %s(%s) Recv: %d seqNo: %d/%d, error: %d, missed: %d"+
+			", Request: %d/%d, cache merge: %d", st.Name, st.Session, st.Recvs,
+			st.SeqNo, st.SeqMax, st.Errors, st.Missed, st.Requests, st.Repeats,
+			st.Merges)
+	}
+}
@@ -0,0 +1,291 @@
+package MoldUDP
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// dropSend	McastConn drop nth packet sent if drop returns true
+type dropSend struct {
+	McastConn
+	drop func(int, Packet) bool
+	n    int
+}
+
+func (c *dropSend) Send(buff []byte) (int, error) {
+	n := c.n
+	c.n++
+	if c.drop(n, buff) {
+		return len(buff), nil
+	}
+	return c.McastConn.Send(buff)
+}
+
+func TestMemIfJoin(t *testing.T) {
+	group1, group2 := net.IPv4(239, 192, 168, 20), net.IPv4(239, 192, 168, 21)
+	rcv := NewIf("mem")
+	if err := rcv.Open(group1, 5858, nil, nil); err != nil {
+		t.Fatal("Open", err)
+	}
+	defer rcv.Close()
+	if err := rcv.(mcastJoiner).Join(group2, nil, nil); err != nil {
+		t.Fatal("Join", err)
+	}
+	if err := rcv.(mcastJoiner).Join(group2, net.IPv4(10, 0, 0, 1), nil); err != errSource {
+		t.Errorf("Join() other source error = %v, want %v", err, errSource)
+	}
+	for _, group := range []net.IP{group1, group2} {
+		snd := NewIf("mem")
+		if err := snd.OpenSend(group, 5858, false, nil); err != nil {
+			t.Fatal("OpenSend", err)
+		}
+		snd.Send([]byte(group.String()))
+		snd.Close()
+	}
+	if got := memRecvAll(t, rcv, 2); fmt.Sprint(got) != "[239.192.168.20 239.192.168.21]" {
+		t.Errorf("Recv() = %v", got)
+	}
+	rcv.Close()
+	memGroups.lock.Lock()
+	defer memGroups.lock.Unlock()
+	for _, group := range []net.IP{group1, group2} {
+		if n := len(memGroups.rcvs[memKey(group, 5858)]); n != 0 {
+			t.Errorf("%d receivers of %v after Close", n, group)
+		}
+	}
+}
+
+func TestManagerChannels(t *testing.T) {
+	tests := []struct {
+		name  string
+		chans []Channel
+		socks int
+		err   error
+	}{
+		{"no channel", nil, 0, errNoChannel},
+		{"duplicate session", []Channel{
+			{Addr: "239.192.168.22", Port: 6310, Session: "sessA"},
+			{Addr: "239.192.168.22", Port: 6310, Session: "sessA"}}, 0, errDupChannel},
+		{"duplicate after other group", []Channel{
+			{Addr: "239.192.168.23", Port: 6310, Session: "sessA"},
+			{Addr: "239.192.168.22", Port: 6310, Session: "sessB"},
+			{Addr: "239.192.168.22", Port: 6310, Session: "sessB"}}, 0, errDupChannel},
+		{"any session of groups", []Channel{
+			{Addr: "239.192.168.22", Port: 6310},
+			{Addr: "239.192.168.23", Port: 6310}}, 2, nil},
+		{"any and named session", []Channel{
+			{Addr: "239.192.168.22", Port: 6310},
+			{Addr: "239.192.168.22", Port: 6310, Session: "sessA"}}, 1, nil},
+		{"named sessions of groups", []Channel{
+			{Addr: "239.192.168.22", Port: 6310, Session: "sessA"},
+			{Addr: "239.192.168.23", Port: 6310, Session: "sessB"},
+			{Addr: "239.192.168.24", Port: 6310, Session: "sessA"}}, 2, nil},
+	}
+	for _, tt := range tests {
+		m, err := NewManager(context.Background(), tt.chans, "mem", &Option{}, false)
+		if err != tt.err {
+			t.Errorf("%s: NewManager() error = %v, want %v", tt.name, err, tt.err)
+		}
+		if m != nil {
+			if len(m.socks) != tt.socks {
+				t.Errorf("%s: %d sockets, want %d", tt.name, len(m.socks), tt.socks)
+			}
+			m.Close()
+		}
+		// no group left joined
+		memGroups.lock.Lock()
+		for _, ch := range tt.chans {
+			if n := len(memGroups.rcvs[memKey(net.ParseIP(ch.Addr), ch.Port)]); n != 0 {
+				t.Errorf("%s: %d receivers of %s after Close", tt.name, n, ch.Addr)
+			}
+		}
+		memGroups.lock.Unlock()
+	}
+}
+
+func TestManager(t *testing.T) {
+	const port = 6300
+	store := NewMemStore(1)
+	rr, err := NewRewinder("sessA", 0, store)
+	if err != nil {
+		t.Fatal("NewRewinder", err)
+	}
+	go rr.Serve()
+	defer rr.Close()
+	chans := []Channel{
+		{Addr: "239.192.168.24", Port: port, Session: "sessA",
+			Srvs: []string{fmt.Sprintf("127.0.0.1:%d", rr.LocalAddr().Port)}},
+		{Addr: "239.192.168.24", Port: port, Session: "sessB"},
+		{Addr: "239.192.168.25", Port: port, Session: "sessC"},
+		{Name: "any", Addr: "239.192.168.26", Port: port + 2},
+	}
+	m, err := NewManager(context.Background(), chans, "mem", &Option{}, false)
+	if err != nil {
+		t.Fatal("NewManager", err)
+	}
+	defer m.Close()
+	if len(m.socks) != 2 {
+		t.Errorf("%d sockets, want 2", len(m.socks))
+	}
+	srvs := []struct {
+		group   string
+		port    int
+		session string
+		drop    []uint64
+	}{
+		{"239.192.168.24", port, "sessA", []uint64{30, 55}},
+		{"239.192.168.24", port, "sessB", nil},
+		{"239.192.168.25", port, "sessC", nil},
+		{"239.192.168.26", port + 2, "sessD", nil},
+		// unknown session on shared socket
+		{"239.192.168.25", port, "sessX", nil},
+	}
+	msgs := make([]Message, 100)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	for _, ss := range srvs {
+		conn := NewIf("mem")
+		if ss.drop != nil {
+			conn = &dropSend{McastConn: conn, drop: dropSeq(ss.drop...)}
+		}
+		srv, err := NewServer(ss.group, ss.port, ss.session, &Option{}, conn, false)
+		if err != nil {
+			t.Fatal("NewServer", err)
+		}
+		if ss.session == "sessA" {
+			srv.SetStore(store)
+		}
+		go func() {
+			for i := 0; i < len(msgs); i += 10 {
+				srv.Send(msgs[i : i+10])
+			}
+			srv.Close()
+		}()
+	}
+	var wg sync.WaitGroup
+	for _, c := range m.Clients() {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			tm := time.AfterFunc(5*time.Second, c.Stop)
+			defer tm.Stop()
+			seqNo := uint64(1)
+			err := c.Subscribe(func(sn uint64, msg Message) {
+				if sn != seqNo || string(msg.Data) != string(msgs[seqNo-1].Data) {
+					t.Errorf("%s: message %d: %s, want %d", c.name, sn, msg.Data, seqNo)
+				}
+				seqNo++
+			})
+			if err != ErrEndOfSession || seqNo != uint64(len(msgs))+1 {
+				t.Errorf("%s: Subscribe() %v, got %d messages", c.name, err, seqNo-1)
+			}
+		}(c)
+	}
+	wg.Wait()
+	if m.Client("any") == nil || m.Client("sessB") == nil {
+		t.Error("Client() by name not found")
+	}
+	stats := m.Stats()
+	wantSession := []string{"sessA", "sessB", "sessC", "sessD"}
+	for i, st := range stats {
+		if st.Session != wantSession[i] || st.SeqNo != uint64(len(msgs))+1 {
+			t.Errorf("channel %s: session %s seqNo %d", st.Name, st.Session, st.SeqNo)
+		}
+		if bReq := st.Requests != 0; bReq != (i == 0) {
+			t.Errorf("channel %s: %d requests", st.Name, st.Requests)
+		}
+	}
+	if atomic.LoadInt64(&m.nUnknown) == 0 {
+		t.Error("packets of unknown session not counted")
+	}
+	m.DumpStats()
+}
+
+func TestManagerSameSession(t *testing.T) {
+	const port = 6320
+	// same session on other port or other group of port
+	feeds := []struct {
+		addr string
+		port int
+	}{
+		{"239.192.168.27", port},
+		{"239.192.168.27", port + 2},
+		{"239.192.168.28", port},
+	}
+	msgs := make([][]Message, len(feeds))
+	chans := make([]Channel, len(feeds))
+	srvs := make([]*Server, len(feeds))
+	for i, ff := range feeds {
+		msgs[i] = make([]Message, 100)
+		for j := range msgs[i] {
+			msgs[i][j].Data = []byte(fmt.Sprintf("%s:%d message %d", ff.addr,
+				ff.port, j+1))
+		}
+		store := NewMemStore(1)
+		rr, err := NewRewinder("sess01", 0, store)
+		if err != nil {
+			t.Fatal("NewRewinder", err)
+		}
+		go rr.Serve()
+		defer rr.Close()
+		chans[i] = Channel{Name: fmt.Sprintf("feed%d", i), Addr: ff.addr,
+			Port: ff.port, Session: "sess01",
+			Srvs: []string{fmt.Sprintf("127.0.0.1:%d", rr.LocalAddr().Port)}}
+		conn := &dropSend{McastConn: NewIf("mem"), drop: dropSeq(15, 65)}
+		srv, err := NewServer(ff.addr, ff.port, "sess01", &Option{}, conn, false)
+		if err != nil {
+			t.Fatal("NewServer", err)
+		}
+		srv.SetStore(store)
+		srvs[i] = srv
+	}
+	m, err := NewManager(context.Background(), chans, "mem", &Option{}, false)
+	if err != nil {
+		t.Fatal("NewManager", err)
+	}
+	defer m.Close()
+	if len(m.socks) != len(feeds) {
+		t.Errorf("%d sockets, want %d", len(m.socks), len(feeds))
+	}
+	for i, srv := range srvs {
+		go func(srv *Server, msgs []Message) {
+			for j := 0; j < len(msgs); j += 10 {
+				srv.Send(msgs[j : j+10])
+			}
+			srv.Close()
+		}(srv, msgs[i])
+	}
+	var wg sync.WaitGroup
+	for i, c := range m.Clients() {
+		wg.Add(1)
+		go func(c *Client, msgs []Message) {
+			defer wg.Done()
+			tm := time.AfterFunc(5*time.Second, c.Stop)
+			defer tm.Stop()
+			seqNo := uint64(1)
+			err := c.Subscribe(func(sn uint64, msg Message) {
+				if sn != seqNo || string(msg.Data) != string(msgs[seqNo-1].Data) {
+					t.Errorf("%s: message %d: %s, want %s", c.name, sn, msg.Data,
+						msgs[seqNo-1].Data)
+				}
+				seqNo++
+			})
+			if err != ErrEndOfSession || seqNo != uint64(len(msgs))+1 {
+				t.Errorf("%s: Subscribe() %v, got %d messages", c.name, err, seqNo-1)
+			}
+		}(c, msgs[i])
+	}
+	wg.Wait()
+	for _, st := range m.Stats() {
+		if st.Requests == 0 || st.Retrans == 0 {
+			t.Errorf("channel %s: %d requests, %d retransmissions", st.Name,
+				st.Requests, st.Retrans)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package MoldUDP
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
 type Packet []byte
@@ -17,7 +20,8 @@ const (
 type McastConn interface {
 	Enabled(opts int) bool
 	Close() error
-	Open(ip net.IP, port int, ifn *net.Interface) error
+	// Open	join group ip:port, only packets from src if not nil(SSM)
+	Open(ip net.IP, port int, src net.IP, ifn *net.Interface) error
 	OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface) error
 	Send(buff []byte) (int, error)
 	Recv(buff []byte) (int, *net.UDPAddr, error)
@@ -31,6 +35,7 @@ var (
 	errOpened     = errors.New("Already opened")
 	errModeRW     = errors.New("Open/OpenSend for Recv/Send")
 	errUDPlen     = errors.New("UDP payload length error")
+	errSource     = errors.New("Invalid SSM source address")
 )
 
 type netIf struct {
@@ -39,17 +44,61 @@ type netIf struct {
 	adr   net.UDPAddr
 }
 
+// ConnStats	statistics of McastConn receiving
+//	Name	line or socket of McastConn
+//	Drops	packets dropped before received, by kernel or impairment
+//	RcvBuf	bytes of socket receive buffer, 0 if unknown
+type ConnStats struct {
+	Name   string
+	Drops  uint64
+	RcvBuf int
+}
+
+// connStater	McastConn with drop counter or socket buffer
+type connStater interface {
+	connStats() ConnStats
+}
+
+// GetConnStats	statistics of conn, zero if not supported by conn
+func GetConnStats(conn McastConn) ConnStats {
+	if cs, ok := conn.(connStater); ok {
+		return cs.connStats()
+	}
+	return ConnStats{}
+}
+
+// sockRcvBuf	SO_RCVBUF of socket fd, 0 if failed
+func sockRcvBuf(fd int) int {
+	if bl, err := GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF); err == nil {
+		return bl
+	}
+	return 0
+}
+
 type ifFuncType func() McastConn
 
 var ifFuncMap = map[string]ifFuncType{}
 
+// ifArg	interface with argument given by netMode "name:arg"
+type ifArg interface {
+	setArg(arg string)
+}
+
+// NewIf	McastConn for netMode, net/sock/zsock or pcap:fileName
 func NewIf(netMode string) (netif McastConn) {
 	if netMode == "net" {
 		netif = newNetIf()
 		return
 	}
+	var arg string
+	if i := strings.IndexByte(netMode, ':'); i > 0 {
+		netMode, arg = netMode[:i], netMode[i+1:]
+	}
 	if funcPtr, ok := ifFuncMap[netMode]; ok {
 		netif = funcPtr()
+		if ia, ok := netif.(ifArg); ok && arg != "" {
+			ia.setArg(arg)
+		}
 		return
 	}
 	netif = newNetIf()
@@ -73,6 +122,19 @@ func (c *netIf) String() string {
 	return "net Intf"
 }
 
+func (c *netIf) connStats() (res ConnStats) {
+	conn := c.conn
+	if conn == nil {
+		return
+	}
+	if rc, err := conn.SyscallConn(); err == nil {
+		rc.Control(func(fd uintptr) {
+			res.RcvBuf = sockRcvBuf(int(fd))
+		})
+	}
+	return
+}
+
 func (c *netIf) Close() error {
 	if c.conn == nil {
 		return errClosed
@@ -84,23 +146,43 @@ func (c *netIf) Close() error {
 
 const maxDatagramSize = 8192
 
-func (c *netIf) Open(ip net.IP, port int, ifn *net.Interface) (err error) {
+func (c *netIf) Open(ip net.IP, port int, src net.IP, ifn *net.Interface) (err error) {
 	if c.conn != nil {
 		return errOpened
 	}
+	network := "udp4"
+	if isIPv6(ip) {
+		network = "udp6"
+	}
 	// Parse the string address
-	addr, err := net.ResolveUDPAddr("udp4", fmt.Sprintf("%s:%d",ip.String(), port))
+	addr, err := net.ResolveUDPAddr(network, net.JoinHostPort(ip.String(),
+		strconv.Itoa(port)))
 	if err != nil {
 		return err
 	}
 
+	if src, err = sourceAddr(ip, src); err != nil {
+		return err
+	}
 	// Open up a connection
-	c.conn, err = net.ListenMulticastUDP("udp4", nil, addr)
+	if src != nil {
+		c.conn, err = listenSource(network, addr, src, ifn)
+	} else {
+		c.conn, err = net.ListenMulticastUDP(network, ifn, addr)
+	}
 	if err != nil {
 		return err
 	}
 
 	c.conn.SetReadBuffer(maxDatagramSize)
+	// other groups on port received by socket of each group
+	if rc, err := c.conn.SyscallConn(); err == nil {
+		rc.Control(func(fd uintptr) {
+			if err := noMulticastAll(int(fd), isIPv6(ip)); err != nil {
+				log.Info("unset multicast all", err)
+			}
+		})
+	}
 
 	c.bRead = true
 	c.adr.IP = ip
@@ -129,17 +211,49 @@ func (c *netIf) Open(ip net.IP, port int, ifn *net.Interface) (err error) {
 	return nil
 }
 
+// listenSource	listen on group addr, join with source src(SSM)
+func listenSource(network string, addr *net.UDPAddr, src net.IP, ifn *net.Interface) (*net.UDPConn, error) {
+	var errJoin error
+	lc := net.ListenConfig{Control: func(_, _ string, rc syscall.RawConn) error {
+		err := rc.Control(func(fd uintptr) {
+			SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+			noMulticastAll(int(fd), isIPv6(addr.IP))
+			if isIPv6(addr.IP) {
+				errJoin = JoinSourceMulticast6(int(fd), addr.IP, src, ifn)
+			} else {
+				errJoin = JoinSourceMulticast(int(fd), addr.IP.To4(), src, ifn)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return errJoin
+	}}
+	conn, err := lc.ListenPacket(context.Background(), network, addr.String())
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Join SSM group %s from %s", addr.IP, src)
+	return conn.(*net.UDPConn), nil
+}
+
 func (c *netIf) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface) (err error) {
 	if c.conn != nil {
 		return errOpened
 	}
 	var fd int = -1
+	bIPv6 := isIPv6(ip)
+	network := "udp4"
 	laddr := net.UDPAddr{IP: net.IPv4(0, 0, 0, 0), Port: port}
+	if bIPv6 {
+		network = "udp6"
+		laddr.IP = net.IPv6unspecified
+	}
 	if bLoop {
 		// let system allc port
 		laddr.Port = 0
 	}
-	c.conn, err = net.ListenUDP("udp4", &laddr)
+	c.conn, err = net.ListenUDP(network, &laddr)
 	if err != nil {
 		return err
 	}
@@ -161,6 +275,17 @@ func (c *netIf) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface) (e
 		}
 	*/
 	log.Infof("Try Multicast %s:%d", ip, port)
+	if bIPv6 {
+		if err := SetMulticastInterface6(fd, ifn); err != nil {
+			log.Info("set multicast interface", err)
+		}
+		if bLoop {
+			if err := SetMulticastLoop6(fd, true); err != nil {
+				log.Info("set multicast loopback", err)
+			}
+		}
+		return
+	}
 	if err := SetMulticastInterface(fd, ifn); err != nil {
 		log.Info("set multicast interface", err)
 	}
@@ -0,0 +1,253 @@
+package MoldUDP
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const memQueueLen = 4096
+
+// MemOption	impairment of packets received via mem interface, Loss,
+//			Reorder, Dup and Delay applied by Impairment wrapper
+//	Loss	probability of packet dropped
+//	Reorder	probability of packet delivered after the next one
+//	Dup		probability of packet delivered twice
+//	Delay	delay of each packet delivered
+//	Drop	drop nth(0 based) packet sent to group if returns true
+//	Seed	seed of random, same seed for same impairment
+type MemOption struct {
+	Loss    float64
+	Reorder float64
+	Dup     float64
+	Delay   time.Duration
+	Drop    func(n int, pkt Packet) bool
+	Seed    int64
+}
+
+type memPkt struct {
+	pkt Packet
+	src *net.UDPAddr
+}
+
+// memIf	in process McastConn, packets sent delivered to all receivers
+//			opened on same group and port
+type memIf struct {
+	drop   func(n int, pkt Packet) bool
+	key    string
+	keys   []string
+	port   int
+	src    net.IP
+	bRead  bool
+	local  net.UDPAddr
+	lock   sync.Mutex
+	rx     chan memPkt
+	nPkts  int
+	nDrops int
+	done   chan struct{}
+	closed int32
+}
+
+var memGroups = struct {
+	lock sync.Mutex
+	rcvs map[string][]*memIf
+}{rcvs: map[string][]*memIf{}}
+
+func newMemIf() McastConn {
+	return &memIf{}
+}
+
+// NewMemIf	in process McastConn with impairment opt for packets received,
+//			wrapped by NewImpairIf for Loss, Reorder, Dup or Delay
+func NewMemIf(opt *MemOption) McastConn {
+	c := memIf{}
+	if opt == nil {
+		return &c
+	}
+	c.drop = opt.Drop
+	imp := Impairment{Drop: opt.Loss, Reorder: opt.Reorder, Dup: opt.Dup,
+		Delay: opt.Delay, Seed: opt.Seed}
+	if imp.Enabled() {
+		return NewImpairIf(&c, &imp)
+	}
+	return &c
+}
+
+func init() {
+	registerIf("mem", newMemIf)
+}
+
+func (c *memIf) Enabled(opts int) bool {
+	return false
+}
+
+func (c *memIf) String() string {
+	return "mem Intf"
+}
+
+func memKey(ip net.IP, port int) string {
+	return net.JoinHostPort(ip.String(), strconv.Itoa(port))
+}
+
+// connStats	packets dropped by drop or full queue
+func (c *memIf) connStats() ConnStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return ConnStats{Drops: uint64(c.nDrops)}
+}
+
+func (c *memIf) Close() error {
+	if c.done == nil || !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return errClosed
+	}
+	if c.bRead {
+		memGroups.lock.Lock()
+		for _, key := range c.keys {
+			rcvs := memGroups.rcvs[key]
+			for i := range rcvs {
+				if rcvs[i] == c {
+					memGroups.rcvs[key] = append(rcvs[:i:i], rcvs[i+1:]...)
+					break
+				}
+			}
+		}
+		memGroups.lock.Unlock()
+	}
+	close(c.done)
+	return nil
+}
+
+func (c *memIf) Open(ip net.IP, port int, src net.IP, ifn *net.Interface) error {
+	if c.done != nil {
+		return errOpened
+	}
+	var err error
+	if c.src, err = sourceAddr(ip, src); err != nil {
+		return err
+	}
+	c.key = memKey(ip, port)
+	c.port = port
+	c.bRead = true
+	c.rx = make(chan memPkt, memQueueLen)
+	c.done = make(chan struct{})
+	c.addKey(c.key)
+	return nil
+}
+
+// Join	receive group ip on port of Open also, src same as Open
+func (c *memIf) Join(ip net.IP, src net.IP, ifn *net.Interface) error {
+	if !c.bRead || c.done == nil {
+		return errModeRW
+	}
+	if src, err := sourceAddr(ip, src); err != nil || !src.Equal(c.src) {
+		return errSource
+	}
+	c.addKey(memKey(ip, c.port))
+	return nil
+}
+
+func (c *memIf) addKey(key string) {
+	memGroups.lock.Lock()
+	c.keys = append(c.keys, key)
+	memGroups.rcvs[key] = append(memGroups.rcvs[key], c)
+	memGroups.lock.Unlock()
+}
+
+// OpenSend	packets sent from 127.0.0.1:port+1 or [::1]:port+1, as source
+//			port of buildUDP
+func (c *memIf) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface) error {
+	if c.done != nil {
+		return errOpened
+	}
+	c.key = memKey(ip, port)
+	c.bRead = false
+	c.local = net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port + 1}
+	if isIPv6(ip) {
+		c.local.IP = net.IPv6loopback
+	}
+	c.done = make(chan struct{})
+	return nil
+}
+
+// deliver	queue packet not dropped by drop, dropped if queue full
+func (c *memIf) deliver(pkt Packet, src *net.UDPAddr) {
+	if c.src != nil && !c.src.Equal(src.IP) {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	n := c.nPkts
+	c.nPkts++
+	if c.drop != nil && c.drop(n, pkt) {
+		c.nDrops++
+		return
+	}
+	c.queue(memPkt{pkt: pkt, src: src})
+}
+
+func (c *memIf) queue(mp memPkt) {
+	select {
+	case c.rx <- mp:
+	default:
+		c.nDrops++
+	}
+}
+
+func (c *memIf) Send(buff []byte) (int, error) {
+	if c.bRead {
+		return 0, errModeRW
+	}
+	if c.done == nil || atomic.LoadInt32(&c.closed) != 0 {
+		return 0, errClosed
+	}
+	pkt := append(Packet{}, buff...)
+	memGroups.lock.Lock()
+	for _, r := range memGroups.rcvs[c.key] {
+		r.deliver(pkt, &c.local)
+	}
+	memGroups.lock.Unlock()
+	return len(buff), nil
+}
+
+func (c *memIf) MSend(buffs []Packet) (int, error) {
+	for i, buf := range buffs {
+		if _, err := c.Send(buf); err != nil {
+			return i, err
+		}
+	}
+	return len(buffs), nil
+}
+
+// Recv		next packet delivered, blocks till Close
+func (c *memIf) Recv(buff []byte) (int, *net.UDPAddr, error) {
+	if !c.bRead {
+		return 0, nil, errModeRW
+	}
+	if c.done == nil {
+		return 0, nil, errClosed
+	}
+	select {
+	case mp := <-c.rx:
+		rAddr := *mp.src
+		return copy(buff, mp.pkt), &rAddr, nil
+	case <-c.done:
+		return 0, nil, errClosed
+	}
+}
+
+func (c *memIf) MRecv() ([]Packet, *net.UDPAddr, error) {
+	return nil, nil, errNotSupport
+}
+
+func (c *memIf) Listen(fx func([]byte, *net.UDPAddr)) {
+	buff := make([]byte, maxDatagramSize)
+	for {
+		n, rAddr, err := c.Recv(buff)
+		if err != nil {
+			return
+		}
+		fx(buff[:n], rAddr)
+	}
+}
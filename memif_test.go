@@ -0,0 +1,184 @@
+package MoldUDP
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func memRecvAll(t *testing.T, conn McastConn, want int) []string {
+	var res []string
+	buff := make([]byte, 256)
+	for len(res) < want {
+		n, _, err := conn.Recv(buff)
+		if err != nil {
+			t.Fatal("Recv", err)
+		}
+		res = append(res, string(buff[:n]))
+	}
+	return res
+}
+
+func TestMemIf(t *testing.T) {
+	group := net.IPv4(239, 192, 168, 10)
+	tests := []struct {
+		name string
+		opt  *MemOption
+		want []string
+	}{
+		{"plain", nil, []string{"0", "1", "2", "3", "4", "5"}},
+		{"drop", &MemOption{Drop: func(n int, pkt Packet) bool { return n%3 == 1 }},
+			[]string{"0", "2", "3", "5"}},
+		{"loss", &MemOption{Loss: 1}, nil},
+		{"dup", &MemOption{Dup: 1},
+			[]string{"0", "0", "1", "1", "2", "2", "3", "3", "4", "4", "5", "5"}},
+		{"reorder", &MemOption{Reorder: 1}, []string{"1", "0", "3", "2", "5", "4"}},
+		{"delay", &MemOption{Delay: 20 * time.Millisecond},
+			[]string{"0", "1", "2", "3", "4", "5"}},
+	}
+	var rcvs []McastConn
+	for _, tt := range tests {
+		conn := NewMemIf(tt.opt)
+		if err := conn.Open(group, 5858, nil, nil); err != nil {
+			t.Fatal("Open", err)
+		}
+		defer conn.Close()
+		rcvs = append(rcvs, conn)
+	}
+	snd := NewIf("mem")
+	if err := snd.OpenSend(group, 5858, false, nil); err != nil {
+		t.Fatal("OpenSend", err)
+	}
+	defer snd.Close()
+	tm := time.Now()
+	for i := 0; i < 6; i++ {
+		snd.Send([]byte(fmt.Sprint(i)))
+	}
+	for i, tt := range tests {
+		got := memRecvAll(t, rcvs[i], len(tt.want))
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.opt != nil && tt.opt.Delay > time.Since(tm) {
+			t.Errorf("%s: recv before delay %v", tt.name, tt.opt.Delay)
+		}
+		nQueued := 0
+		switch c := rcvs[i].(type) {
+		case *memIf:
+			nQueued = len(c.rx)
+		case *impairIf:
+			// wait packets of mem impaired
+			inner := c.conn.(*memIf)
+			for deadline := time.Now().Add(time.Second); len(inner.rx) > 0 &&
+				time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+			}
+			c.lock.Lock()
+			nQueued = len(c.rx) + len(c.held) + len(inner.rx)
+			c.lock.Unlock()
+		}
+		if nQueued != 0 {
+			t.Errorf("%s: %d more packets queued", tt.name, nQueued)
+		}
+	}
+	rcvs[0].Close()
+	if _, _, err := rcvs[0].Recv(make([]byte, 16)); err != errClosed {
+		t.Errorf("Recv() after Close error = %v, want %v", err, errClosed)
+	}
+}
+
+// dropSeq	drop data packets contain any sequence number of seqs
+func dropSeq(seqs ...uint64) func(int, Packet) bool {
+	return func(n int, pkt Packet) bool {
+		var head Header
+		if DecodeHead(pkt, &head) != nil || head.MessageCnt == 0 ||
+			head.MessageCnt == 0xffff {
+			return false
+		}
+		for _, seqNo := range seqs {
+			if seqNo >= head.SeqNo && seqNo < head.SeqNo+uint64(head.MessageCnt) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestClientGapFill(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  MemOption
+	}{
+		{"first lost", MemOption{Drop: dropSeq(1)}},
+		{"gaps", MemOption{Drop: dropSeq(30, 31, 150, 390)}},
+		{"tail lost", MemOption{Drop: dropSeq(399)}},
+		{"reorder", MemOption{Reorder: 0.3, Seed: 1}},
+		{"dup", MemOption{Dup: 0.3, Seed: 2}},
+		{"loss", MemOption{Loss: 0.1, Reorder: 0.1, Dup: 0.1, Seed: 3}},
+	}
+	msgs := make([]Message, 400)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	for i, tt := range tests {
+		port := 6000 + 2*i
+		store := NewMemStore(1)
+		rr, err := NewRewinder("", 0, store)
+		if err != nil {
+			t.Fatal("NewRewinder", err)
+		}
+		served := make(chan struct{})
+		go func() {
+			rr.Serve()
+			close(served)
+		}()
+		srv, err := NewServer("239.192.168.11", port, "mem0", &Option{}, NewIf("mem"),
+			false)
+		if err != nil {
+			t.Fatal("NewServer", err)
+		}
+		srv.SetStore(store)
+		opt := Option{Srvs: []string{fmt.Sprintf("127.0.0.1:%d", rr.LocalAddr().Port)}}
+		cc, err := NewClient("239.192.168.11", port, &opt, NewMemIf(&tt.opt), false)
+		if err != nil {
+			t.Fatal("NewClient", err)
+		}
+		go func() {
+			for i := 0; i < len(msgs); i += 20 {
+				srv.Send(msgs[i : i+20])
+			}
+			srv.Close()
+		}()
+		seqNo := uint64(1)
+		tm := time.AfterFunc(5*time.Second, cc.Stop)
+		for {
+			res, seqF, err := cc.Read()
+			if err != nil {
+				if err != ErrEndOfSession {
+					t.Errorf("%s: Read() %v", tt.name, err)
+				}
+				break
+			}
+			if seqF != seqNo {
+				t.Errorf("%s: Read() seqNo = %d, want %d", tt.name, seqF, seqNo)
+			}
+			for _, msg := range res {
+				if string(msg.Data) != string(msgs[seqNo-1].Data) {
+					t.Errorf("%s: message %d = %s", tt.name, seqNo, msg.Data)
+				}
+				seqNo++
+			}
+		}
+		tm.Stop()
+		if seqNo != uint64(len(msgs))+1 {
+			t.Errorf("%s: Read %d messages, want %d", tt.name, seqNo-1, len(msgs))
+		}
+		cc.Close()
+		rr.Close()
+		<-served
+		if rr.nRetrans == 0 && tt.opt.Drop != nil {
+			t.Errorf("%s: no retransmission", tt.name)
+		}
+	}
+}
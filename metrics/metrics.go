@@ -0,0 +1,306 @@
+// Package metrics	Prometheus text format metrics of MoldUDP clients
+//
+// Counters and gauges are snapshots of Client.Stats and ConnStats taken on
+// each scrape, no Prometheus client library required.
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	MoldUDP "github.com/kjx98/go-mold"
+)
+
+// contentType	Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Registry	clients and managers exported, safe for concurrent use
+type Registry struct {
+	lock     sync.Mutex
+	clients  []namedClient
+	managers []namedManager
+}
+
+type namedClient struct {
+	name string
+	c    *MoldUDP.Client
+}
+
+type namedManager struct {
+	name string
+	m    *MoldUDP.Manager
+}
+
+// chanSnap	stats of one channel taken on scrape
+type chanSnap struct {
+	name    string
+	running bool
+	st      MoldUDP.ClientStats
+}
+
+// connSnap	stats of one McastConn, owner is channel or manager
+type connSnap struct {
+	owner string
+	MoldUDP.ConnStats
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// AddClient	export stats of c labeled channel name
+func (r *Registry) AddClient(name string, c *MoldUDP.Client) {
+	r.lock.Lock()
+	r.clients = append(r.clients, namedClient{name: name, c: c})
+	r.lock.Unlock()
+}
+
+// AddManager	export stats of channels of m labeled by their names,
+//				shared sockets labeled channel name
+func (r *Registry) AddManager(name string, m *MoldUDP.Manager) {
+	r.lock.Lock()
+	r.managers = append(r.managers, namedManager{name: name, m: m})
+	r.lock.Unlock()
+}
+
+func (r *Registry) snapshot() ([]chanSnap, []connSnap) {
+	r.lock.Lock()
+	clients := append([]namedClient{}, r.clients...)
+	managers := append([]namedManager{}, r.managers...)
+	r.lock.Unlock()
+	var chans []chanSnap
+	var conns []connSnap
+	for _, nc := range clients {
+		chans = append(chans, chanSnap{name: nc.name, running: nc.c.Running(),
+			st: nc.c.Stats()})
+		for _, cs := range nc.c.ConnStats() {
+			conns = append(conns, connSnap{owner: nc.name, ConnStats: cs})
+		}
+	}
+	for _, nm := range managers {
+		for _, ss := range nm.m.Stats() {
+			cs := chanSnap{name: ss.Name, st: ss.ClientStats}
+			if c := nm.m.Client(ss.Name); c != nil {
+				cs.running = c.Running()
+			}
+			chans = append(chans, cs)
+		}
+		for _, cs := range nm.m.ConnStats() {
+			conns = append(conns, connSnap{owner: nm.name, ConnStats: cs})
+		}
+	}
+	return chans, conns
+}
+
+// WriteTo	write metrics of all registered in Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	chans, conns := r.snapshot()
+	var buf bytes.Buffer
+	writeText(&buf, chans, conns)
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP	serve metrics in Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	r.WriteTo(w)
+}
+
+// Listener	HTTP listener serving /metrics of Registry
+type Listener struct {
+	ln  net.Listener
+	srv *http.Server
+}
+
+// Listen	serve /metrics of r on addr till Listener closed
+func (r *Registry) Listen(addr string) (*Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	l := Listener{ln: ln, srv: &http.Server{Handler: mux,
+		ReadHeaderTimeout: 5 * time.Second}}
+	go l.srv.Serve(ln)
+	return &l, nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+func (l *Listener) Close() error {
+	return l.srv.Close()
+}
+
+// counter	metric family of one value per channel
+type counter struct {
+	name, typ, help string
+	value           func(st *MoldUDP.ClientStats) int64
+}
+
+var chanCounters = []counter{
+	{"moldudp_packets_received_total", "counter",
+		"Packets received by all lines and retransmission.",
+		func(st *MoldUDP.ClientStats) int64 { return st.Recvs }},
+	{"moldudp_retrans_packets_received_total", "counter",
+		"Packets received by retransmission.",
+		func(st *MoldUDP.ClientStats) int64 { return st.Retrans }},
+	{"moldudp_messages_delivered_total", "counter",
+		"Messages delivered in order.",
+		func(st *MoldUDP.ClientStats) int64 { return st.Messages }},
+	{"moldudp_messages_recovered_total", "counter",
+		"Messages from late join or bulk recovery.",
+		func(st *MoldUDP.ClientStats) int64 { return st.Recovered }},
+	{"moldudp_gaps_total", "counter",
+		"Gaps of sequence detected.",
+		func(st *MoldUDP.ClientStats) int64 { return st.Gaps }},
+	{"moldudp_gap_messages_total", "counter",
+		"Messages missing in gaps detected.",
+		func(st *MoldUDP.ClientStats) int64 { return st.GapMessages }},
+	{"moldudp_missed_packets_total", "counter",
+		"Packets received with messages before missing.",
+		func(st *MoldUDP.ClientStats) int64 { return st.Missed }},
+	{"moldudp_naks_sent_total", "counter",
+		"Retransmission requests sent.",
+		func(st *MoldUDP.ClientStats) int64 { return st.Requests }},
+	{"moldudp_repeated_packets_total", "counter",
+		"Packets already received.",
+		func(st *MoldUDP.ClientStats) int64 { return st.Repeats }},
+	{"moldudp_merged_packets_total", "counter",
+		"Packets cached received again.",
+		func(st *MoldUDP.ClientStats) int64 { return st.Merges }},
+	{"moldudp_session_rollovers_total", "counter",
+		"Session rollovers followed.",
+		func(st *MoldUDP.ClientStats) int64 { return st.Rollovers }},
+	{"moldudp_next_seq", "gauge",
+		"Next sequence number to deliver.",
+		func(st *MoldUDP.ClientStats) int64 { return int64(st.SeqNo) }},
+	{"moldudp_cache_messages", "gauge",
+		"Messages cached out of order.",
+		func(st *MoldUDP.ClientStats) int64 { return int64(st.Cache.Cached) }},
+	{"moldudp_cache_capacity", "gauge",
+		"Messages could be cached without growing.",
+		func(st *MoldUDP.ClientStats) int64 { return int64(st.Cache.Capacity) }},
+	{"moldudp_cache_overflow_total", "counter",
+		"Messages beyond cache window dropped.",
+		func(st *MoldUDP.ClientStats) int64 { return int64(st.Cache.Overflow) }},
+}
+
+// errKinds	label of packet error kind
+var errKinds = []struct {
+	kind  string
+	value func(es *MoldUDP.ErrorStats) int64
+}{
+	{"decode", func(es *MoldUDP.ErrorStats) int64 { return es.Decode }},
+	{"session", func(es *MoldUDP.ErrorStats) int64 { return es.Session }},
+	{"message_cnt", func(es *MoldUDP.ErrorStats) int64 { return es.MessageCnt }},
+	{"unmarshal", func(es *MoldUDP.ErrorStats) int64 { return es.Unmarshal }},
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels	label pairs of name, value formatted
+func labels(kv ...string) string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(kv[i])
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(kv[i+1]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func writeHead(buf *bytes.Buffer, name, typ, help string) {
+	buf.WriteString("# HELP " + name + " " + help + "\n")
+	buf.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+func writeSample(buf *bytes.Buffer, name, lbls, value string) {
+	buf.WriteString(name)
+	buf.WriteString(lbls)
+	buf.WriteByte(' ')
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
+
+// writeText	families of metrics, samples of all channels grouped
+func writeText(buf *bytes.Buffer, chans []chanSnap, conns []connSnap) {
+	name := "moldudp_session_up"
+	writeHead(buf, name, "gauge", "Client running, 0 after stopped or end of session.")
+	for i := range chans {
+		up := "0"
+		if chans[i].running {
+			up = "1"
+		}
+		writeSample(buf, name, labels("channel", chans[i].name), up)
+	}
+	name = "moldudp_session_info"
+	writeHead(buf, name, "gauge", "Current session of channel.")
+	for i := range chans {
+		writeSample(buf, name, labels("channel", chans[i].name, "session",
+			chans[i].st.Session), "1")
+	}
+	for _, ct := range chanCounters {
+		writeHead(buf, ct.name, ct.typ, ct.help)
+		for i := range chans {
+			writeSample(buf, ct.name, labels("channel", chans[i].name),
+				strconv.FormatInt(ct.value(&chans[i].st), 10))
+		}
+	}
+	name = "moldudp_packet_errors_total"
+	writeHead(buf, name, "counter", "Packets with error by kind.")
+	for i := range chans {
+		for _, ek := range errKinds {
+			writeSample(buf, name, labels("channel", chans[i].name, "kind", ek.kind),
+				strconv.FormatInt(ek.value(&chans[i].st.ErrorKinds), 10))
+		}
+	}
+	name = "moldudp_retrans_latency_seconds"
+	writeHead(buf, name, "histogram",
+		"Retransmission latency from first request to gap filled.")
+	for i := range chans {
+		lat := &chans[i].st.Latency
+		var cum int64
+		for j, bound := range lat.Bounds {
+			cum += lat.Counts[j]
+			writeSample(buf, name+"_bucket", labels("channel", chans[i].name,
+				"le", seconds(bound)), strconv.FormatInt(cum, 10))
+		}
+		lbls := labels("channel", chans[i].name)
+		writeSample(buf, name+"_bucket", labels("channel", chans[i].name,
+			"le", "+Inf"), strconv.FormatInt(lat.Count, 10))
+		writeSample(buf, name+"_sum", lbls, seconds(lat.Sum))
+		writeSample(buf, name+"_count", lbls, strconv.FormatInt(lat.Count, 10))
+	}
+	name = "moldudp_conn_drops_total"
+	writeHead(buf, name, "counter",
+		"Packets dropped before received, by kernel or impairment.")
+	for i := range conns {
+		writeSample(buf, name, labels("channel", conns[i].owner, "line",
+			conns[i].Name), strconv.FormatUint(conns[i].Drops, 10))
+	}
+	name = "moldudp_conn_rcvbuf_bytes"
+	writeHead(buf, name, "gauge", "Receive buffer of socket, 0 if unknown.")
+	for i := range conns {
+		writeSample(buf, name, labels("channel", conns[i].owner, "line",
+			conns[i].Name), strconv.Itoa(conns[i].RcvBuf))
+	}
+}
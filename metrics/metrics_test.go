@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	MoldUDP "github.com/kjx98/go-mold"
+)
+
+// parseText	value of each sample line by name with labels
+func parseText(t *testing.T, text string) map[string]string {
+	res := map[string]string{}
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			t.Fatalf("bad sample line: %q", line)
+		}
+		res[line[:i]] = line[i+1:]
+	}
+	return res
+}
+
+func TestWriteText(t *testing.T) {
+	st := MoldUDP.ClientStats{Session: "sess01", SeqNo: 101, Recvs: 12,
+		Retrans: 2, Messages: 100, Gaps: 1, GapMessages: 10, Requests: 1,
+		ErrorKinds: MoldUDP.ErrorStats{Session: 3},
+		Latency: MoldUDP.LatencyHist{
+			Bounds: []time.Duration{time.Millisecond, 10 * time.Millisecond},
+			Counts: []int64{1, 2, 1}, Count: 4, Sum: 1500 * time.Millisecond}}
+	chans := []chanSnap{{name: `feed"A"`, running: true, st: st}}
+	conns := []connSnap{{owner: `feed"A"`,
+		ConnStats: MoldUDP.ConnStats{Name: "A", Drops: 5, RcvBuf: 212992}}}
+	var buf bytes.Buffer
+	writeText(&buf, chans, conns)
+	res := parseText(t, buf.String())
+	tests := []struct {
+		sample string
+		value  string
+	}{
+		{`moldudp_session_up{channel="feed\"A\""}`, "1"},
+		{`moldudp_session_info{channel="feed\"A\"",session="sess01"}`, "1"},
+		{`moldudp_packets_received_total{channel="feed\"A\""}`, "12"},
+		{`moldudp_retrans_packets_received_total{channel="feed\"A\""}`, "2"},
+		{`moldudp_messages_delivered_total{channel="feed\"A\""}`, "100"},
+		{`moldudp_gaps_total{channel="feed\"A\""}`, "1"},
+		{`moldudp_naks_sent_total{channel="feed\"A\""}`, "1"},
+		{`moldudp_next_seq{channel="feed\"A\""}`, "101"},
+		{`moldudp_packet_errors_total{channel="feed\"A\"",kind="session"}`, "3"},
+		{`moldudp_packet_errors_total{channel="feed\"A\"",kind="decode"}`, "0"},
+		{`moldudp_retrans_latency_seconds_bucket{channel="feed\"A\"",le="0.001"}`, "1"},
+		{`moldudp_retrans_latency_seconds_bucket{channel="feed\"A\"",le="0.01"}`, "3"},
+		{`moldudp_retrans_latency_seconds_bucket{channel="feed\"A\"",le="+Inf"}`, "4"},
+		{`moldudp_retrans_latency_seconds_sum{channel="feed\"A\""}`, "1.5"},
+		{`moldudp_retrans_latency_seconds_count{channel="feed\"A\""}`, "4"},
+		{`moldudp_conn_drops_total{channel="feed\"A\"",line="A"}`, "5"},
+		{`moldudp_conn_rcvbuf_bytes{channel="feed\"A\"",line="A"}`, "212992"},
+	}
+	for _, tt := range tests {
+		if v, ok := res[tt.sample]; !ok || v != tt.value {
+			t.Errorf("%s = %q, want %q", tt.sample, v, tt.value)
+		}
+	}
+	// one HELP/TYPE per family
+	if n := strings.Count(buf.String(), "# TYPE moldudp_packet_errors_total "); n != 1 {
+		t.Errorf("TYPE of moldudp_packet_errors_total %d times", n)
+	}
+}
+
+func TestListen(t *testing.T) {
+	const port = 6420
+	msgs := make([]MoldUDP.Message, 100)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	store := MoldUDP.NewMemStore(1)
+	rr, err := MoldUDP.NewRewinder("", 0, store)
+	if err != nil {
+		t.Fatal("NewRewinder", err)
+	}
+	go rr.Serve()
+	defer rr.Close()
+	srv, err := MoldUDP.NewServer("239.192.168.20", port, "feed0",
+		&MoldUDP.Option{}, MoldUDP.NewIf("mem"), false)
+	if err != nil {
+		t.Fatal("NewServer", err)
+	}
+	srv.SetStore(store)
+	// drop packet of message 41-60
+	drop := func(n int, pkt MoldUDP.Packet) bool { return n == 2 }
+	feeds := []MoldUDP.Feed{{Addr: "239.192.168.20", Port: port,
+		Conn: MoldUDP.NewMemIf(&MoldUDP.MemOption{Drop: drop})}}
+	opt := MoldUDP.Option{Srvs: []string{fmt.Sprintf("127.0.0.1:%d",
+		rr.LocalAddr().Port)}}
+	cc, err := MoldUDP.NewClientFeeds(context.Background(), feeds, &opt, false)
+	if err != nil {
+		t.Fatal("NewClientFeeds", err)
+	}
+	defer cc.Close()
+	reg := NewRegistry()
+	reg.AddClient("feed0", cc)
+	ln, err := reg.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for i := 0; i < len(msgs); i += 20 {
+			srv.Send(msgs[i : i+20])
+		}
+		srv.Close()
+	}()
+	tm := time.AfterFunc(5*time.Second, cc.Stop)
+	nRecv := 0
+	cc.Subscribe(func(uint64, MoldUDP.Message) { nRecv++ })
+	tm.Stop()
+	if nRecv != len(msgs) {
+		t.Fatalf("received %d messages, want %d", nRecv, len(msgs))
+	}
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal("GET /metrics", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != contentType {
+		t.Errorf("Content-Type %q", ct)
+	}
+	var buf bytes.Buffer
+	buf.ReadFrom(resp.Body)
+	res := parseText(t, buf.String())
+	tests := []struct {
+		sample string
+		value  string
+	}{
+		{`moldudp_session_info{channel="feed0",session="feed0"}`, "1"},
+		{`moldudp_messages_delivered_total{channel="feed0"}`, "100"},
+		{`moldudp_next_seq{channel="feed0"}`, "101"},
+		{`moldudp_gaps_total{channel="feed0"}`, "1"},
+		{`moldudp_gap_messages_total{channel="feed0"}`, "20"},
+		{`moldudp_naks_sent_total{channel="feed0"}`, "1"},
+		{`moldudp_retrans_packets_received_total{channel="feed0"}`, "1"},
+		{`moldudp_retrans_latency_seconds_count{channel="feed0"}`, "1"},
+		{`moldudp_conn_drops_total{channel="feed0",line="A"}`, "1"},
+	}
+	for _, tt := range tests {
+		if v, ok := res[tt.sample]; !ok || v != tt.value {
+			t.Errorf("%s = %q, want %q", tt.sample, v, tt.value)
+		}
+	}
+}
+
+func TestManagerConns(t *testing.T) {
+	// any session on two groups of port, one socket each
+	chans := []MoldUDP.Channel{{Addr: "239.192.168.21", Port: 6422},
+		{Addr: "239.192.168.22", Port: 6422},
+		{Addr: "ff15::1:21", Port: 6422, Session: "feed6"}}
+	m, err := MoldUDP.NewManager(context.Background(), chans, "mem",
+		&MoldUDP.Option{}, false)
+	if err != nil {
+		t.Fatal("NewManager", err)
+	}
+	defer m.Close()
+	reg := NewRegistry()
+	reg.AddManager("mgr", m)
+	chanSt, conns := reg.snapshot()
+	var buf bytes.Buffer
+	writeText(&buf, chanSt, conns)
+	lines := strings.Split(buf.String(), "\n")
+	seen := map[string]bool{}
+	for _, line := range lines {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sample := line[:strings.LastIndexByte(line, ' ')]
+		if seen[sample] {
+			t.Errorf("duplicate sample %s", sample)
+		}
+		seen[sample] = true
+	}
+	for _, line := range []string{"239.192.168.21:6422", "239.192.168.22:6422",
+		"[ff15::1:21]:6422"} {
+		sample := `moldudp_conn_drops_total{channel="mgr",line="` + line + `"}`
+		if !seen[sample] {
+			t.Errorf("%s not found", sample)
+		}
+	}
+}
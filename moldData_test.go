@@ -2,6 +2,8 @@ package MoldUDP
 
 import (
 	"bytes"
+
+	"github.com/kjx98/go-mold/itch"
 )
 
 var head0 = Header{Session: "test0", SeqNo: 1, MessageCnt: 2}
@@ -28,3 +30,20 @@ func init() {
 	msg1.Data = msgBuf0[12:220]
 	msg2.Data = []byte{}
 }
+
+var aaplStock = itch.NewStock("AAPL")
+
+// itchFeed	ITCH 5.0 feed add then delete orders, for protocol level tests
+func itchFeed(n int) []Message {
+	msgs := make([]Message, n)
+	for i := range msgs {
+		ref := uint64(i/2 + 1)
+		if i%2 == 0 {
+			msgs[i].Data = itch.Bytes(&itch.AddOrder{OrderRef: ref, BuySell: 'B',
+				Shares: 100, Stock: aaplStock, Price: 1750000 + uint32(i)})
+		} else {
+			msgs[i].Data = itch.Bytes(&itch.OrderDelete{OrderRef: ref})
+		}
+	}
+	return msgs
+}
@@ -56,6 +56,11 @@ func EncodeHead(buff []byte, head *Header) error {
 }
 
 func DecodeHead(buff []byte, head *Header) error {
+	return decodeHead(buff, head, "")
+}
+
+// decodeHead	DecodeHead with session known reused if same, no allocation
+func decodeHead(buff []byte, head *Header, known string) error {
 	if len(buff) < headSize {
 		return errTooShort
 	}
@@ -67,7 +72,11 @@ func DecodeHead(buff []byte, head *Header) error {
 			break
 		}
 	}
-	head.Session = string(buff[:i])
+	if string(buff[:i]) == known {
+		head.Session = known
+	} else {
+		head.Session = string(buff[:i])
+	}
 	head.SeqNo = coder.Uint64(buff[10:18])
 	head.MessageCnt = coder.Uint16(buff[18:20])
 	return nil
@@ -78,7 +87,11 @@ func Unmarshal(buff []byte, cnt int) (ret []Message, err error) {
 		return
 	}
 	// if cnt < 0 { return nil, errMessageCnt }
-	ret = make([]Message, cnt)
+	return unmarshal(buff, cnt, make([]Message, cnt))
+}
+
+// unmarshal	cnt messages of buff into ret
+func unmarshal(buff []byte, cnt int, ret []Message) ([]Message, error) {
 	n := len(buff)
 	i := 0
 	off := 0
@@ -107,7 +120,7 @@ func Unmarshal(buff []byte, cnt int) (ret []Message, err error) {
 	if off != n {
 		return nil, errUnmarshal
 	}
-	return
+	return ret, nil
 }
 
 func Marshal(buff []byte, msgs []Message) (msgCnt int, bufLen int) {
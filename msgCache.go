@@ -1,96 +1,156 @@
 package MoldUDP
 
-const (
-	maxPageMsg    = 0x100000
-	pageShift     = 20
-	pageIncrement = 16
+import (
+	"sync/atomic"
 )
 
-type msgPage [maxPageMsg]*Message
+const (
+	// minCacheWindow	initial slots of ring, power of 2
+	minCacheWindow = 1 << 12
+	// maxCacheWindow	max sequence numbers cached ahead of base, messages
+	//					beyond not cached and requested again
+	maxCacheWindow = 1 << 20
+)
 
+// msgCache	ring buffer of messages received ahead of Client.seqNo,
+//			slot of seqNo at seqNo & mask, grows by doubling upto
+//			maxCacheWindow unless bOpen, entries merged or below base
+//			evicted
+//	base		next sequence number to deliver, lowest cacheable
+//	cnt			messages cached, maxCnt high water of cnt
+//	nOverflow	messages beyond window not cached
+//	bOpen		window not limited, gap filled by recovery from store
 type msgCache struct {
-	nPage     int
-	maxPageNo int
-	msgPages  []msgPage
+	ring      []*Message
+	mask      uint64
+	base      uint64
+	size      int64
+	cnt       int64
+	maxCnt    int64
+	nOverflow int64
+	bOpen     bool
+}
+
+// CacheStats	occupancy of out of order message cache
+//	Cached		messages cached waiting for gap filled
+//	Capacity	slots of ring buffer
+//	MaxCached	high water of Cached
+//	Overflow	messages beyond window not cached
+type CacheStats struct {
+	Cached    int
+	Capacity  int
+	MaxCached int
+	Overflow  int
 }
 
 func (mc *msgCache) Init() {
-	mc.nPage = pageIncrement
-	mc.msgPages = make([]msgPage, pageIncrement)
+	mc.ring = make([]*Message, minCacheWindow)
+	mc.mask = minCacheWindow - 1
+	mc.base = 0
+	atomic.StoreInt64(&mc.size, minCacheWindow)
+	atomic.StoreInt64(&mc.cnt, 0)
 }
 
-// Upset  update or insert
-//	return true for update
-func (mc *msgCache) Upset(seqNo uint64, msg *Message) bool {
-	page := int(seqNo >> pageShift)
-	off := int(seqNo & (maxPageMsg - 1))
-	if page >= mc.nPage {
-		for page >= mc.nPage {
-			msgPP := make([]msgPage, pageIncrement)
-			mc.msgPages = append(mc.msgPages, msgPP...)
-			mc.nPage += pageIncrement
+// Advance	evict entries below seqNo, base moved to seqNo
+func (mc *msgCache) Advance(seqNo uint64) {
+	if seqNo <= mc.base {
+		return
+	}
+	if atomic.LoadInt64(&mc.cnt) == 0 {
+		mc.base = seqNo
+		return
+	}
+	if seqNo-mc.base >= uint64(len(mc.ring)) {
+		for i := range mc.ring {
+			mc.ring[i] = nil
 		}
+		atomic.StoreInt64(&mc.cnt, 0)
+		mc.base = seqNo
+		return
 	}
-	if page > mc.maxPageNo {
-		mc.maxPageNo = page
+	for ; mc.base < seqNo; mc.base++ {
+		if p := &mc.ring[mc.base&mc.mask]; *p != nil {
+			*p = nil
+			atomic.AddInt64(&mc.cnt, -1)
+		}
 	}
-	ret := mc.msgPages[page][off] != nil
-	mc.msgPages[page][off] = msg
-	return ret
 }
 
-func (mc *msgCache) IsNil(seqNo uint64) bool {
-	page := int(seqNo >> pageShift)
-	off := int(seqNo & (maxPageMsg - 1))
-	if page >= mc.nPage {
-		return true
+// grow		double ring till slot for off from base
+func (mc *msgCache) grow(off uint64) {
+	n := len(mc.ring)
+	for uint64(n) <= off {
+		n <<= 1
+	}
+	ring := make([]*Message, n)
+	mask := uint64(n - 1)
+	for seqNo := mc.base; seqNo < mc.base+uint64(len(mc.ring)); seqNo++ {
+		ring[seqNo&mask] = mc.ring[seqNo&mc.mask]
 	}
-	if mc.msgPages[page][off] == nil {
+	mc.ring, mc.mask = ring, mask
+	atomic.StoreInt64(&mc.size, int64(n))
+}
+
+// Upset  update or insert
+//	return true for update, false for insert or not cached
+func (mc *msgCache) Upset(seqNo uint64, msg *Message) bool {
+	if seqNo < mc.base {
+		return false
+	}
+	off := seqNo - mc.base
+	if off >= maxCacheWindow && !mc.bOpen {
+		atomic.AddInt64(&mc.nOverflow, 1)
+		return false
+	}
+	if off >= uint64(len(mc.ring)) {
+		mc.grow(off)
+	}
+	p := &mc.ring[seqNo&mc.mask]
+	if *p != nil {
+		*p = msg
 		return true
 	}
+	*p = msg
+	if cnt := atomic.AddInt64(&mc.cnt, 1); cnt > atomic.LoadInt64(&mc.maxCnt) {
+		atomic.StoreInt64(&mc.maxCnt, cnt)
+	}
 	return false
 }
 
-func (mc *msgCache) Merge(seqNo uint64) []Message {
-	page := int(seqNo >> pageShift)
-	off := int(seqNo & (maxPageMsg - 1))
-	if page >= mc.nPage {
-		return nil
+func (mc *msgCache) IsNil(seqNo uint64) bool {
+	if seqNo < mc.base || seqNo-mc.base >= uint64(len(mc.ring)) {
+		return true
 	}
-	if mc.msgPages[page][off] == nil {
+	return mc.ring[seqNo&mc.mask] == nil
+}
+
+// Merge	pop messages cached in sequence from seqNo, entries below
+//			seqNo evicted, nil if seqNo not cached
+func (mc *msgCache) Merge(seqNo uint64) []Message {
+	mc.Advance(seqNo)
+	if seqNo != mc.base || atomic.LoadInt64(&mc.cnt) == 0 ||
+		mc.ring[seqNo&mc.mask] == nil {
 		return nil
 	}
-	getCount := func(page, off int) (res int) {
-		for mc.msgPages[page][off] != nil {
-			res++
-			off++
-			if off >= maxPageMsg {
-				off = 0
-				page++
-				if page >= mc.nPage {
-					break
-				}
-			}
-		}
-		return
-	}
-	cnt := getCount(page, off)
-	if cnt == 0 {
-		return []Message{}
+	cnt := 1
+	for cnt < len(mc.ring) && mc.ring[(seqNo+uint64(cnt))&mc.mask] != nil {
+		cnt++
 	}
 	ret := make([]Message, cnt)
-	i := 0
-	for mc.msgPages[page][off] != nil {
-		ret[i] = *mc.msgPages[page][off]
-		i++
-		if i >= cnt {
-			break
-		}
-		off++
-		if off >= maxPageMsg {
-			off = 0
-			page++
-		}
+	for i := range ret {
+		p := &mc.ring[(seqNo+uint64(i))&mc.mask]
+		ret[i] = **p
+		*p = nil
 	}
+	mc.base += uint64(cnt)
+	atomic.AddInt64(&mc.cnt, -int64(cnt))
 	return ret
 }
+
+// Stats	occupancy metrics, safe from other goroutine
+func (mc *msgCache) Stats() CacheStats {
+	return CacheStats{Cached: int(atomic.LoadInt64(&mc.cnt)),
+		Capacity:  int(atomic.LoadInt64(&mc.size)),
+		MaxCached: int(atomic.LoadInt64(&mc.maxCnt)),
+		Overflow:  int(atomic.LoadInt64(&mc.nOverflow))}
+}
@@ -0,0 +1,228 @@
+package MoldUDP
+
+import (
+	"testing"
+)
+
+// paged cache replaced by ring buffer msgCache, kept for benchmark
+const (
+	pageCacheMsg   = 0x100000
+	pageCacheShift = 20
+	pageCacheIncr  = 16
+)
+
+func TestMsgCache(t *testing.T) {
+	var mc msgCache
+	mc.Init()
+	msgs := make([]Message, 8)
+	for i := range msgs {
+		msgs[i].Data = []byte{byte(i)}
+	}
+	// across ring boundary
+	base := uint64(minCacheWindow - 3)
+	mc.Advance(base)
+	for i := range msgs {
+		if i == 5 {
+			continue
+		}
+		if mc.Upset(base+uint64(i), &msgs[i]) {
+			t.Errorf("Upset(%d) first time returns update", base+uint64(i))
+		}
+	}
+	if !mc.Upset(base, &msgs[0]) {
+		t.Error("Upset() again not update")
+	}
+	if !mc.IsNil(base+5) || mc.IsNil(base+4) || !mc.IsNil(base+maxCacheWindow) {
+		t.Error("IsNil() dismatch")
+	}
+	if st := mc.Stats(); st.Cached != 7 || st.Capacity != minCacheWindow {
+		t.Errorf("Stats() = %+v before merge", st)
+	}
+	res := mc.Merge(base)
+	if len(res) != 5 {
+		t.Fatalf("Merge() got %d messages, want 5", len(res))
+	}
+	for i := range res {
+		if res[i].Data[0] != byte(i) {
+			t.Errorf("Merge() message %d = %d", i, res[i].Data[0])
+		}
+	}
+	if !mc.IsNil(base) || mc.Merge(base) != nil {
+		t.Error("merged message not evicted")
+	}
+	if res := mc.Merge(base + 5); res != nil {
+		t.Errorf("Merge() at gap = %v, want nil", res)
+	}
+	if res := mc.Merge(base + 6); len(res) != 2 {
+		t.Errorf("Merge() got %d messages, want 2", len(res))
+	}
+	if st := mc.Stats(); st.Cached != 0 || st.MaxCached != 7 {
+		t.Errorf("Stats() = %+v after merge", st)
+	}
+}
+
+func TestMsgCacheWindow(t *testing.T) {
+	var mc msgCache
+	mc.Init()
+	msg := Message{Data: []byte{1}}
+	tests := []struct {
+		name     string
+		seqNo    uint64
+		cached   int
+		capacity int
+		overflow int
+	}{
+		{"in ring", 100, 1, minCacheWindow, 0},
+		{"grow ring", 3 * minCacheWindow, 2, 4 * minCacheWindow, 0},
+		{"beyond window", maxCacheWindow + 1, 2, 4 * minCacheWindow, 1},
+		{"delivered", 0, 2, 4 * minCacheWindow, 1},
+	}
+	mc.Advance(1)
+	for _, tt := range tests {
+		mc.Upset(tt.seqNo, &msg)
+		st := mc.Stats()
+		if st.Cached != tt.cached || st.Capacity != tt.capacity ||
+			st.Overflow != tt.overflow {
+			t.Errorf("%s: Stats() = %+v", tt.name, st)
+		}
+	}
+	if mc.IsNil(100) || mc.IsNil(3*minCacheWindow) {
+		t.Error("entry lost after grow")
+	}
+	// evict entries delivered by other path
+	mc.Advance(200)
+	if st := mc.Stats(); st.Cached != 1 || !mc.IsNil(100) {
+		t.Errorf("Advance() Stats() = %+v", st)
+	}
+	mc.Advance(maxCacheWindow * 2)
+	if st := mc.Stats(); st.Cached != 0 || !mc.IsNil(3*minCacheWindow) {
+		t.Errorf("Advance() beyond ring Stats() = %+v", st)
+	}
+}
+
+type cacheImpl interface {
+	Upset(seqNo uint64, msg *Message) bool
+	Merge(seqNo uint64) []Message
+}
+
+// benchCache	one message per op, in blocks of 64 with first one late
+func benchCache(b *testing.B, mc cacheImpl) {
+	const blk = 64
+	msgs := make([]Message, blk)
+	seqNo := uint64(1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += blk {
+		for j := 1; j < blk; j++ {
+			mc.Upset(seqNo+uint64(j), &msgs[j])
+		}
+		mc.Upset(seqNo, &msgs[0])
+		if res := mc.Merge(seqNo); len(res) != blk {
+			b.Fatalf("Merge(%d) got %d messages", seqNo, len(res))
+		}
+		seqNo += blk
+	}
+}
+
+func BenchmarkMsgCache(b *testing.B) {
+	b.Run("ring", func(b *testing.B) {
+		var mc msgCache
+		mc.Init()
+		benchCache(b, &mc)
+	})
+	b.Run("page", func(b *testing.B) {
+		var mc pageCache
+		mc.Init()
+		// pages grow with sequence number, never freed
+		benchCache(b, &mc)
+	})
+}
+
+type pageMsgs [pageCacheMsg]*Message
+
+type pageCache struct {
+	nPage     int
+	maxPageNo int
+	pages     []pageMsgs
+}
+
+func (mc *pageCache) Init() {
+	mc.nPage = pageCacheIncr
+	mc.pages = make([]pageMsgs, pageCacheIncr)
+}
+
+// Upset  update or insert
+//	return true for update
+func (mc *pageCache) Upset(seqNo uint64, msg *Message) bool {
+	page := int(seqNo >> pageCacheShift)
+	off := int(seqNo & (pageCacheMsg - 1))
+	if page >= mc.nPage {
+		for page >= mc.nPage {
+			msgPP := make([]pageMsgs, pageCacheIncr)
+			mc.pages = append(mc.pages, msgPP...)
+			mc.nPage += pageCacheIncr
+		}
+	}
+	if page > mc.maxPageNo {
+		mc.maxPageNo = page
+	}
+	ret := mc.pages[page][off] != nil
+	mc.pages[page][off] = msg
+	return ret
+}
+
+func (mc *pageCache) IsNil(seqNo uint64) bool {
+	page := int(seqNo >> pageCacheShift)
+	off := int(seqNo & (pageCacheMsg - 1))
+	if page >= mc.nPage {
+		return true
+	}
+	if mc.pages[page][off] == nil {
+		return true
+	}
+	return false
+}
+
+func (mc *pageCache) Merge(seqNo uint64) []Message {
+	page := int(seqNo >> pageCacheShift)
+	off := int(seqNo & (pageCacheMsg - 1))
+	if page >= mc.nPage {
+		return nil
+	}
+	if mc.pages[page][off] == nil {
+		return nil
+	}
+	getCount := func(page, off int) (res int) {
+		for mc.pages[page][off] != nil {
+			res++
+			off++
+			if off >= pageCacheMsg {
+				off = 0
+				page++
+				if page >= mc.nPage {
+					break
+				}
+			}
+		}
+		return
+	}
+	cnt := getCount(page, off)
+	if cnt == 0 {
+		return []Message{}
+	}
+	ret := make([]Message, cnt)
+	i := 0
+	for mc.pages[page][off] != nil {
+		ret[i] = *mc.pages[page][off]
+		i++
+		if i >= cnt {
+			break
+		}
+		off++
+		if off >= pageCacheMsg {
+			off = 0
+			page++
+		}
+	}
+	return ret
+}
@@ -0,0 +1,5 @@
+// +build !race
+
+package MoldUDP
+
+const raceEnabled = false
@@ -0,0 +1,441 @@
+package MoldUDP
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+
+	"github.com/kjx98/golib/nettypes"
+)
+
+// pcap/pcapng capture file
+//	classic	header(24) record: tsSec(4) tsFrac(4) capLen(4) origLen(4) data
+//	pcapng	blocks: type(4) length(4) body length(4), SHB for byte order,
+//			IDB for link type, EPB/SPB for packets
+const (
+	pcapMagic     = 0xa1b2c3d4
+	pcapMagicNano = 0xa1b23c4d
+	pcapHeadSize  = 24
+	pcapRecHead   = 16
+	pcapngSHB     = 0x0a0d0d0a
+	pcapngIDB     = 1
+	pcapngSPB     = 3
+	pcapngEPB     = 6
+	pcapngBOM     = 0x1a2b3c4d
+	maxPcapRecord = 262144
+	// link types
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkSLL      = 113
+	linkSLL2     = 276
+	afInet       = 2
+)
+
+var (
+	errPcapFormat = errors.New("Not a pcap/pcapng file")
+	errPcapRecord = errors.New("pcap record corrupt")
+	errPcapFile   = errors.New("pcap file not given, use pcap:fileName")
+)
+
+// pcapReader	read link layer frames from classic pcap or pcapng
+type pcapReader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	ng    bool
+	links []uint16
+	buff  []byte
+}
+
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	pr := pcapReader{r: bufio.NewReaderSize(r, 65536)}
+	magic, err := pr.r.Peek(4)
+	if err != nil {
+		return nil, errPcapFormat
+	}
+	switch {
+	case binary.LittleEndian.Uint32(magic) == pcapngSHB:
+		pr.ng = true
+		return &pr, nil
+	case binary.LittleEndian.Uint32(magic) == pcapMagic,
+		binary.LittleEndian.Uint32(magic) == pcapMagicNano:
+		pr.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(magic) == pcapMagic,
+		binary.BigEndian.Uint32(magic) == pcapMagicNano:
+		pr.order = binary.BigEndian
+	default:
+		return nil, errPcapFormat
+	}
+	var head [pcapHeadSize]byte
+	if _, err := io.ReadFull(pr.r, head[:]); err != nil {
+		return nil, errPcapFormat
+	}
+	// upper bits of link type may carry FCS info
+	pr.links = []uint16{uint16(pr.order.Uint32(head[20:]))}
+	return &pr, nil
+}
+
+// next		next captured frame and its link type, io.EOF for end of file
+func (pr *pcapReader) next() ([]byte, uint16, error) {
+	if pr.ng {
+		return pr.nextBlock()
+	}
+	var head [pcapRecHead]byte
+	if _, err := io.ReadFull(pr.r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, 0, err
+	}
+	capLen := pr.order.Uint32(head[8:])
+	if capLen > maxPcapRecord {
+		return nil, 0, errPcapRecord
+	}
+	data, err := pr.read(int(capLen))
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, pr.links[0], nil
+}
+
+func (pr *pcapReader) read(n int) ([]byte, error) {
+	if cap(pr.buff) < n {
+		pr.buff = make([]byte, n)
+	}
+	pr.buff = pr.buff[:n]
+	if _, err := io.ReadFull(pr.r, pr.buff); err != nil {
+		return nil, io.EOF
+	}
+	return pr.buff, nil
+}
+
+func (pr *pcapReader) nextBlock() ([]byte, uint16, error) {
+	for {
+		var head [8]byte
+		if _, err := io.ReadFull(pr.r, head[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return nil, 0, err
+		}
+		if binary.LittleEndian.Uint32(head[:4]) == pcapngSHB {
+			// new section, byte order from byte-order magic
+			bom, err := pr.r.Peek(4)
+			if err != nil {
+				return nil, 0, io.EOF
+			}
+			switch {
+			case binary.LittleEndian.Uint32(bom) == pcapngBOM:
+				pr.order = binary.LittleEndian
+			case binary.BigEndian.Uint32(bom) == pcapngBOM:
+				pr.order = binary.BigEndian
+			default:
+				return nil, 0, errPcapFormat
+			}
+			pr.links = pr.links[:0]
+		}
+		bLen := pr.order.Uint32(head[4:])
+		if bLen < 12 || bLen > maxPcapRecord || bLen%4 != 0 {
+			return nil, 0, errPcapRecord
+		}
+		body, err := pr.read(int(bLen) - 8)
+		if err != nil {
+			return nil, 0, err
+		}
+		// strip trailing block length
+		body = body[:len(body)-4]
+		switch pr.order.Uint32(head[:4]) {
+		case pcapngIDB:
+			if len(body) < 8 {
+				return nil, 0, errPcapRecord
+			}
+			pr.links = append(pr.links, pr.order.Uint16(body))
+		case pcapngEPB:
+			if len(body) < 20 {
+				return nil, 0, errPcapRecord
+			}
+			ifID := pr.order.Uint32(body)
+			capLen := pr.order.Uint32(body[12:])
+			if int(ifID) >= len(pr.links) || int(capLen) > len(body)-20 {
+				return nil, 0, errPcapRecord
+			}
+			return body[20 : 20+capLen], pr.links[ifID], nil
+		case pcapngSPB:
+			if len(body) < 4 || len(pr.links) == 0 {
+				return nil, 0, errPcapRecord
+			}
+			capLen := int(pr.order.Uint32(body))
+			if capLen > len(body)-4 {
+				capLen = len(body) - 4
+			}
+			return body[4 : 4+capLen], pr.links[0], nil
+		}
+	}
+}
+
+// linkPayload	network packet of frame and its ethernet type
+func linkPayload(frame []byte, link uint16) ([]byte, int) {
+	switch link {
+	case linkEthernet:
+		if len(frame) < 14 {
+			return nil, 0
+		}
+		f := nettypes.Frame(frame)
+		tag := f.VLANTag()
+		if len(frame) < 14+int(tag) {
+			return nil, 0
+		}
+		pay, _ := f.MACPayload(tag)
+		return pay, int(f.MACEthertype(tag))
+	case linkSLL:
+		if len(frame) < 16 {
+			return nil, 0
+		}
+		return frame[16:], int(coder.Uint16(frame[14:]))
+	case linkSLL2:
+		if len(frame) < 20 {
+			return nil, 0
+		}
+		return frame[20:], int(coder.Uint16(frame))
+	case linkNull:
+		// address family in capturing host byte order, AF_INET6 differs
+		// among systems, IPv6 checked by version
+		if len(frame) < 5 {
+			return nil, 0
+		}
+		if frame[0] == afInet || frame[3] == afInet {
+			return frame[4:], int(nettypes.IPv4)
+		}
+		if frame[4]>>4 == 6 {
+			return frame[4:], ethIPv6
+		}
+	case linkRaw:
+		if len(frame) == 0 {
+			return nil, 0
+		}
+		if frame[0]>>4 == 6 {
+			return frame, ethIPv6
+		}
+		return frame, int(nettypes.IPv4)
+	}
+	return nil, 0
+}
+
+// ipPayload	IPv4 packet of frame, nil for other protocols
+func ipPayload(frame []byte, link uint16) nettypes.IPv4_P {
+	if pay, ether := linkPayload(frame, link); ether == int(nettypes.IPv4) {
+		return nettypes.IPv4_P(pay)
+	}
+	return nil
+}
+
+type pcapIf struct {
+	fileName string
+	file     *os.File
+	pr       *pcapReader
+	dstIP    net.IP
+	srcIP    net.IP
+	port     int
+	rAddr    net.UDPAddr
+	err      error
+	done     chan struct{}
+	bClosed  int32
+	nFrames  int
+	nPackets int
+}
+
+func newPcapIf() McastConn {
+	return &pcapIf{}
+}
+
+// NewPcapIf	McastConn recv multicast packets captured in pcap/pcapng
+//	file, same as NewIf("pcap:" + fileName)
+func NewPcapIf(fileName string) McastConn {
+	return &pcapIf{fileName: fileName}
+}
+
+func init() {
+	registerIf("pcap", newPcapIf)
+}
+
+func (c *pcapIf) setArg(arg string) {
+	c.fileName = arg
+}
+
+func (c *pcapIf) Enabled(opts int) bool {
+	if (opts & HasRingBuffer) != 0 {
+		return true
+	}
+	return false
+}
+
+func (c *pcapIf) String() string {
+	return "pcap Intf(" + c.fileName + ")"
+}
+
+func (c *pcapIf) Close() error {
+	if c.file == nil || !atomic.CompareAndSwapInt32(&c.bClosed, 0, 1) {
+		return errClosed
+	}
+	close(c.done)
+	return c.file.Close()
+}
+
+// Open		open capture file, packets not from src filtered if src not
+//			nil, ifn ignored
+func (c *pcapIf) Open(ip net.IP, port int, src net.IP, ifn *net.Interface) (err error) {
+	if c.file != nil {
+		return errOpened
+	}
+	if c.fileName == "" {
+		return errPcapFile
+	}
+	if c.srcIP, err = sourceAddr(ip, src); err != nil {
+		return
+	}
+	if c.file, err = os.Open(c.fileName); err != nil {
+		c.file = nil
+		return
+	}
+	if c.pr, err = newPcapReader(c.file); err != nil {
+		c.file.Close()
+		c.file = nil
+		return
+	}
+	if dst := ip.To4(); dst != nil {
+		c.dstIP = dst
+	} else if isIPv6(ip) {
+		c.dstIP = ip
+	}
+	c.port = port
+	c.rAddr.IP = net.IPv4zero
+	c.done = make(chan struct{})
+	log.Info("Using pcap file", c.fileName)
+	return nil
+}
+
+func (c *pcapIf) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface) error {
+	return errNotSupport
+}
+
+func (c *pcapIf) Send(buff []byte) (int, error) {
+	return 0, errModeRW
+}
+
+func (c *pcapIf) MSend(buffs []Packet) (int, error) {
+	return 0, errModeRW
+}
+
+func (c *pcapIf) MRecv() ([]Packet, *net.UDPAddr, error) {
+	return nil, nil, errNotSupport
+}
+
+// nextPayload	UDP payload of next packet sent to group/port
+func (c *pcapIf) nextPayload() ([]byte, error) {
+	for atomic.LoadInt32(&c.bClosed) == 0 {
+		frame, link, err := c.pr.next()
+		if err != nil {
+			return nil, err
+		}
+		c.nFrames++
+		pay, ether := linkPayload(frame, link)
+		if ether == ethIPv6 {
+			if udp := c.ip6Payload(pay); udp != nil {
+				c.nPackets++
+				return udp, nil
+			}
+			continue
+		}
+		if ether != int(nettypes.IPv4) {
+			continue
+		}
+		ip := nettypes.IPv4_P(pay)
+		if len(ip) < 20 || ip.Version() != 4 || ip.Protocol() != nettypes.UDP {
+			continue
+		}
+		ihl := int(ip.IHL()) * 4
+		ipLen := int(ip.Length())
+		// fragmented packet not supported
+		if ihl < 20 || ipLen < ihl+8 || ipLen > len(ip) || ip.FragmentOffset() != 0 ||
+			(ip.Flags()&1) != 0 {
+			continue
+		}
+		if c.dstIP != nil && !c.dstIP.IsUnspecified() &&
+			!ip.DestinationIP().Equal(c.dstIP) {
+			continue
+		}
+		udp := nettypes.UDP_P(ip[ihl:ipLen])
+		if int(udp.DestinationPort()) != c.port {
+			continue
+		}
+		uLen := int(udp.Length())
+		if uLen < 8 || uLen > len(udp) {
+			continue
+		}
+		ips := ip.SourceIP()
+		if c.srcIP != nil && !ips.Equal(c.srcIP) {
+			continue
+		}
+		c.rAddr.IP = net.IPv4(ips[0], ips[1], ips[2], ips[3])
+		c.rAddr.Port = int(udp.SourcePort())
+		c.nPackets++
+		return udp[8:uLen], nil
+	}
+	return nil, errClosed
+}
+
+// ip6Payload	UDP payload of IPv6 packet ip if sent to group/port
+func (c *pcapIf) ip6Payload(ip []byte) []byte {
+	udp := ip6UDP(ip)
+	if udp == nil || int(coder.Uint16(udp[2:])) != c.port {
+		return nil
+	}
+	if c.dstIP != nil && !c.dstIP.IsUnspecified() &&
+		!net.IP(ip[24:40]).Equal(c.dstIP) {
+		return nil
+	}
+	if c.srcIP != nil && !net.IP(ip[8:24]).Equal(c.srcIP) {
+		return nil
+	}
+	c.rAddr.IP = append(net.IP{}, ip[8:24]...)
+	c.rAddr.Port = int(coder.Uint16(udp))
+	return udp[8:]
+}
+
+// Recv		next packet, blocks till Close after end of file
+func (c *pcapIf) Recv(buff []byte) (int, *net.UDPAddr, error) {
+	if c.err == nil {
+		pay, err := c.nextPayload()
+		if err == nil {
+			return copy(buff, pay), &c.rAddr, nil
+		}
+		c.err = err
+		if err != io.EOF {
+			return 0, nil, err
+		}
+		log.Infof("pcap end of file, %d frames %d packets", c.nFrames, c.nPackets)
+	}
+	<-c.done
+	return 0, nil, errClosed
+}
+
+// Listen	deliver all packets of file, returns at end of file or Close
+func (c *pcapIf) Listen(fx func([]byte, *net.UDPAddr)) {
+	for {
+		pay, err := c.nextPayload()
+		if err != nil {
+			if err == io.EOF {
+				log.Infof("pcap end of file, %d frames %d packets", c.nFrames,
+					c.nPackets)
+			} else if err != errClosed {
+				log.Error("pcap read", err)
+			}
+			return
+		}
+		fx(pay, &c.rAddr)
+	}
+}
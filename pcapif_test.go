@@ -0,0 +1,194 @@
+package MoldUDP
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	pcapSrcIP = net.IPv4(192, 168, 0, 1).To4()
+	pcapDstIP = net.IPv4(239, 192, 168, 1).To4()
+)
+
+// udpFrame	ethernet frame of UDP packet from pcapSrcIP to dst:port
+func udpFrame(dst net.IP, port int, pay []byte) []byte {
+	dst = dst.To4()
+	buff := make([]byte, 14+28+len(pay))
+	copy(buff, GetMulticastHWAddr(dst))
+	buildRawUDP(buff, len(pay), port, pcapSrcIP, dst)
+	copy(buff[14+28:], pay)
+	return buff
+}
+
+// vlanFrame	insert 802.1Q tag into ethernet frame
+func vlanFrame(frame []byte) []byte {
+	buff := append([]byte{}, frame[:12]...)
+	buff = append(buff, 0x81, 0, 0, 10)
+	return append(buff, frame[12:]...)
+}
+
+func writePcap(t *testing.T, fileName string, ng bool, frames [][]byte) {
+	le := binary.LittleEndian
+	var buff []byte
+	if ng {
+		shb := make([]byte, 28)
+		le.PutUint32(shb, pcapngSHB)
+		le.PutUint32(shb[4:], 28)
+		le.PutUint32(shb[8:], pcapngBOM)
+		le.PutUint16(shb[12:], 1)
+		le.PutUint64(shb[16:], ^uint64(0))
+		le.PutUint32(shb[24:], 28)
+		idb := make([]byte, 20)
+		le.PutUint32(idb, pcapngIDB)
+		le.PutUint32(idb[4:], 20)
+		le.PutUint16(idb[8:], linkEthernet)
+		le.PutUint32(idb[16:], 20)
+		buff = append(append(buff, shb...), idb...)
+	} else {
+		head := make([]byte, pcapHeadSize)
+		le.PutUint32(head, pcapMagic)
+		le.PutUint16(head[4:], 2)
+		le.PutUint16(head[6:], 4)
+		le.PutUint32(head[16:], 65535)
+		le.PutUint32(head[20:], linkEthernet)
+		buff = append(buff, head...)
+	}
+	for i, frame := range frames {
+		if ng {
+			pad := (4 - len(frame)%4) % 4
+			bLen := 32 + len(frame) + pad
+			epb := make([]byte, bLen)
+			le.PutUint32(epb, pcapngEPB)
+			le.PutUint32(epb[4:], uint32(bLen))
+			le.PutUint32(epb[16:], uint32(i))
+			le.PutUint32(epb[20:], uint32(len(frame)))
+			le.PutUint32(epb[24:], uint32(len(frame)))
+			copy(epb[28:], frame)
+			le.PutUint32(epb[bLen-4:], uint32(bLen))
+			buff = append(buff, epb...)
+			continue
+		}
+		rec := make([]byte, pcapRecHead)
+		le.PutUint32(rec, uint32(i))
+		le.PutUint32(rec[8:], uint32(len(frame)))
+		le.PutUint32(rec[12:], uint32(len(frame)))
+		buff = append(append(buff, rec...), frame...)
+	}
+	if err := os.WriteFile(fileName, buff, 0644); err != nil {
+		t.Fatal("WriteFile", err)
+	}
+}
+
+func TestPcapIf(t *testing.T) {
+	other := net.IPv4(239, 192, 168, 2)
+	frames := [][]byte{
+		udpFrame(pcapDstIP, 5858, []byte("first")),
+		udpFrame(pcapDstIP, 5859, []byte("wrong port")),
+		udpFrame(other, 5858, []byte("wrong group")),
+		make([]byte, 60),
+		vlanFrame(udpFrame(pcapDstIP, 5858, []byte("second"))),
+		udpFrame(pcapDstIP, 5858, []byte("third")),
+	}
+	want := []string{"first", "second", "third"}
+	dir := t.TempDir()
+	for _, ng := range []bool{false, true} {
+		fileName := filepath.Join(dir, "test.pcap")
+		if ng {
+			fileName += "ng"
+		}
+		writePcap(t, fileName, ng, frames)
+		conn := NewIf("pcap:" + fileName)
+		if err := conn.Open(pcapDstIP, 5858, nil, nil); err != nil {
+			t.Fatal("Open", err)
+		}
+		buff := make([]byte, 2048)
+		for _, ss := range want {
+			n, rAddr, err := conn.Recv(buff)
+			if err != nil {
+				t.Fatal("Recv", err)
+			}
+			if string(buff[:n]) != ss || !rAddr.IP.Equal(pcapSrcIP) ||
+				rAddr.Port != 5859 {
+				t.Errorf("Recv() = %s from %v, want %s", buff[:n], rAddr, ss)
+			}
+		}
+		conn.Close()
+		if _, _, err := conn.Recv(buff); err != errClosed {
+			t.Errorf("Recv() after end of file error = %v, want %v", err, errClosed)
+		}
+
+		conn = NewPcapIf(fileName)
+		if err := conn.Open(pcapDstIP, 5858, nil, nil); err != nil {
+			t.Fatal("Open", err)
+		}
+		var got []string
+		conn.Listen(func(buff []byte, rAddr *net.UDPAddr) {
+			got = append(got, string(buff))
+		})
+		conn.Close()
+		if len(got) != len(want) {
+			t.Fatalf("Listen() got %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Listen() packet %d = %s, want %s", i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestPcapClient(t *testing.T) {
+	msgs := make([]Message, 100)
+	for i := range msgs {
+		msgs[i].Data = []byte{byte(i), byte(i >> 8)}
+	}
+	var frames [][]byte
+	for i := 0; i < len(msgs); i += 10 {
+		pkt := buildPacket("pcap0", uint64(i+1), 0, msgs[i:i+10])
+		frames = append(frames, udpFrame(pcapDstIP, 5858, pkt))
+	}
+	frames = append(frames, udpFrame(pcapDstIP, 5858,
+		buildPacket("pcap0", uint64(len(msgs)+1), 0xffff, nil)))
+	fileName := filepath.Join(t.TempDir(), "mold.pcapng")
+	writePcap(t, fileName, true, frames)
+	cc, err := NewClient(pcapDstIP.String(), 5858, &Option{}, NewIf("pcap:"+fileName),
+		false)
+	if err != nil {
+		t.Fatal("NewClient", err)
+	}
+	defer cc.Close()
+	seqNo := uint64(1)
+	for {
+		res, _, err := cc.Read()
+		if err != nil {
+			if err != ErrEndOfSession {
+				t.Error("Read()", err)
+			}
+			break
+		}
+		for _, msg := range res {
+			if string(msg.Data) != string(msgs[seqNo-1].Data) {
+				t.Errorf("message %d = %v, want %v", seqNo, msg.Data,
+					msgs[seqNo-1].Data)
+			}
+			seqNo++
+		}
+	}
+	if seqNo != uint64(len(msgs))+1 {
+		t.Errorf("Read %d messages, want %d", seqNo-1, len(msgs))
+	}
+}
+
+func TestPcapFormat(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "bad.pcap")
+	os.WriteFile(fileName, []byte("not a capture file"), 0644)
+	if err := NewPcapIf(fileName).Open(pcapDstIP, 5858, nil, nil); err != errPcapFormat {
+		t.Errorf("Open() error = %v, want %v", err, errPcapFormat)
+	}
+	if err := NewIf("pcap").Open(pcapDstIP, 5858, nil, nil); err != errPcapFile {
+		t.Errorf("Open() error = %v, want %v", err, errPcapFile)
+	}
+}
@@ -0,0 +1,258 @@
+package MoldUDP
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// PcapWriter	write UDP payloads to classic pcap file(nanosecond) with
+//				synthetic Ethernet/IPv4/UDP headers, opens in wireshark
+type PcapWriter struct {
+	lock     sync.Mutex
+	file     *os.File
+	w        *bufio.Writer
+	srcMAC   [6]byte
+	buff     []byte
+	nPackets int
+}
+
+// NewPcapWriter	create(truncate) pcap file
+func NewPcapWriter(fileName string) (*PcapWriter, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	pw := PcapWriter{file: file, w: bufio.NewWriterSize(file, 65536)}
+	// locally administered unicast MAC
+	pw.srcMAC = [6]byte{2, 0, 0, 0, 0, 1}
+	pw.buff = make([]byte, pcapRecHead+14+ip6HeadSize+8+maxDatagramSize)
+	var head [pcapHeadSize]byte
+	le := binary.LittleEndian
+	le.PutUint32(head[:], pcapMagicNano)
+	le.PutUint16(head[4:], 2)
+	le.PutUint16(head[6:], 4)
+	le.PutUint32(head[16:], 65535)
+	le.PutUint32(head[20:], linkEthernet)
+	if _, err := pw.w.Write(head[:]); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &pw, nil
+}
+
+// udpChecksum	UDP checksum with IPv4/IPv6 pseudo header, checksum of udp
+//				must be 0
+func udpChecksum(udp []byte, src, dst []byte) uint16 {
+	var cs uint32
+	for i := 0; i+1 < len(src) && i+1 < len(dst); i += 2 {
+		cs += uint32(coder.Uint16(src[i:])) + uint32(coder.Uint16(dst[i:]))
+	}
+	cs += 17 + uint32(len(udp))
+	i := 0
+	for ; i+1 < len(udp); i += 2 {
+		cs += uint32(coder.Uint16(udp[i:]))
+	}
+	if i < len(udp) {
+		cs += uint32(udp[i]) << 8
+	}
+	for cs>>16 != 0 {
+		cs = (cs & 0xffff) + (cs >> 16)
+	}
+	if ck := ^uint16(cs); ck != 0 {
+		return ck
+	}
+	// 0 for no checksum
+	return 0xffff
+}
+
+// WritePacket	append UDP packet with payload pay from src to dst
+func (pw *PcapWriter) WritePacket(pay []byte, src, dst *net.UDPAddr, ts time.Time) error {
+	if isIPv6(dst.IP) {
+		return pw.writePacket6(pay, src, dst, ts)
+	}
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	if srcIP == nil {
+		srcIP = net.IPv4zero.To4()
+	}
+	if dstIP == nil {
+		return errNotSupport
+	}
+	pLen := len(pay)
+	if pLen > maxDatagramSize {
+		pLen = maxDatagramSize
+	}
+	pw.lock.Lock()
+	defer pw.lock.Unlock()
+	rec := pw.buff[:pcapRecHead]
+	frame := pw.buff[pcapRecHead : pcapRecHead+14+28+pLen]
+	pw.putHead(rec, frame, dstIP, ts)
+	buildRawUDP(frame, pLen, dst.Port, srcIP, dstIP)
+	udp := frame[14+20:]
+	coder.PutUint16(udp, uint16(src.Port))
+	copy(udp[8:], pay[:pLen])
+	coder.PutUint16(udp[6:], udpChecksum(udp, srcIP, dstIP))
+	if _, err := pw.w.Write(pw.buff[:pcapRecHead+len(frame)]); err != nil {
+		return err
+	}
+	pw.nPackets++
+	return nil
+}
+
+func (pw *PcapWriter) writePacket6(pay []byte, src, dst *net.UDPAddr, ts time.Time) error {
+	srcIP := net.IPv6unspecified
+	if isIPv6(src.IP) {
+		srcIP = src.IP
+	}
+	pLen := len(pay)
+	if pLen > maxDatagramSize {
+		pLen = maxDatagramSize
+	}
+	pw.lock.Lock()
+	defer pw.lock.Unlock()
+	rec := pw.buff[:pcapRecHead]
+	frame := pw.buff[pcapRecHead : pcapRecHead+14+ip6HeadSize+8+pLen]
+	pw.putHead(rec, frame, dst.IP, ts)
+	buildRawUDP6(frame, pay[:pLen], dst.Port, srcIP, dst.IP)
+	udp := frame[14+ip6HeadSize:]
+	coder.PutUint16(udp, uint16(src.Port))
+	coder.PutUint16(udp[6:], 0)
+	coder.PutUint16(udp[6:], udpChecksum(udp, srcIP, dst.IP))
+	if _, err := pw.w.Write(pw.buff[:pcapRecHead+len(frame)]); err != nil {
+		return err
+	}
+	pw.nPackets++
+	return nil
+}
+
+// putHead	record header and MAC addresses of frame
+func (pw *PcapWriter) putHead(rec, frame []byte, dstIP net.IP, ts time.Time) {
+	le := binary.LittleEndian
+	le.PutUint32(rec, uint32(ts.Unix()))
+	le.PutUint32(rec[4:], uint32(ts.Nanosecond()))
+	le.PutUint32(rec[8:], uint32(len(frame)))
+	le.PutUint32(rec[12:], uint32(len(frame)))
+	if dstIP.IsMulticast() {
+		copy(frame, GetMulticastHWAddr(dstIP))
+	} else {
+		copy(frame, []byte{2, 0, 0, 0, 0, 2})
+	}
+	copy(frame[6:], pw.srcMAC[:])
+}
+
+// Flush	flush buffered packets to file
+func (pw *PcapWriter) Flush() error {
+	pw.lock.Lock()
+	defer pw.lock.Unlock()
+	return pw.w.Flush()
+}
+
+func (pw *PcapWriter) Close() error {
+	err := pw.Flush()
+	pw.file.Close()
+	return err
+}
+
+// pcapTee	McastConn write packets sent/received via conn to pcap file
+type pcapTee struct {
+	conn  McastConn
+	pw    *PcapWriter
+	local net.UDPAddr
+	group net.UDPAddr
+}
+
+// NewPcapTee	wrap conn, tee packets sent and received to pw
+//	retransmissions of Client not via conn are not recorded
+func NewPcapTee(conn McastConn, pw *PcapWriter) McastConn {
+	return &pcapTee{conn: conn, pw: pw}
+}
+
+func (c *pcapTee) Enabled(opts int) bool {
+	return c.conn.Enabled(opts)
+}
+
+func (c *pcapTee) String() string {
+	return fmt.Sprintf("%v with pcap tee", c.conn)
+}
+
+func (c *pcapTee) connStats() ConnStats {
+	return GetConnStats(c.conn)
+}
+
+func (c *pcapTee) Close() error {
+	err := c.conn.Close()
+	c.pw.Flush()
+	return err
+}
+
+func (c *pcapTee) Open(ip net.IP, port int, src net.IP, ifn *net.Interface) error {
+	c.group = net.UDPAddr{IP: ip, Port: port}
+	return c.conn.Open(ip, port, src, ifn)
+}
+
+func (c *pcapTee) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface) error {
+	c.group = net.UDPAddr{IP: ip, Port: port}
+	// same source as buildRawUDP
+	c.local = net.UDPAddr{IP: net.IPv4zero, Port: port + 1}
+	if isIPv6(ip) {
+		c.local.IP = net.IPv6unspecified
+		if adr, err := getIfAddr6(ifn); err == nil {
+			c.local.IP = adr
+		}
+	} else if adr, err := getIfAddr(ifn); err == nil {
+		c.local.IP = adr
+	}
+	return c.conn.OpenSend(ip, port, bLoop, ifn)
+}
+
+func (c *pcapTee) tee(buff []byte, src *net.UDPAddr) {
+	if src == nil {
+		src = &c.local
+	}
+	if err := c.pw.WritePacket(buff, src, &c.group, time.Now()); err != nil {
+		log.Error("pcap write", err)
+	}
+}
+
+func (c *pcapTee) Send(buff []byte) (int, error) {
+	n, err := c.conn.Send(buff)
+	if err == nil {
+		c.tee(buff[:n], nil)
+	}
+	return n, err
+}
+
+func (c *pcapTee) Recv(buff []byte) (int, *net.UDPAddr, error) {
+	n, rAddr, err := c.conn.Recv(buff)
+	if err == nil {
+		c.tee(buff[:n], rAddr)
+	}
+	return n, rAddr, err
+}
+
+func (c *pcapTee) MSend(buffs []Packet) (int, error) {
+	n, err := c.conn.MSend(buffs)
+	for i := 0; i < n; i++ {
+		c.tee(buffs[i], nil)
+	}
+	return n, err
+}
+
+func (c *pcapTee) MRecv() ([]Packet, *net.UDPAddr, error) {
+	bufs, rAddr, err := c.conn.MRecv()
+	for _, buf := range bufs {
+		c.tee(buf, rAddr)
+	}
+	return bufs, rAddr, err
+}
+
+func (c *pcapTee) Listen(fx func([]byte, *net.UDPAddr)) {
+	c.conn.Listen(func(buff []byte, rAddr *net.UDPAddr) {
+		c.tee(buff, rAddr)
+		fx(buff, rAddr)
+	})
+}
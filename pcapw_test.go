@@ -0,0 +1,97 @@
+package MoldUDP
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kjx98/golib/nettypes"
+)
+
+func TestPcapWriter(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "tee.pcap")
+	pw, err := NewPcapWriter(fileName)
+	if err != nil {
+		t.Fatal("NewPcapWriter", err)
+	}
+	sConn := &fakeConn{bMmsg: true}
+	srv, err := NewServer(pcapDstIP.String(), 5858, "tee0", &Option{},
+		NewPcapTee(sConn, pw), false)
+	if err != nil {
+		t.Fatal("NewServer", err)
+	}
+	msgs := make([]Message, 100)
+	for i := range msgs {
+		// odd length for checksum padding
+		msgs[i].Data = []byte{byte(i), 1, 2}
+	}
+	srv.Send(msgs)
+	rConn := newChanConn()
+	tee := NewPcapTee(rConn, pw)
+	if err := tee.Open(pcapDstIP, 5858, nil, nil); err != nil {
+		t.Fatal("Open", err)
+	}
+	rAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	buff := make([]byte, 2048)
+	for _, pkt := range sConn.pkts {
+		rConn.rx <- pkt
+		n, _, err := tee.Recv(buff)
+		if err != nil || n != len(pkt) {
+			t.Fatalf("Recv() = %d, %v, want %d", n, err, len(pkt))
+		}
+	}
+	pw.Close()
+
+	nPkts := len(sConn.pkts)
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal("Open", err)
+	}
+	defer file.Close()
+	pr, err := newPcapReader(file)
+	if err != nil {
+		t.Fatal("newPcapReader", err)
+	}
+	for i := 0; i < 2*nPkts; i++ {
+		frame, link, err := pr.next()
+		if err != nil {
+			t.Fatal("pcap next", err)
+		}
+		ip := ipPayload(frame, link)
+		if ip == nil || ip.PacketCorrupt() {
+			t.Fatalf("packet %d IP header corrupt", i)
+		}
+		udp := nettypes.UDP_P(append([]byte{}, ip[20:]...))
+		ck := udp.Checksum()
+		udp[6], udp[7] = 0, 0
+		if want := udpChecksum(udp, ip.SourceIP(), ip.DestinationIP()); ck != want {
+			t.Errorf("packet %d UDP checksum %x, want %x", i, ck, want)
+		}
+		pkt := sConn.pkts[i%nPkts]
+		if string(udp[8:]) != string(pkt) {
+			t.Errorf("packet %d payload dismatch", i)
+		}
+		if i >= nPkts && !ip.SourceIP().Equal(rAddr.IP) {
+			t.Errorf("packet %d from %v, want %v", i, ip.SourceIP(), rAddr.IP)
+		}
+	}
+	if _, _, err := pr.next(); err == nil {
+		t.Error("more packets than sent and received")
+	}
+}
+
+func TestUDPChecksum(t *testing.T) {
+	udp := []byte{0x16, 0xe3, 0x16, 0xe2, 0x00, 0x0d, 0, 0, 'h', 'e', 'l', 'l', 'o'}
+	src := []byte{192, 168, 0, 1}
+	dst := []byte{239, 192, 168, 1}
+	ck := udpChecksum(udp, src, dst)
+	if ck != 0x35d1 {
+		t.Errorf("udpChecksum = %x, want 35d1", ck)
+	}
+	coder.PutUint16(udp[6:], ck)
+	// sum including checksum must be all ones
+	if udpChecksum(udp, src, dst) != 0xffff {
+		t.Errorf("udpChecksum %x not verified", ck)
+	}
+}
@@ -0,0 +1,101 @@
+package MoldUDP
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// pktBufSize	initial capacity of pooled packet payload
+	pktBufSize = 2048
+	// maxHeldPkts	packet buffers referenced by messages delivered or
+	//				cached, oldest dropped to GC if not released in time
+	maxHeldPkts = 4096
+)
+
+// pktBuf	pooled payload of packet and messages unmarshaled from it,
+//			reference counted, back to pool when last reference released
+type pktBuf struct {
+	data []byte
+	msgs []Message
+	refs int32
+}
+
+var pktPool = sync.Pool{New: func() interface{} {
+	return &pktBuf{data: make([]byte, 0, pktBufSize)}
+}}
+
+// getPktBuf	packet buffer with payload copied, one reference
+func getPktBuf(payload []byte) *pktBuf {
+	pb := pktPool.Get().(*pktBuf)
+	pb.data = append(pb.data[:0], payload...)
+	pb.refs = 1
+	return pb
+}
+
+func (pb *pktBuf) retain() {
+	atomic.AddInt32(&pb.refs, 1)
+}
+
+func (pb *pktBuf) release() {
+	if atomic.AddInt32(&pb.refs, -1) == 0 {
+		pb.msgs = pb.msgs[:0]
+		pktPool.Put(pb)
+	}
+}
+
+// unmarshal	messages of payload, reuse msgs of pb
+func (pb *pktBuf) unmarshal(cnt int) ([]Message, error) {
+	if cap(pb.msgs) < cnt {
+		pb.msgs = make([]Message, cnt)
+	}
+	pb.msgs = pb.msgs[:cnt]
+	return unmarshal(pb.data, cnt, pb.msgs)
+}
+
+// heldPkt	packet buffer held till messages before end released
+type heldPkt struct {
+	pb  *pktBuf
+	end uint64
+}
+
+// heldRing	FIFO of packet buffers held, fixed size
+type heldRing struct {
+	pkts       []heldPkt
+	head, tail int
+}
+
+// push		hold pb for messages before end, oldest dropped without
+//			release if full
+func (hr *heldRing) push(pb *pktBuf, end uint64) {
+	if hr.pkts == nil {
+		hr.pkts = make([]heldPkt, maxHeldPkts)
+	}
+	if hr.tail-hr.head == maxHeldPkts {
+		hr.pkts[hr.head%maxHeldPkts] = heldPkt{}
+		hr.head++
+	}
+	hr.pkts[hr.tail%maxHeldPkts] = heldPkt{pb: pb, end: end}
+	hr.tail++
+}
+
+// release	packet buffers in order of hold with end upto seqNo
+func (hr *heldRing) release(seqNo uint64) {
+	for hr.head < hr.tail {
+		hp := &hr.pkts[hr.head%maxHeldPkts]
+		if hp.end > seqNo {
+			break
+		}
+		hp.pb.release()
+		*hp = heldPkt{}
+		hr.head++
+	}
+}
+
+// reset	drop all held packet buffers to GC
+func (hr *heldRing) reset() {
+	for ; hr.head < hr.tail; hr.head++ {
+		hr.pkts[hr.head%maxHeldPkts] = heldPkt{}
+	}
+	hr.head, hr.tail = 0, 0
+}
@@ -0,0 +1,197 @@
+package MoldUDP
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHeldRing(t *testing.T) {
+	var hr heldRing
+	pbs := make([]*pktBuf, 3)
+	for i := range pbs {
+		pbs[i] = getPktBuf(nil)
+		hr.push(pbs[i], uint64(10*(i+1)))
+	}
+	// held by reader too
+	pbs[1].retain()
+	tests := []struct {
+		seqNo uint64
+		refs  []int32
+	}{
+		{5, []int32{1, 2, 1}},
+		{10, []int32{0, 2, 1}},
+		{25, []int32{0, 1, 1}},
+		{30, []int32{0, 1, 0}},
+	}
+	for _, tt := range tests {
+		hr.release(tt.seqNo)
+		for i, pb := range pbs {
+			if pb.refs != tt.refs[i] {
+				t.Errorf("release(%d) buffer %d refs %d, want %d", tt.seqNo, i,
+					pb.refs, tt.refs[i])
+			}
+		}
+	}
+	// oldest dropped to GC if full
+	for i := 0; i <= maxHeldPkts; i++ {
+		hr.push(getPktBuf(nil), uint64(100+i))
+	}
+	if n := hr.tail - hr.head; n != maxHeldPkts {
+		t.Errorf("%d packets held, want %d", n, maxHeldPkts)
+	}
+	hr.reset()
+	if hr.tail != hr.head {
+		t.Error("reset() left packets held")
+	}
+}
+
+// feedPackets	deliver packets of seqNo order via parseBuff and doMsg
+func feedPackets(c *Client, pkts []Packet, order []int) {
+	for _, i := range order {
+		var head Header
+		decodeHead(pkts[i], &head, "test0")
+		msgBB, _ := c.parseBuff(pkts[i], len(pkts[i]), &head, nil, false)
+		c.doMsg(&msgBB)
+	}
+}
+
+func TestClientRelease(t *testing.T) {
+	msgs := make([]Message, 40)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	pkts := make([]Packet, 4)
+	for i := range pkts {
+		pkts[i] = buildPacket("test0", uint64(10*i+1), 0, msgs[10*i:10*i+10])
+	}
+	c := newTestClient(1)
+	c.Release(0)
+	// packet 2 cached till 1 received
+	feedPackets(c, pkts, []int{0, 2, 1})
+	res, seqNo, err := c.Read()
+	if err != nil || seqNo != 1 || len(res) != 30 {
+		t.Fatalf("Read() %d messages from %d, %v", len(res), seqNo, err)
+	}
+	for i := range res {
+		if string(res[i].Data) != string(msgs[i].Data) {
+			t.Errorf("message %d: %s", i+1, res[i].Data)
+		}
+	}
+	if n := c.held.tail - c.held.head; n != 3 {
+		t.Errorf("%d packets held, want 3", n)
+	}
+	// released in order held, cached packet 2 before 1
+	c.Release(21)
+	if n := c.held.tail - c.held.head; n != 2 {
+		t.Errorf("%d packets held after Release(21), want 2", n)
+	}
+	if c.spare != nil {
+		t.Error("slice read recycled before all released")
+	}
+	c.Release(31)
+	if n := c.held.tail - c.held.head; n != 0 || cap(c.spare) < 30 {
+		t.Errorf("%d packets held, spare cap %d after Release(31)", n, cap(c.spare))
+	}
+	// packet 3 not read before rollover
+	feedPackets(c, pkts, []int{3})
+	c.rollover = SessionFollow
+	c.resetSession("test1")
+	pkt := buildPacket("test1", 1, 0, msgs[:10])
+	var head Header
+	DecodeHead(pkt, &head)
+	msgBB, _ := c.parseBuff(pkt, len(pkt), &head, nil, false)
+	c.doMsg(&msgBB)
+	if res, seqNo, _ := c.Read(); seqNo != 31 || len(res) != 10 ||
+		string(res[9].Data) != "message 40" {
+		t.Errorf("Read() recycled slice %d messages from %d", len(res), seqNo)
+	}
+	// buffers of new session not released by seqNo of old one
+	c.Release(41)
+	if n := c.held.tail - c.held.head; n != 1 {
+		t.Errorf("%d packets of new session held, want 1", n)
+	}
+	if res, seqNo, _ := c.Read(); seqNo != 1 || len(res) != 10 {
+		t.Errorf("Read() new session %d messages from %d", len(res), seqNo)
+	}
+	c.Release(11)
+	if n := c.held.tail - c.held.head; n != 0 {
+		t.Errorf("%d packets held after Release(11), want 0", n)
+	}
+}
+
+// recvStep	packet of msgs at seqNo encoded in buff, received and read,
+//			next seqNo returned
+func recvStep(c *Client, buff []byte, msgs []Message, seqNo uint64, bRelease bool) (uint64, error) {
+	_, n := packMessages(buff, "test0", seqNo, msgs)
+	var head Header
+	known, _ := c.sessHint.Load().(string)
+	if err := decodeHead(buff[:n], &head, known); err != nil {
+		return seqNo, err
+	}
+	msgBB, err := c.parseBuff(buff, n, &head, nil, true)
+	if err != nil {
+		return seqNo, err
+	}
+	c.doMsg(&msgBB)
+	res, sn, err := c.Read()
+	if err != nil || sn != seqNo || len(res) != len(msgs) {
+		return seqNo, errMessageCnt
+	}
+	seqNo += uint64(len(res))
+	if bRelease {
+		c.Release(seqNo)
+	}
+	return seqNo, nil
+}
+
+func TestRecvPathAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops buffers randomly with race detector")
+	}
+	msgs := make([]Message, 20)
+	for i := range msgs {
+		msgs[i].Data = make([]byte, 60)
+	}
+	c := newTestClient(1)
+	buff := make([]byte, maxUDPSize)
+	seqNo := uint64(1)
+	var err error
+	// warm up pool, held ring and spare slice
+	for i := 0; i < 10; i++ {
+		if seqNo, err = recvStep(c, buff, msgs, seqNo, true); err != nil {
+			t.Fatal("recvStep", err)
+		}
+	}
+	allocs := testing.AllocsPerRun(1000, func() {
+		if seqNo, err = recvStep(c, buff, msgs, seqNo, true); err != nil {
+			t.Fatal("recvStep", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("%.2f allocations per packet received and released, want 0", allocs)
+	}
+}
+
+func benchRecvPath(b *testing.B, bRelease bool) {
+	msgs := make([]Message, 20)
+	for i := range msgs {
+		msgs[i].Data = make([]byte, 60)
+	}
+	c := newTestClient(1)
+	// recv buffer of packets, encoded in place
+	buff := make([]byte, maxUDPSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	seqNo := uint64(1)
+	var err error
+	for i := 0; i < b.N; i++ {
+		if seqNo, err = recvStep(c, buff, msgs, seqNo, bRelease); err != nil {
+			b.Fatal("recvStep", err)
+		}
+	}
+}
+
+func BenchmarkRecvPath(b *testing.B) {
+	b.Run("release", func(b *testing.B) { benchRecvPath(b, true) })
+	b.Run("no-release", func(b *testing.B) { benchRecvPath(b, false) })
+}
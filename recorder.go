@@ -0,0 +1,301 @@
+package MoldUDP
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// recorded session file
+//	header	magic(8) session(10)
+//	record	seqNo(8) recvTime(8) length(2) data
+// index file, fileName + ".idx", entry for every indexInterval messages
+//	entry	seqNo(8) offset(8)
+const (
+	recMagic      = "MOLDREC\x01"
+	recHeadSize   = 18
+	recordHead    = 18
+	idxEntrySize  = 16
+	indexInterval = 1024
+	idxSuffix     = ".idx"
+)
+
+var (
+	errRecMagic    = errors.New("Not a MoldUDP record file")
+	errRecSeq      = errors.New("Record sequence not continuous")
+	errRecSession  = errors.New("Record session dismatch")
+	errRecReadOnly = errors.New("Record file read only")
+)
+
+// Recorder	append messages delivered in order to session file with
+//			receive timestamp, indexed by sequence number
+type Recorder struct {
+	lock    sync.Mutex
+	file    *os.File
+	idxFile *os.File
+	w       *bufio.Writer
+	idxW    *bufio.Writer
+	session string
+	nextSeq uint64
+	offset  int64
+	nIndex  int
+	nError  int
+}
+
+// NewRecorder	create(truncate) session file and its index file
+func NewRecorder(fileName string) (*Recorder, error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	idxFile, err := os.Create(fileName + idxSuffix)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	rec := Recorder{file: file, idxFile: idxFile}
+	rec.w = bufio.NewWriterSize(file, 65536)
+	rec.idxW = bufio.NewWriter(idxFile)
+	return &rec, nil
+}
+
+func (r *Recorder) writeHead(session string) error {
+	var buff [recHeadSize]byte
+	copy(buff[:8], recMagic)
+	for i := 8; i < recHeadSize; i++ {
+		buff[i] = ' '
+	}
+	copy(buff[8:], session)
+	if _, err := r.w.Write(buff[:]); err != nil {
+		return err
+	}
+	r.session = session
+	r.offset = recHeadSize
+	return nil
+}
+
+// Write	append messages, first with sequence number seqNo
+//	recvTime	receive time in nanoseconds since epoch
+func (r *Recorder) Write(session string, seqNo uint64, msgs []Message, recvTime int64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.offset == 0 {
+		if err := r.writeHead(session); err != nil {
+			r.nError++
+			return err
+		}
+	} else if session != r.session {
+		r.nError++
+		return errRecSession
+	}
+	if r.nextSeq != 0 && seqNo != r.nextSeq {
+		r.nError++
+		return errRecSeq
+	}
+	var buff [recordHead]byte
+	for i := range msgs {
+		if r.nIndex%indexInterval == 0 {
+			var idx [idxEntrySize]byte
+			coder.PutUint64(idx[:8], seqNo)
+			coder.PutUint64(idx[8:], uint64(r.offset))
+			if _, err := r.idxW.Write(idx[:]); err != nil {
+				r.nError++
+				return err
+			}
+		}
+		mLen := len(msgs[i].Data)
+		coder.PutUint64(buff[:8], seqNo)
+		coder.PutUint64(buff[8:16], uint64(recvTime))
+		coder.PutUint16(buff[16:18], uint16(mLen))
+		if _, err := r.w.Write(buff[:]); err != nil {
+			r.nError++
+			return err
+		}
+		if _, err := r.w.Write(msgs[i].Data); err != nil {
+			r.nError++
+			return err
+		}
+		r.offset += int64(recordHead + mLen)
+		r.nIndex++
+		seqNo++
+	}
+	r.nextSeq = seqNo
+	return nil
+}
+
+// Flush	flush buffered records and index to files
+func (r *Recorder) Flush() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	return r.idxW.Flush()
+}
+
+func (r *Recorder) Close() error {
+	err := r.Flush()
+	r.file.Close()
+	r.idxFile.Close()
+	return err
+}
+
+type idxEntry struct {
+	seqNo  uint64
+	offset int64
+}
+
+// RecordFile	read session file recorded by Recorder, implements
+//				MessageStore(read only) for Rewinder
+type RecordFile struct {
+	lock    sync.Mutex
+	file    *os.File
+	session string
+	index   []idxEntry
+	nextSeq uint64
+	size    int64
+	r       *bufio.Reader
+}
+
+// OpenRecord	open session file and load its index
+func OpenRecord(fileName string) (*RecordFile, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	rf := RecordFile{file: file}
+	if err := rf.load(fileName + idxSuffix); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &rf, nil
+}
+
+func (rf *RecordFile) load(idxName string) error {
+	var buff [recHeadSize]byte
+	if _, err := io.ReadFull(rf.file, buff[:]); err != nil {
+		return errRecMagic
+	}
+	if string(buff[:8]) != recMagic {
+		return errRecMagic
+	}
+	rf.session = strings.TrimRight(string(buff[8:]), " ")
+	if fi, err := rf.file.Stat(); err == nil {
+		rf.size = fi.Size()
+	}
+	idx, err := os.ReadFile(idxName)
+	if err != nil {
+		return err
+	}
+	for i := 0; i+idxEntrySize <= len(idx); i += idxEntrySize {
+		ent := idxEntry{seqNo: coder.Uint64(idx[i : i+8]),
+			offset: int64(coder.Uint64(idx[i+8 : i+16]))}
+		if ent.offset >= rf.size {
+			// index flushed before records
+			break
+		}
+		rf.index = append(rf.index, ent)
+	}
+	// locate last message from last index entry
+	if len(rf.index) > 0 {
+		rf.seekOffset(rf.index[len(rf.index)-1].offset)
+		for {
+			seqNo, _, _, err := rf.Next()
+			if err != nil {
+				break
+			}
+			rf.nextSeq = seqNo + 1
+		}
+	}
+	return rf.Seek(0)
+}
+
+// Session	session name of record
+func (rf *RecordFile) Session() string {
+	return rf.session
+}
+
+// NextSeq	sequence number after last message recorded
+func (rf *RecordFile) NextSeq() uint64 {
+	return rf.nextSeq
+}
+
+func (rf *RecordFile) seekOffset(off int64) {
+	rf.r = bufio.NewReader(io.NewSectionReader(rf.file, off, rf.size-off))
+}
+
+// Seek		position reader to message seqNo via index, 0 for first
+func (rf *RecordFile) Seek(seqNo uint64) error {
+	i := sort.Search(len(rf.index), func(i int) bool {
+		return rf.index[i].seqNo > seqNo
+	})
+	if i > 0 {
+		i--
+	}
+	if len(rf.index) == 0 {
+		rf.seekOffset(recHeadSize)
+		return nil
+	}
+	rf.seekOffset(rf.index[i].offset)
+	for {
+		buff, err := rf.r.Peek(8)
+		if err != nil || coder.Uint64(buff) >= seqNo {
+			return nil
+		}
+		if _, _, _, err := rf.Next(); err != nil {
+			return err
+		}
+	}
+}
+
+// Next		read next message, io.EOF for end of record
+func (rf *RecordFile) Next() (seqNo uint64, recvTime int64, msg Message, err error) {
+	var buff [recordHead]byte
+	if _, err = io.ReadFull(rf.r, buff[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return
+	}
+	seqNo = coder.Uint64(buff[:8])
+	recvTime = int64(coder.Uint64(buff[8:16]))
+	msg.Data = make([]byte, coder.Uint16(buff[16:18]))
+	if _, err = io.ReadFull(rf.r, msg.Data); err != nil {
+		err = io.EOF
+		return
+	}
+	return
+}
+
+func (rf *RecordFile) Append(seqNo uint64, msgs []Message) error {
+	return errRecReadOnly
+}
+
+// Get		read up to cnt messages from seqNo, moves reader position
+func (rf *RecordFile) Get(seqNo uint64, cnt int) []Message {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if seqNo >= rf.nextSeq || cnt <= 0 {
+		return nil
+	}
+	if err := rf.Seek(seqNo); err != nil {
+		return nil
+	}
+	var res []Message
+	for len(res) < cnt {
+		sn, _, msg, err := rf.Next()
+		if err != nil || sn != seqNo+uint64(len(res)) {
+			break
+		}
+		res = append(res, msg)
+	}
+	return res
+}
+
+func (rf *RecordFile) Close() error {
+	return rf.file.Close()
+}
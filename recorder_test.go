@@ -0,0 +1,121 @@
+package MoldUDP
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "test0.rec")
+	rec, err := NewRecorder(fileName)
+	if err != nil {
+		t.Fatal("NewRecorder", err)
+	}
+	feed := itchFeed(3000)
+	tt := time.Now().UnixNano()
+	for i := 0; i < len(feed); i += 100 {
+		if err := rec.Write("test0", uint64(i+1), feed[i:i+100], tt+int64(i)); err != nil {
+			t.Fatal("Write()", err)
+		}
+	}
+	if err := rec.Write("test0", 5000, feed[:1], tt); err != errRecSeq {
+		t.Errorf("Write() gap error = %v, want %v", err, errRecSeq)
+	}
+	if err := rec.Write("test1", 3001, feed[:1], tt); err != errRecSession {
+		t.Errorf("Write() session error = %v, want %v", err, errRecSession)
+	}
+	rec.Close()
+
+	rf, err := OpenRecord(fileName)
+	if err != nil {
+		t.Fatal("OpenRecord", err)
+	}
+	defer rf.Close()
+	if rf.Session() != "test0" || rf.NextSeq() != 3001 {
+		t.Errorf("record session %s nextSeq %d, want test0 3001", rf.Session(),
+			rf.NextSeq())
+	}
+	if len(rf.index) != 3 {
+		t.Errorf("record index %d entries, want 3", len(rf.index))
+	}
+	res := rf.Get(2500, 3)
+	if len(res) != 3 {
+		t.Fatalf("Get(2500, 3) got %d messages", len(res))
+	}
+	for i, msg := range res {
+		if string(msg.Data) != string(feed[2499+i].Data) {
+			t.Errorf("Get(2500, 3)[%d] = %v, want %v", i, msg.Data, feed[2499+i].Data)
+		}
+	}
+	if res := rf.Get(2999, 10); len(res) != 2 {
+		t.Errorf("Get(2999, 10) got %d messages, want 2", len(res))
+	}
+	if err := rf.Seek(1025); err != nil {
+		t.Fatal("Seek()", err)
+	}
+	seqNo, recvTime, _, err := rf.Next()
+	if err != nil || seqNo != 1025 || recvTime != tt+1000 {
+		t.Errorf("Next() = %d, %d, %v, want 1025, %d", seqNo, recvTime, err, tt+1000)
+	}
+	rf.Seek(3000)
+	rf.Next()
+	if _, _, _, err := rf.Next(); err != io.EOF {
+		t.Errorf("Next() at end error = %v, want EOF", err)
+	}
+}
+
+func TestClientRecorder(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "client.rec")
+	rec, err := NewRecorder(fileName)
+	if err != nil {
+		t.Fatal("NewRecorder", err)
+	}
+	conn := newChanConn()
+	cc, err := NewClient("239.192.168.1", 5858, &Option{Recorder: rec}, conn, false)
+	if err != nil {
+		t.Fatal("NewClient", err)
+	}
+	defer cc.Close()
+	feed := itchFeed(10)
+	// 5-7 and 8-10 cached till 1-4 received, each recorded with own time
+	conn.rx <- buildPacket("rec0", 5, 0, feed[4:7])
+	time.Sleep(10 * time.Millisecond)
+	conn.rx <- buildPacket("rec0", 8, 0, feed[7:])
+	time.Sleep(10 * time.Millisecond)
+	conn.rx <- buildPacket("rec0", 1, 0, feed[:4])
+	conn.rx <- buildPacket("rec0", 11, 0xffff, nil)
+	if err := cc.Subscribe(func(uint64, Message) {}); err != ErrEndOfSession {
+		t.Error("Subscribe()", err)
+	}
+	rec.Close()
+	rf, err := OpenRecord(fileName)
+	if err != nil {
+		t.Fatal("OpenRecord", err)
+	}
+	defer rf.Close()
+	if rf.Session() != "rec0" || rf.NextSeq() != 11 {
+		t.Errorf("record session %s nextSeq %d, want rec0 11", rf.Session(),
+			rf.NextSeq())
+	}
+	var times [11]int64
+	for i := 1; i <= 10; i++ {
+		seqNo, recvTime, _, err := rf.Next()
+		if err != nil || seqNo != uint64(i) {
+			t.Fatalf("Next() = %d, %v, want %d", seqNo, err, i)
+		}
+		times[i] = recvTime
+	}
+	for _, run := range [][]int{{1, 4}, {5, 7}, {8, 10}} {
+		for i := run[0]; i <= run[1]; i++ {
+			if times[i] != times[run[0]] {
+				t.Errorf("message %d recvTime %d, want %d", i, times[i], times[run[0]])
+			}
+		}
+	}
+	if !(times[5] < times[8] && times[8] < times[1]) {
+		t.Errorf("recvTime of 1, 5, 8: %d, %d, %d, want received order 5, 8, 1",
+			times[1], times[5], times[8])
+	}
+}
@@ -0,0 +1,206 @@
+package MoldUDP
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// recoverChunk	max messages queued for one chunk of late join recovery
+	recoverChunk = 4096
+	// reqStoreWait	wait for first reply packet of request
+	reqStoreWait = 200 * time.Millisecond
+	// reqStoreIdle	reply burst ended if no packet for reqStoreIdle
+	reqStoreIdle  = 50 * time.Millisecond
+	reqStoreRetry = 3
+	// recoverDoneWait	max wait for messages queued by recovery done
+	recoverDoneWait = time.Second
+)
+
+// reqStore	read only MessageStore via retransmission request server,
+//			one request for each Get
+type reqStore struct {
+	conn    *net.UDPConn
+	session string
+	buff    []byte
+}
+
+func newReqStore(srv *net.UDPAddr, session string) (*reqStore, error) {
+	conn, err := net.DialUDP("udp", nil, srv)
+	if err != nil {
+		return nil, err
+	}
+	// room for reply burst, limited by rmem_max
+	conn.SetReadBuffer(maxReplyPkts * maxDatagramSize)
+	return &reqStore{conn: conn, session: session, buff: make([]byte, 2048)}, nil
+}
+
+func (rs *reqStore) Close() error {
+	return rs.conn.Close()
+}
+
+func (rs *reqStore) Append(seqNo uint64, msgs []Message) error {
+	return errNotSupport
+}
+
+func (rs *reqStore) NextSeq() uint64 {
+	return 0
+}
+
+// Get		messages in order replied for request of cnt messages from seqNo,
+//			request server may reply part of them
+func (rs *reqStore) Get(seqNo uint64, cnt int) []Message {
+	if cnt > nakWindow {
+		cnt = nakWindow
+	}
+	var res []Message
+	for try := 0; try < reqStoreRetry && len(res) == 0; try++ {
+		var req [headSize]byte
+		EncodeHead(req[:], &Header{Session: rs.session, SeqNo: seqNo,
+			MessageCnt: uint16(cnt)})
+		if _, err := rs.conn.Write(req[:]); err != nil {
+			log.Error("Req Write", err)
+			return nil
+		}
+		res = rs.read(seqNo, cnt)
+	}
+	return res
+}
+
+// read		reply packets till cnt messages or reply burst ended
+func (rs *reqStore) read(seqNo uint64, cnt int) (res []Message) {
+	wait := reqStoreWait
+	for len(res) < cnt {
+		rs.conn.SetReadDeadline(time.Now().Add(wait))
+		n, err := rs.conn.Read(rs.buff)
+		if err != nil {
+			return
+		}
+		wait = reqStoreIdle
+		var head Header
+		if DecodeHead(rs.buff[:n], &head) != nil || head.Session != rs.session {
+			continue
+		}
+		next := seqNo + uint64(len(res))
+		if head.MessageCnt == 0 || head.MessageCnt == 0xffff || head.SeqNo > next {
+			continue
+		}
+		// messages of packet share one copy
+		data := append([]byte{}, rs.buff[headSize:n]...)
+		msgs, err := Unmarshal(data, int(head.MessageCnt))
+		if err != nil || head.SeqNo+uint64(len(msgs)) <= next {
+			continue
+		}
+		msgs = msgs[int(next-head.SeqNo):]
+		if len(res)+len(msgs) > cnt {
+			msgs = msgs[:cnt-len(res)]
+		}
+		res = append(res, msgs...)
+	}
+	return
+}
+
+// startLateJoin	recover from seqNo of client if opt.LateJoin
+func (c *Client) startLateJoin(opt *Option, store MessageStore) {
+	if !opt.LateJoin {
+		return
+	}
+	c.startOnFirst, c.firstOnNew = false, false
+	c.liveC = make(chan struct{})
+	c.recovering = 1
+	go c.lateJoin(store, c.seqNo)
+}
+
+// lateJoin	queue messages from seqNo read from store in chunks till
+//			sequence number of first live packet, via request server
+//			of client if store is nil, gap left requested as usual
+func (c *Client) lateJoin(store MessageStore, seqNo uint64) {
+	defer atomic.StoreInt32(&c.recovering, 0)
+	if store == nil {
+		select {
+		case <-c.liveC:
+		case <-c.ctx.Done():
+			return
+		}
+		c.recvLock.Lock()
+		session, reqSrv := c.session, c.reqSrv
+		c.recvLock.Unlock()
+		if len(reqSrv) == 0 {
+			log.Info("No request server for late join")
+			return
+		}
+		rs, err := newReqStore(&reqSrv[0], session)
+		if err != nil {
+			log.Error("late join request server", err)
+			return
+		}
+		defer rs.Close()
+		store = rs
+	}
+	log.Info("Late join recovery from", seqNo)
+	seqNo = c.recoverLoop(store, seqNo, 0)
+	c.waitQueued(seqNo)
+	log.Info("Late join recovery stopped at", seqNo)
+}
+
+// waitQueued	wait messages queued by recovery before next done, live
+//				packets queued ahead of them not taken as gap
+func (c *Client) waitQueued(next uint64) {
+	for tt := time.Now(); time.Since(tt) < recoverDoneWait && c.ctx.Err() == nil; {
+		if sn, n := c.LastSeq(); sn+uint64(n) >= next {
+			break
+		}
+		time.Sleep(reqInterval)
+	}
+}
+
+// bulkRecover	queue gap [seqNo, until) read from recovery store of client,
+//				gap left if stopped short requested as usual
+func (c *Client) bulkRecover(seqNo, until uint64) {
+	defer atomic.StoreInt32(&c.recovering, 0)
+	log.Info("Bulk recovery from", seqNo, "to", until)
+	next := c.recoverLoop(c.recovery, seqNo, until)
+	if next < until {
+		atomic.StoreUint64(&c.bulkStop, next)
+	}
+	c.waitQueued(next)
+	log.Info("Bulk recovery stopped at", next)
+}
+
+// recoverLoop	queue messages from seqNo read from store in chunks till
+//				until, or sequence number of first live packet if until
+//				is 0, return sequence number stopped at
+func (c *Client) recoverLoop(store MessageStore, seqNo, until uint64) uint64 {
+	for c.ctx.Err() == nil {
+		end := until
+		if end == 0 {
+			c.recvLock.Lock()
+			end = c.liveSeq
+			c.recvLock.Unlock()
+		}
+		cnt := recoverChunk
+		if end != 0 {
+			if seqNo >= end {
+				break
+			}
+			if end-seqNo < uint64(cnt) {
+				cnt = int(end - seqNo)
+			}
+		}
+		msgs := store.Get(seqNo, cnt)
+		if len(msgs) == 0 {
+			break
+		}
+		if len(msgs) > cnt {
+			msgs = msgs[:cnt]
+		}
+		msgBB := msgBuf{seqNo: seqNo, recvTime: time.Now().UnixNano(),
+			msgCnt: uint16(len(msgs)), msgs: msgs}
+		if err := c.queue(msgBB); err != nil {
+			break
+		}
+		seqNo += uint64(len(msgs))
+	}
+	return seqNo
+}
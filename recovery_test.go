@@ -0,0 +1,199 @@
+package MoldUDP
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestReqStore(t *testing.T) {
+	store := NewMemStore(1)
+	msgs := make([]Message, 300)
+	for i := range msgs {
+		// one message for each reply packet
+		msgs[i].Data = bytes.Repeat([]byte{byte(i)}, 1000)
+	}
+	store.Append(1, msgs)
+	rr, err := NewRewinder("test0", 0, store)
+	if err != nil {
+		t.Fatal("NewRewinder", err)
+	}
+	go rr.Serve()
+	defer rr.Close()
+	rs, err := newReqStore(rr.LocalAddr(), "test0")
+	if err != nil {
+		t.Fatal("newReqStore", err)
+	}
+	defer rs.Close()
+	tests := []struct {
+		seqNo uint64
+		cnt   int
+		want  int
+	}{
+		{10, 50, 50},
+		{290, 20, 11},
+		// reply limited to maxReplyPkts, or less by socket buffer
+		{1, 300, maxReplyPkts},
+		{301, 10, 0},
+	}
+	for _, tt := range tests {
+		res := rs.Get(tt.seqNo, tt.cnt)
+		if tt.want == maxReplyPkts && len(res) > 0 && len(res) <= tt.want {
+			tt.want = len(res)
+		}
+		if len(res) != tt.want {
+			t.Errorf("Get(%d, %d) %d messages, want %d", tt.seqNo, tt.cnt, len(res),
+				tt.want)
+			continue
+		}
+		for i := range res {
+			if !bytes.Equal(res[i].Data, msgs[int(tt.seqNo)-1+i].Data) {
+				t.Errorf("Get(%d, %d) message %d mismatch", tt.seqNo, tt.cnt,
+					tt.seqNo+uint64(i))
+				break
+			}
+		}
+	}
+}
+
+func TestLateJoin(t *testing.T) {
+	tests := []struct {
+		name      string
+		nStore    int
+		bReqSrv   bool
+		recovered int64
+	}{
+		{"store", 100, false, 100},
+		{"request server", 0, true, 100},
+		{"partial store", 50, true, 50},
+	}
+	msgs := make([]Message, 200)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	for i, tt := range tests {
+		port := 6320 + 2*i
+		srvStore := NewMemStore(1)
+		rr, err := NewRewinder("late0", 0, srvStore)
+		if err != nil {
+			t.Fatal("NewRewinder", err)
+		}
+		go rr.Serve()
+		srv, err := NewServer("239.192.168.30", port, "late0", &Option{},
+			NewIf("mem"), false)
+		if err != nil {
+			t.Fatal("NewServer", err)
+		}
+		srv.SetStore(srvStore)
+		// published before client joined
+		for j := 0; j < 100; j += 10 {
+			srv.Send(msgs[j : j+10])
+		}
+		opt := Option{LateJoin: true}
+		if tt.nStore > 0 {
+			opt.Recovery = NewMemStore(1)
+			opt.Recovery.Append(1, msgs[:tt.nStore])
+		}
+		if tt.bReqSrv {
+			opt.Srvs = []string{fmt.Sprintf("127.0.0.1:%d", rr.LocalAddr().Port)}
+		}
+		cc, err := NewClient("239.192.168.30", port, &opt, NewIf("mem"), true)
+		if err != nil {
+			t.Fatal("NewClient", err)
+		}
+		go func() {
+			for j := 100; j < len(msgs); j += 10 {
+				srv.Send(msgs[j : j+10])
+			}
+			srv.Close()
+		}()
+		tm := time.AfterFunc(5*time.Second, cc.Stop)
+		seqNo := uint64(1)
+		err = cc.Subscribe(func(sn uint64, msg Message) {
+			if sn != seqNo || string(msg.Data) != string(msgs[seqNo-1].Data) {
+				t.Errorf("%s: message %d: %s, want %d", tt.name, sn, msg.Data, seqNo)
+			}
+			seqNo++
+		})
+		tm.Stop()
+		if err != ErrEndOfSession || seqNo != uint64(len(msgs))+1 {
+			t.Errorf("%s: Subscribe() %v, got %d messages", tt.name, err, seqNo-1)
+		}
+		cc.Close()
+		rr.Close()
+		if cc.nRecovered != tt.recovered {
+			t.Errorf("%s: %d messages recovered, want %d", tt.name, cc.nRecovered,
+				tt.recovered)
+		}
+	}
+}
+
+// gateStore	MessageStore blocks Get till gate closed
+type gateStore struct {
+	MessageStore
+	gate chan struct{}
+}
+
+func (s *gateStore) Get(seqNo uint64, cnt int) []Message {
+	<-s.gate
+	return s.MessageStore.Get(seqNo, cnt)
+}
+
+func TestLateJoinAhead(t *testing.T) {
+	const (
+		port  = 6330
+		nPrev = 1000
+		// live messages more than cache window(1<<20) ahead of recovery
+		nLive = 1100000
+	)
+	msgs := make([]Message, nPrev+nLive)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("%08d", i+1))
+	}
+	srvStore := NewMemStore(1)
+	srv, err := NewServer("239.192.168.31", port, "late1", &Option{},
+		NewIf("mem"), false)
+	if err != nil {
+		t.Fatal("NewServer", err)
+	}
+	srv.SetStore(srvStore)
+	srv.Send(msgs[:nPrev])
+	store := &gateStore{MessageStore: srvStore, gate: make(chan struct{})}
+	conn := NewIf("mem")
+	cc, err := NewClient("239.192.168.31", port, &Option{LateJoin: true,
+		Recovery: store}, conn, true)
+	if err != nil {
+		t.Fatal("NewClient", err)
+	}
+	defer cc.Close()
+	go func() {
+		for j := nPrev; j < len(msgs); j += 1000 {
+			srv.Send(msgs[j : j+1000])
+			// no packet dropped by queue of mem interface
+			for len(conn.(*memIf).rx) > memQueueLen/2 && cc.ctx.Err() == nil {
+				time.Sleep(time.Millisecond)
+			}
+		}
+		close(store.gate)
+		srv.Close()
+	}()
+	tm := time.AfterFunc(30*time.Second, cc.Stop)
+	defer tm.Stop()
+	seqNo := uint64(1)
+	err = cc.Subscribe(func(sn uint64, msg Message) {
+		if sn != seqNo || string(msg.Data) != string(msgs[seqNo-1].Data) {
+			t.Fatalf("message %d: %s, want %d", sn, msg.Data, seqNo)
+		}
+		seqNo++
+	})
+	if err != ErrEndOfSession || seqNo != uint64(len(msgs))+1 {
+		t.Fatalf("Subscribe() %v, got %d messages", err, seqNo-1)
+	}
+	// history recovered from store, first chunk read before live packet,
+	// live stitched without requests
+	if st := cc.Stats(); st.Recovered < nPrev || st.Recovered > nPrev+recoverChunk ||
+		st.Requests != 0 {
+		t.Errorf("%d messages recovered, %d requests", st.Recovered, st.Requests)
+	}
+}
@@ -0,0 +1,86 @@
+package MoldUDP
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const (
+	// maxReplayBatch	max messages of one Send while replaying
+	maxReplayBatch = 256
+)
+
+// FirstSeq	sequence number of first message recorded
+func (rf *RecordFile) FirstSeq() uint64 {
+	if len(rf.index) == 0 {
+		return 0
+	}
+	return rf.index[0].seqNo
+}
+
+// Replay	re-publish recorded messages via srv, start from srv.SeqNo(),
+//			messages received at same time are sent in one batch
+//	rf		shall not be shared with Rewinder, use another OpenRecord
+//	speed	1 for original inter-arrival timing, 2 for twice faster,
+//			0 for as fast as possible
+//	return	number of messages published
+func Replay(ctx context.Context, rf *RecordFile, srv *Server, speed float64) (int, error) {
+	seqNo := srv.SeqNo()
+	if err := rf.Seek(seqNo); err != nil {
+		return 0, err
+	}
+	var batch []Message
+	var batchTime, firstTime int64
+	start := time.Now()
+	nSent := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := srv.Send(batch)
+		nSent += n
+		batch = batch[:0]
+		return err
+	}
+	for {
+		sn, recvTime, msg, err := rf.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nSent, err
+		}
+		if sn != seqNo {
+			return nSent, errRecSeq
+		}
+		seqNo++
+		if len(batch) > 0 && (recvTime != batchTime || len(batch) >= maxReplayBatch) {
+			if err := flush(); err != nil {
+				return nSent, err
+			}
+		}
+		if len(batch) == 0 {
+			if firstTime == 0 {
+				firstTime = recvTime
+			}
+			batchTime = recvTime
+			if speed > 0 {
+				due := time.Duration(float64(recvTime-firstTime) / speed)
+				if wait := due - time.Now().Sub(start); wait > 0 {
+					timer := time.NewTimer(wait)
+					select {
+					case <-ctx.Done():
+						timer.Stop()
+						return nSent, ctx.Err()
+					case <-timer.C:
+					}
+				}
+			} else if err := ctx.Err(); err != nil {
+				return nSent, err
+			}
+		}
+		batch = append(batch, msg)
+	}
+	err := flush()
+	return nSent, err
+}
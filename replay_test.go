@@ -0,0 +1,88 @@
+package MoldUDP
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "replay.rec")
+	rec, err := NewRecorder(fileName)
+	if err != nil {
+		t.Fatal("NewRecorder", err)
+	}
+	feed := itchFeed(300)
+	tt := time.Now().UnixNano()
+	for i := 0; i < len(feed); i += 100 {
+		// 10ms inter-arrival
+		rec.Write("rep0", uint64(i+1), feed[i:i+100], tt+int64(i/100)*1e7)
+	}
+	rec.Close()
+	rf, err := OpenRecord(fileName)
+	if err != nil {
+		t.Fatal("OpenRecord", err)
+	}
+	defer rf.Close()
+	if rf.FirstSeq() != 1 {
+		t.Errorf("FirstSeq() = %d, want 1", rf.FirstSeq())
+	}
+	tests := []struct {
+		speed   float64
+		nextSeq uint64
+		minDur  time.Duration
+		maxDur  time.Duration
+	}{
+		{1, 1, 20 * time.Millisecond, time.Second},
+		// no pacing, generous bound for loaded CI or -race
+		{0, 101, 0, 5 * time.Second},
+	}
+	for _, tc := range tests {
+		conn := &fakeConn{}
+		srv, err := NewServer("239.192.168.1", 5858, rf.Session(),
+			&Option{NextSeq: tc.nextSeq}, conn, false)
+		if err != nil {
+			t.Fatal("NewServer", err)
+		}
+		start := time.Now()
+		n, err := Replay(context.Background(), rf, srv, tc.speed)
+		du := time.Now().Sub(start)
+		if err != nil || n != len(feed)-int(tc.nextSeq-1) {
+			t.Errorf("Replay(speed %v) = %d, %v", tc.speed, n, err)
+		}
+		if du < tc.minDur || du > tc.maxDur {
+			t.Errorf("Replay(speed %v) took %v", tc.speed, du)
+		}
+		// all messages from nextSeq published in order
+		seqNo := tc.nextSeq
+		for _, pkt := range conn.sent() {
+			var head Header
+			DecodeHead(pkt, &head)
+			if head.Session != "rep0" || head.SeqNo != seqNo {
+				t.Errorf("packet head %+v, want seqNo %d", head, seqNo)
+				break
+			}
+			res, err := Unmarshal(pkt[headSize:], int(head.MessageCnt))
+			if err != nil {
+				t.Fatal("Unmarshal", err)
+			}
+			for _, msg := range res {
+				if string(msg.Data) != string(feed[seqNo-1].Data) {
+					t.Errorf("Replay(speed %v) message %d mismatch", tc.speed, seqNo)
+				}
+				seqNo++
+			}
+		}
+		if seqNo != uint64(len(feed))+1 {
+			t.Errorf("Replay(speed %v) published upto %d, want %d", tc.speed,
+				seqNo, len(feed)+1)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	srv, _ := NewServer("239.192.168.1", 5858, "rep0", &Option{}, &fakeConn{}, false)
+	if _, err := Replay(ctx, rf, srv, 0); err != context.Canceled {
+		t.Errorf("Replay() cancelled error = %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package MoldUDP
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+const (
+	// maxReplyPkts	max packets reply for one request, requester should
+	//				request again for the rest
+	maxReplyPkts = 256
+)
+
+// Rewinder struct for MoldUDP64 retransmission request server
+//	answer request(header only packet) via unicast UDP with messages
+//	from store
+type Rewinder struct {
+	Session  string
+	conn     *net.UDPConn
+	store    MessageStore
+	bClosed  int32
+	buffs    [maxReplyPkts]Packet
+	nRequest int
+	nRetrans int
+	nError   int
+}
+
+// NewRewinder	listen retransmission request on UDP port
+//	session		only answer request for session, blank for any
+//	store		messages to retransmit, e.g. the one of Server.SetStore
+func NewRewinder(session string, port int, store MessageStore) (*Rewinder, error) {
+	if len(session) > 10 {
+		return nil, errSessionLen
+	}
+	// any address of both families, requesters of IPv4 or IPv6 groups
+	laddr := net.UDPAddr{Port: port}
+	conn, err := net.ListenUDP("udp", &laddr)
+	if err != nil {
+		return nil, err
+	}
+	rr := Rewinder{Session: session, conn: conn, store: store}
+	for i := 0; i < maxReplyPkts; i++ {
+		rr.buffs[i] = make([]byte, maxUDPSize)
+	}
+	log.Info("Rewinder listen", conn.LocalAddr())
+	return &rr, nil
+}
+
+// LocalAddr	address of request server listen on
+func (r *Rewinder) LocalAddr() *net.UDPAddr {
+	return r.conn.LocalAddr().(*net.UDPAddr)
+}
+
+func (r *Rewinder) Close() error {
+	if !atomic.CompareAndSwapInt32(&r.bClosed, 0, 1) {
+		return errClosed
+	}
+	return r.conn.Close()
+}
+
+// reply	build reply packets of pktSize for request, nil for invalid or
+//			no message
+func (r *Rewinder) reply(req []byte, pktSize int) ([]Packet, error) {
+	var head Header
+	if err := DecodeHead(req, &head); err != nil {
+		return nil, errDecodeHead
+	}
+	if r.Session != "" && head.Session != r.Session {
+		return nil, errSession
+	}
+	if head.MessageCnt == 0 || head.MessageCnt == 0xffff {
+		return nil, errInvMessageCnt
+	}
+	msgs := r.store.Get(head.SeqNo, int(head.MessageCnt))
+	seqNo := head.SeqNo
+	nPkts := 0
+	for len(msgs) > 0 && nPkts < maxReplyPkts {
+		buff := r.buffs[nPkts][:pktSize]
+		n, pLen := packMessages(buff, head.Session, seqNo, msgs)
+		if n == 0 {
+			return nil, errMsgTooLarge
+		}
+		r.buffs[nPkts] = buff[:pLen]
+		msgs = msgs[n:]
+		seqNo += uint64(n)
+		nPkts++
+	}
+	return r.buffs[:nPkts], nil
+}
+
+// Serve	answer requests until Close
+func (r *Rewinder) Serve() error {
+	buff := make([]byte, 2048)
+	for {
+		n, rAddr, err := r.conn.ReadFromUDP(buff)
+		if err != nil {
+			if atomic.LoadInt32(&r.bClosed) != 0 {
+				return nil
+			}
+			log.Error("Rewinder ReadFromUDP", err)
+			return err
+		}
+		r.nRequest++
+		// reply sized for address family of requester as NewServer
+		pktSize := maxUDPSize
+		if isIPv6(rAddr.IP) {
+			pktSize = maxUDPSize6
+		}
+		pkts, err := r.reply(buff[:n], pktSize)
+		if err != nil {
+			r.nError++
+			log.Error("Request from", rAddr, " error:", err)
+			continue
+		}
+		for _, pkt := range pkts {
+			if _, err := r.conn.WriteToUDP(pkt, rAddr); err != nil {
+				r.nError++
+				log.Error("Rewinder WriteToUDP", err)
+				break
+			}
+			r.nRetrans++
+		}
+	}
+}
+
+func (r *Rewinder) DumpStats() {
+	log.Infof("Total Request: %d, reTrans packets: %d, error: %d",
+		r.nRequest, r.nRetrans, r.nError)
+}
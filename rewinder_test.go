@@ -0,0 +1,131 @@
+package MoldUDP
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMemStore(t *testing.T) {
+	st := NewMemStore(0)
+	msgs := []Message{{Data: []byte("a")}, {Data: []byte("bc")}, {Data: []byte{}}}
+	if err := st.Append(1, msgs); err != nil {
+		t.Error("Append()", err)
+	}
+	// overlapped append skip stored messages
+	if err := st.Append(3, []Message{msgs[2], {Data: []byte("d")}}); err != nil {
+		t.Error("Append() overlapped", err)
+	}
+	if err := st.Append(10, msgs); err != errStoreGap {
+		t.Errorf("Append() with gap error = %v, want %v", err, errStoreGap)
+	}
+	if n := st.NextSeq(); n != 5 {
+		t.Errorf("NextSeq() = %d, want 5", n)
+	}
+	msgs[0].Data[0] = 'x'
+	tests := []struct {
+		seqNo uint64
+		cnt   int
+		want  []string
+	}{
+		{1, 2, []string{"a", "bc"}},
+		{3, 10, []string{"", "d"}},
+		{5, 1, nil},
+		{0, 1, nil},
+	}
+	for _, tt := range tests {
+		res := st.Get(tt.seqNo, tt.cnt)
+		if len(res) != len(tt.want) {
+			t.Errorf("Get(%d, %d) got %d messages, want %d", tt.seqNo, tt.cnt,
+				len(res), len(tt.want))
+			continue
+		}
+		for i := range res {
+			if string(res[i].Data) != tt.want[i] {
+				t.Errorf("Get(%d, %d)[%d] = %s, want %s", tt.seqNo, tt.cnt, i,
+					res[i].Data, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestRewinder(t *testing.T) {
+	// 29 messages of IPv4 reply beyond IPv6 payload limit
+	msgs := make([]Message, 500)
+	for i := range msgs {
+		msgs[i].Data = make([]byte, 48)
+		msgs[i].Data[0] = byte(i)
+	}
+	srv, err := NewServer("239.192.168.1", 5858, "test0", &Option{}, &fakeConn{}, false)
+	if err != nil {
+		t.Fatal("NewServer", err)
+	}
+	store := NewMemStore(1)
+	srv.SetStore(store)
+	if _, err := srv.Send(msgs); err != nil {
+		t.Fatal("Server Send", err)
+	}
+	rr, err := NewRewinder("test0", 0, store)
+	if err != nil {
+		t.Fatal("NewRewinder", err)
+	}
+	defer rr.Close()
+	go rr.Serve()
+
+	tests := []struct {
+		name    string
+		ip      net.IP
+		pktSize int
+	}{
+		{"IPv4", net.IPv4(127, 0, 0, 1), maxUDPSize},
+		{"IPv6", net.IPv6loopback, maxUDPSize6},
+	}
+	for _, tt := range tests {
+		conn, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: tt.ip,
+			Port: rr.LocalAddr().Port})
+		if err != nil {
+			if tt.ip.To4() == nil {
+				t.Log("no IPv6 loopback", err)
+				continue
+			}
+			t.Fatal("DialUDP", err)
+		}
+		defer conn.Close()
+		req := make([]byte, headSize)
+		EncodeHead(req, &Header{Session: "test0", SeqNo: 100, MessageCnt: 300})
+		if _, err := conn.Write(req); err != nil {
+			t.Fatalf("%s: Write request %v", tt.name, err)
+		}
+		seqNo := uint64(100)
+		buff := make([]byte, 2048)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		for seqNo < 400 {
+			n, err := conn.Read(buff)
+			if err != nil {
+				t.Fatalf("%s: Read reply %v", tt.name, err)
+			}
+			if n > tt.pktSize {
+				t.Errorf("%s: reply of %d bytes, beyond %d", tt.name, n, tt.pktSize)
+			}
+			var head Header
+			DecodeHead(buff[:n], &head)
+			if head.SeqNo != seqNo {
+				t.Fatalf("%s: reply seqNo %d, want %d", tt.name, head.SeqNo, seqNo)
+			}
+			res, err := Unmarshal(buff[headSize:n], int(head.MessageCnt))
+			if err != nil {
+				t.Fatalf("%s: Unmarshal reply %v", tt.name, err)
+			}
+			for _, msg := range res {
+				if msg.Data[0] != byte(seqNo-1) {
+					t.Errorf("%s: reply message %d got %d", tt.name, seqNo,
+						msg.Data[0])
+				}
+				seqNo++
+			}
+		}
+		if seqNo != 400 {
+			t.Errorf("%s: reply up to %d, want 400", tt.name, seqNo)
+		}
+	}
+}
@@ -57,6 +57,36 @@ struct sock_filter filter[]={
 { 0x6, 0, 0, 0x00000000 },
 };
 
+// filter of dst udp port and source ip(SSM)
+struct sock_filter filterSrc[]={
+{ 0x28, 0, 0, 0x0000000c },
+{ 0x15, 0, 12, 0x000086dd },
+{ 0x30, 0, 0, 0x00000014 },
+{ 0x15, 0, 21, 0x00000011 },
+{ 0x20, 0, 0, 0x00000016 },
+{ 0x15, 0, 19, 0x00000000 },	// src ipv6 [0:4]
+{ 0x20, 0, 0, 0x0000001a },
+{ 0x15, 0, 17, 0x00000000 },	// src ipv6 [4:8]
+{ 0x20, 0, 0, 0x0000001e },
+{ 0x15, 0, 15, 0x00000000 },	// src ipv6 [8:12]
+{ 0x20, 0, 0, 0x00000022 },
+{ 0x15, 0, 13, 0x00000000 },	// src ipv6 [12:16]
+{ 0x28, 0, 0, 0x00000038 },
+{ 0x15, 10, 11, 0x000016e2 },	// dst udp port
+{ 0x15, 0, 10, 0x00000800 },
+{ 0x30, 0, 0, 0x00000017 },
+{ 0x15, 0, 8, 0x00000011 },
+{ 0x20, 0, 0, 0x0000001a },
+{ 0x15, 0, 6, 0x00000000 },	// src ip
+{ 0x28, 0, 0, 0x00000014 },
+{ 0x45, 4, 0, 0x00001fff },
+{ 0xb1, 0, 0, 0x0000000e },
+{ 0x48, 0, 0, 0x00000010 },
+{ 0x15, 0, 1, 0x000016e2 },	// dst udp port
+{ 0x6, 0, 0, 0x00040000 },
+{ 0x6, 0, 0, 0x00000000 },
+};
+
 inline int setBPF(int fd) {
 	struct sock_fprog	fProg;
 	fProg.len = sizeof(filter)/sizeof(filter[0]);
@@ -64,17 +94,19 @@ inline int setBPF(int fd) {
 	return setsockopt(fd, SOL_SOCKET, SO_ATTACH_FILTER, &fProg, sizeof(fProg));
 }
 
-inline int setPacketMultiCast(int fd, int ifIndex, unsigned char *ipAddr) {
+inline int setBPFSrc(int fd) {
+	struct sock_fprog	fProg;
+	fProg.len = sizeof(filterSrc)/sizeof(filterSrc[0]);
+	fProg.filter = filterSrc;
+	return setsockopt(fd, SOL_SOCKET, SO_ATTACH_FILTER, &fProg, sizeof(fProg));
+}
+
+inline int setPacketMultiCast(int fd, int ifIndex, unsigned char *hwAddr) {
 	struct packet_mreq mreq;
 	mreq.mr_ifindex =  ifIndex;
 	mreq.mr_type = PACKET_MR_MULTICAST; // PACKET_MR_ALLMULTI
 	mreq.mr_alen = 6;
-	mreq.mr_address[0] = 1;
-	mreq.mr_address[1] = 0;
-	mreq.mr_address[2] = 0x5e;
-	mreq.mr_address[3] = ipAddr[1] & 0x7f;
-	mreq.mr_address[4] = ipAddr[2];
-	mreq.mr_address[5] = ipAddr[3];
+	memcpy(mreq.mr_address, hwAddr, 6);
 	return setsockopt(fd, SOL_PACKET, PACKET_ADD_MEMBERSHIP, &mreq,
 				sizeof(mreq));
 }
@@ -97,9 +129,44 @@ static inline void newSockaddrIn(int port, const void *addr, struct sockaddr_in
 static inline void copyAddr(struct sockaddr_in *addr, void *dstAddr) {
 	memcpy(dstAddr, &addr->sin_addr, 4);
 }
+
+static inline void newSockaddrIn6(int port, const void *addr, unsigned int scope,
+			struct sockaddr_in6 *saddr)
+{
+	memset(saddr, 0, sizeof(*saddr));
+	saddr->sin6_family = AF_INET6;
+	saddr->sin6_port = htons(port);
+	saddr->sin6_scope_id = scope;
+	memcpy(& saddr->sin6_addr, addr, 16);
+}
+
+static inline void copyAddr6(struct sockaddr_in6 *addr, void *dstAddr) {
+	memcpy(dstAddr, &addr->sin6_addr, 16);
+}
 */
 import "C"
 
+// setBPFSrc	filter dst udp port and source ip src, IPv4 or IPv6
+func setBPFSrc(fd, port int, src net.IP) (err error) {
+	for _, i := range []int{5, 7, 9, 11, 18} {
+		C.filterSrc[i].k = 0
+	}
+	if src4 := src.To4(); src4 != nil {
+		C.filterSrc[18].k = C.__u32(coder.Uint32(src4))
+	} else if len(src) == net.IPv6len {
+		for i := 0; i < 4; i++ {
+			C.filterSrc[5+2*i].k = C.__u32(coder.Uint32(src[4*i:]))
+		}
+	}
+	C.filterSrc[13].k = C.__u32(port)
+	C.filterSrc[23].k = C.__u32(port)
+	ret := C.setBPFSrc(C.int(fd))
+	if ret != 0 {
+		err = syscall.Errno(C.errNo())
+	}
+	return
+}
+
 func setBPF(fd, port int) (err error) {
 	if int(C.filter[5].k) == port && int(C.filter[13].k) == port {
 		log.Info("already set dst port filter to", port)
@@ -114,24 +181,14 @@ func setBPF(fd, port int) (err error) {
 	return
 }
 
-func GetMulticastHWAddr(adr net.IP) HardwareAddr {
-	if ip4 := adr.To4(); ip4 == nil {
-		return nil
-	} else {
-		ret := make([]byte, 6)
-		ret[0] = 1
-		ret[1] = 0
-		ret[2] = 0x5e
-		ret[3] = ip4[1] & 0x7f
-		ret[4] = ip4[2]
-		ret[5] = ip4[3]
-		return HardwareAddr(ret)
-	}
-}
-
+// JoinPacketMulticast	join ethernet multicast of group maddr, IPv4/IPv6
 func JoinPacketMulticast(fd int, maddr []byte, ifn *net.Interface) (err error) {
+	hwAddr := GetMulticastHWAddr(net.IP(maddr))
+	if hwAddr == nil {
+		return errNotSupport
+	}
 	ret := C.setPacketMultiCast(C.int(fd), C.int(ifn.Index),
-		(*C.uchar)(unsafe.Pointer(&maddr[0])))
+		(*C.uchar)(unsafe.Pointer(&hwAddr[0])))
 	if ret != 0 {
 		err = syscall.Errno(C.errNo())
 	}
@@ -169,6 +226,19 @@ func Sendmmsg2(fd int, bufs []Packet, pktHdr []byte, ifIndex int) (cnt int, err
 func Sendmmsg(fd int, bufs []Packet, to *SockaddrInet4) (cnt int, err error) {
 	taddr := C.struct_sockaddr_in{}
 	C.newSockaddrIn(C.int(to.Port), unsafe.Pointer(&to.Addr[0]), &taddr)
+	return sendmmsg(fd, bufs, unsafe.Pointer(&taddr),
+		C.socklen_t(unsafe.Sizeof(taddr)))
+}
+
+func Sendmmsg6(fd int, bufs []Packet, to *SockaddrInet6) (cnt int, err error) {
+	taddr := C.struct_sockaddr_in6{}
+	C.newSockaddrIn6(C.int(to.Port), unsafe.Pointer(&to.Addr[0]),
+		C.uint(to.ZoneId), &taddr)
+	return sendmmsg(fd, bufs, unsafe.Pointer(&taddr),
+		C.socklen_t(unsafe.Sizeof(taddr)))
+}
+
+func sendmmsg(fd int, bufs []Packet, taddr unsafe.Pointer, tLen C.socklen_t) (cnt int, err error) {
 	bSize := len(bufs)
 	if bSize > C.MAX_BATCH {
 		bSize = C.MAX_BATCH
@@ -180,8 +250,8 @@ func Sendmmsg(fd int, bufs []Packet, to *SockaddrInet4) (cnt int, err error) {
 		C.dgrams[i].msg_len = C.uint(len(buf))
 		C.dgrams[i].msg_hdr.msg_iov = &(C.iovec[i][0])
 		C.dgrams[i].msg_hdr.msg_iovlen = 1
-		C.dgrams[i].msg_hdr.msg_name = unsafe.Pointer(&taddr)
-		C.dgrams[i].msg_hdr.msg_namelen = C.socklen_t(unsafe.Sizeof(taddr))
+		C.dgrams[i].msg_hdr.msg_name = taddr
+		C.dgrams[i].msg_hdr.msg_namelen = tLen
 	}
 	res := C.sendmmsg(C.int(fd), &(C.dgrams[0]), C.uint(bSize), 0)
 	if res < 0 {
@@ -194,13 +264,31 @@ func Sendmmsg(fd int, bufs []Packet, to *SockaddrInet4) (cnt int, err error) {
 
 func Recvmmsg(fd int, bufs []Packet, flags int) (cnt int, from *SockaddrInet4, err error) {
 	raddr := C.struct_sockaddr_in{}
-	raddrLen := C.socklen_t(unsafe.Sizeof(raddr))
+	cnt, err = recvmmsg(fd, bufs, unsafe.Pointer(&raddr),
+		C.socklen_t(unsafe.Sizeof(raddr)))
+	from = &SockaddrInet4{Port: int(C.ntohs(raddr.sin_port))}
+	C.copyAddr(&raddr, unsafe.Pointer(&from.Addr[0]))
+	return
+}
+
+func Recvmmsg6(fd int, bufs []Packet, flags int) (cnt int, from *SockaddrInet6, err error) {
+	raddr := C.struct_sockaddr_in6{}
+	cnt, err = recvmmsg(fd, bufs, unsafe.Pointer(&raddr),
+		C.socklen_t(unsafe.Sizeof(raddr)))
+	from = &SockaddrInet6{Port: int(C.ntohs(raddr.sin6_port)),
+		ZoneId: uint32(raddr.sin6_scope_id)}
+	C.copyAddr6(&raddr, unsafe.Pointer(&from.Addr[0]))
+	return
+}
+
+// recvmmsg	source address of first packet saved to raddr
+func recvmmsg(fd int, bufs []Packet, raddr unsafe.Pointer, rLen C.socklen_t) (cnt int, err error) {
 	bSize := len(bufs)
 	if bSize > C.MAX_BATCH {
 		bSize = C.MAX_BATCH
 	}
-	C.dgrams[0].msg_hdr.msg_name = unsafe.Pointer(&raddr)
-	C.dgrams[0].msg_hdr.msg_namelen = raddrLen
+	C.dgrams[0].msg_hdr.msg_name = raddr
+	C.dgrams[0].msg_hdr.msg_namelen = rLen
 	for i := 0; i < bSize; i++ {
 		buf := bufs[i]
 		C.iovec[i][0].iov_base = unsafe.Pointer(&buf[0])
@@ -230,7 +318,5 @@ func Recvmmsg(fd int, bufs []Packet, flags int) (cnt int, from *SockaddrInet4, e
 			bufs[i] = buf[:bLen]
 		}
 	}
-	from = &SockaddrInet4{Port: int(C.ntohs(raddr.sin_port))}
-	C.copyAddr(&raddr, unsafe.Pointer(&from.Addr[0]))
 	return
 }
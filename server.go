@@ -0,0 +1,267 @@
+package MoldUDP
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// maxUDPSize	Ethernet MTU 1500 minus IPv4(20) and UDP(8) header
+	maxUDPSize = 1472
+	// maxUDPSize6	Ethernet MTU 1500 minus IPv6(40) and UDP(8) header
+	maxUDPSize6 = 1452
+	// hbInterval	default heartbeat interval while idle
+	hbInterval = time.Second
+	// nEndSession	number of End-of-Session packets sent on Close
+	nEndSession = 3
+)
+
+var (
+	errSessionLen  = errors.New("Session longer than 10 bytes")
+	errMsgTooLarge = errors.New("Message too large for one packet")
+	errSendFull    = errors.New("Send buffer full")
+)
+
+// Server struct for MoldUDP64 publisher
+//	Session	session name of published packets, up to 10 bytes
+type Server struct {
+	dstIP    net.IP // Multicast dst IP
+	dstPort  int    // Multicast dst Port
+	conn     McastConn
+	Session  string
+	seqNo    uint64
+	pktSize  int
+	bMmsg    bool
+	store    MessageStore
+	lastSend time.Time
+	done     chan struct{}
+	lock     sync.Mutex
+	buffs    [maxBatch]Packet
+	nSent    int
+	nPackets int
+	nError   int
+}
+
+// NewServer	open MoldUDP64 publisher multicast to udpAddr:port
+//	opt.IfName	if not blank, interface for outgoing multicast
+//	opt.NextSeq	sequence number of first message published, 1 based
+//	bLoop		enable multicast loopback for local listener
+func NewServer(udpAddr string, port int, session string, opt *Option, conn McastConn, bLoop bool) (*Server, error) {
+	if len(session) > 10 {
+		return nil, errSessionLen
+	}
+	var err error
+	server := Server{conn: conn, Session: session, seqNo: opt.NextSeq}
+	if server.seqNo == 0 {
+		server.seqNo++
+	}
+	server.dstIP = net.ParseIP(udpAddr)
+	server.dstPort = port
+	if !server.dstIP.IsMulticast() {
+		log.Info(server.dstIP, "is not multicast IP")
+		server.dstIP = allNodes(server.dstIP)
+	}
+	var ifn *net.Interface
+	if opt.IfName != "" {
+		if ifn, err = net.InterfaceByName(opt.IfName); err != nil {
+			log.Errorf("Ifn(%s) error: %v\n", opt.IfName, err)
+			ifn = nil
+		}
+	}
+	if err := server.conn.OpenSend(server.dstIP, port, bLoop, ifn); err != nil {
+		log.Error("OpenSend Multicast", err)
+		return nil, err
+	}
+	server.pktSize = maxUDPSize
+	if isIPv6(server.dstIP) {
+		server.pktSize = maxUDPSize6
+	}
+	server.bMmsg = server.conn.Enabled(HasMmsg)
+	if server.bMmsg {
+		log.Info("Using Sendmmsg for multicast send")
+	}
+	for i := 0; i < maxBatch; i++ {
+		server.buffs[i] = make([]byte, server.pktSize)
+	}
+	return &server, nil
+}
+
+// Close	stop heartbeat, send End-of-Session packets and close connection
+func (s *Server) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conn == nil {
+		return errClosed
+	}
+	if s.done != nil {
+		close(s.done)
+		s.done = nil
+	}
+	for i := 0; i < nEndSession; i++ {
+		if err := s.sendHead(0xffff); err != nil {
+			log.Error("Send End-of-Session", err)
+			break
+		}
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// sendHead	send header only packet, heartbeat for msgCnt 0 or
+//			End-of-Session for msgCnt 0xffff, with next expected seqNo
+func (s *Server) sendHead(msgCnt uint16) error {
+	buff := s.buffs[0][:headSize]
+	head := Header{Session: s.Session, SeqNo: s.seqNo, MessageCnt: msgCnt}
+	EncodeHead(buff, &head)
+	if _, err := s.conn.Send(buff); err != nil {
+		s.nError++
+		return err
+	}
+	s.nPackets++
+	s.lastSend = time.Now()
+	return nil
+}
+
+// StartHeartbeat	send heartbeat if no packet sent for interval
+//	interval	0 for default one second
+func (s *Server) StartHeartbeat(interval time.Duration) {
+	if interval <= 0 {
+		interval = hbInterval
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.done != nil {
+		return
+	}
+	s.done = make(chan struct{})
+	s.lastSend = time.Now()
+	go s.heartbeatLoop(interval, s.done)
+}
+
+func (s *Server) heartbeatLoop(interval time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(interval / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case tt := <-ticker.C:
+			s.lock.Lock()
+			if s.conn != nil && tt.Sub(s.lastSend) >= interval {
+				if err := s.sendHead(0); err != nil {
+					log.Error("Send heartbeat", err)
+				}
+			}
+			s.lock.Unlock()
+		}
+	}
+}
+
+// SetStore	keep published messages in store, e.g. for Rewinder
+func (s *Server) SetStore(store MessageStore) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.store = store
+}
+
+// SeqNo	sequence number of next message to publish
+func (s *Server) SeqNo() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.seqNo
+}
+
+// packMessages	build one packet from head of msgs, first seqNo of message
+//	return	number of messages packed and packet length
+func packMessages(buff []byte, session string, seqNo uint64, msgs []Message) (int, int) {
+	msgCnt, bufLen := Marshal(buff[headSize:], msgs)
+	if msgCnt >= maxMessages {
+		msgCnt, bufLen = Marshal(buff[headSize:], msgs[:maxMessages-1])
+	}
+	if msgCnt == 0 {
+		return 0, 0
+	}
+	head := Header{Session: session, SeqNo: seqNo, MessageCnt: uint16(msgCnt)}
+	EncodeHead(buff, &head)
+	return msgCnt, bufLen + headSize
+}
+
+// flush	send packets via MSend if supported, otherwise one by one
+func (s *Server) flush(pkts []Packet) error {
+	if s.bMmsg {
+		for len(pkts) > 0 {
+			n, err := s.conn.MSend(pkts)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return errSendFull
+			}
+			s.nPackets += n
+			pkts = pkts[n:]
+		}
+		return nil
+	}
+	for _, pkt := range pkts {
+		if _, err := s.conn.Send(pkt); err != nil {
+			return err
+		}
+		s.nPackets++
+	}
+	return nil
+}
+
+// Send		publish messages in order, packed into MTU sized packets
+//	return	number of messages sequenced, messages are sequenced even if
+//			sending packet failed, they could be recovered via retransmission
+func (s *Server) Send(msgs []Message) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conn == nil {
+		return 0, errClosed
+	}
+	nSent := 0
+	for nSent < len(msgs) {
+		nPkts := 0
+		for nPkts < maxBatch && nSent < len(msgs) {
+			buff := s.buffs[nPkts][:s.pktSize]
+			n, pLen := packMessages(buff, s.Session, s.seqNo, msgs[nSent:])
+			if n == 0 {
+				break
+			}
+			if s.store != nil {
+				if err := s.store.Append(s.seqNo, msgs[nSent:nSent+n]); err != nil {
+					log.Error("Store Append", err)
+				}
+			}
+			s.buffs[nPkts] = buff[:pLen]
+			s.seqNo += uint64(n)
+			nSent += n
+			nPkts++
+		}
+		if nPkts > 0 {
+			if err := s.flush(s.buffs[:nPkts]); err != nil {
+				s.nError++
+				s.nSent += nSent
+				return nSent, err
+			}
+			s.lastSend = time.Now()
+		}
+		if nPkts < maxBatch && nSent < len(msgs) {
+			// message won't fit in one packet
+			s.nError++
+			s.nSent += nSent
+			return nSent, errMsgTooLarge
+		}
+	}
+	s.nSent += nSent
+	return nSent, nil
+}
+
+func (s *Server) DumpStats() {
+	log.Infof("Total Sent: %d messages, %d packets, seqNo: %d, error: %d",
+		s.nSent, s.nPackets, s.seqNo, s.nError)
+}
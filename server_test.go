@@ -0,0 +1,187 @@
+package MoldUDP
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn	McastConn records sent packets
+type fakeConn struct {
+	bMmsg bool
+	lock  sync.Mutex
+	pkts  []Packet
+}
+
+func (c *fakeConn) Enabled(opts int) bool {
+	if (opts & HasMmsg) != 0 {
+		return c.bMmsg
+	}
+	return false
+}
+func (c *fakeConn) Close() error                                       { return nil }
+func (c *fakeConn) Open(ip net.IP, port int, src net.IP, ifn *net.Interface) error { return nil }
+func (c *fakeConn) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface) error {
+	return nil
+}
+func (c *fakeConn) Send(buff []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pkts = append(c.pkts, append(Packet{}, buff...))
+	return len(buff), nil
+}
+
+// sent	copy of packets sent, safe while heartbeat goroutine sending
+func (c *fakeConn) sent() []Packet {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]Packet{}, c.pkts...)
+}
+
+func (c *fakeConn) Recv(buff []byte) (int, *net.UDPAddr, error) { return 0, nil, errNotSupport }
+func (c *fakeConn) MSend(buffs []Packet) (int, error) {
+	for _, buf := range buffs {
+		c.Send(buf)
+	}
+	return len(buffs), nil
+}
+func (c *fakeConn) MRecv() ([]Packet, *net.UDPAddr, error) { return nil, nil, errNotSupport }
+func (c *fakeConn) Listen(f func([]byte, *net.UDPAddr))    {}
+
+func TestServerSend(t *testing.T) {
+	msgs := make([]Message, 200)
+	for i := range msgs {
+		msgs[i].Data = make([]byte, 30)
+		msgs[i].Data[0] = byte(i)
+	}
+	for _, bMmsg := range []bool{false, true} {
+		conn := &fakeConn{bMmsg: bMmsg}
+		srv, err := NewServer("239.192.168.1", 5858, "test0", &Option{}, conn, false)
+		if err != nil {
+			t.Fatal("NewServer", err)
+		}
+		if n, err := srv.Send(msgs); err != nil || n != len(msgs) {
+			t.Errorf("Send() = %d, %v, want %d", n, err, len(msgs))
+		}
+		if seqNo := srv.SeqNo(); seqNo != uint64(len(msgs))+1 {
+			t.Errorf("SeqNo() = %d, want %d", seqNo, len(msgs)+1)
+		}
+		seqNo := uint64(1)
+		for _, pkt := range conn.pkts {
+			var head Header
+			if len(pkt) > maxUDPSize {
+				t.Errorf("packet size %d exceed %d", len(pkt), maxUDPSize)
+			}
+			if err := DecodeHead(pkt, &head); err != nil {
+				t.Fatal("DecodeHead", err)
+			}
+			if head.Session != "test0" || head.SeqNo != seqNo {
+				t.Errorf("head %+v, want seqNo %d", head, seqNo)
+			}
+			res, err := Unmarshal(pkt[headSize:], int(head.MessageCnt))
+			if err != nil {
+				t.Fatal("Unmarshal", err)
+			}
+			for _, msg := range res {
+				if msg.Data[0] != byte(seqNo-1) {
+					t.Errorf("message %d got %d", seqNo, msg.Data[0])
+				}
+				seqNo++
+			}
+		}
+		if seqNo != uint64(len(msgs))+1 {
+			t.Errorf("got %d messages, want %d", seqNo-1, len(msgs))
+		}
+	}
+}
+
+func TestServerMsgTooLarge(t *testing.T) {
+	conn := &fakeConn{}
+	srv, err := NewServer("239.192.168.1", 5858, "test0", &Option{}, conn, false)
+	if err != nil {
+		t.Fatal("NewServer", err)
+	}
+	msgs := []Message{{Data: []byte("ok")}, {Data: make([]byte, maxUDPSize)}}
+	if n, err := srv.Send(msgs); err != errMsgTooLarge || n != 1 {
+		t.Errorf("Send() = %d, %v, want 1, %v", n, err, errMsgTooLarge)
+	}
+	if _, err := NewServer("239.192.168.1", 5858, "session_too_long", &Option{},
+		&fakeConn{}, false); err != errSessionLen {
+		t.Errorf("NewServer() error = %v, want %v", err, errSessionLen)
+	}
+}
+
+func TestServerHeartbeat(t *testing.T) {
+	conn := &fakeConn{}
+	srv, err := NewServer("239.192.168.1", 5858, "test0", &Option{NextSeq: 10},
+		conn, false)
+	if err != nil {
+		t.Fatal("NewServer", err)
+	}
+	srv.Send([]Message{{Data: []byte("msg")}})
+	srv.StartHeartbeat(10 * time.Millisecond)
+	// wait for heartbeat while idle, no timing assumed
+	for deadline := time.Now().Add(5 * time.Second); len(conn.sent()) < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("no heartbeat sent while idle")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	srv.Close()
+	var nHB, nEOS int
+	for _, pkt := range conn.sent()[1:] {
+		var head Header
+		DecodeHead(pkt, &head)
+		if len(pkt) != headSize || head.SeqNo != 11 {
+			t.Errorf("packet len %d head %+v, want header only with seqNo 11",
+				len(pkt), head)
+		}
+		switch head.MessageCnt {
+		case 0:
+			if nEOS > 0 {
+				t.Error("heartbeat after End-of-Session")
+			}
+			nHB++
+		case 0xffff:
+			nEOS++
+		default:
+			t.Errorf("unexpected MessageCnt %d", head.MessageCnt)
+		}
+	}
+	if nHB == 0 {
+		t.Error("no heartbeat sent while idle")
+	}
+	if nEOS != nEndSession {
+		t.Errorf("got %d End-of-Session packets, want %d", nEOS, nEndSession)
+	}
+}
+
+func TestServerSendClose(t *testing.T) {
+	conn := &fakeConn{}
+	srv, err := NewServer("239.192.168.1", 5858, "test0", &Option{}, conn, false)
+	if err != nil {
+		t.Fatal("NewServer", err)
+	}
+	msgs := []Message{{Data: []byte("msg")}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, err := srv.Send(msgs); err == errClosed {
+				return
+			} else if err != nil {
+				t.Error("Send()", err)
+				return
+			}
+		}
+	}()
+	time.Sleep(time.Millisecond)
+	if err := srv.Close(); err != nil {
+		t.Error("Close()", err)
+	}
+	<-done
+	if err := srv.Close(); err != errClosed {
+		t.Errorf("Close() again error = %v, want %v", err, errClosed)
+	}
+}
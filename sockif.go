@@ -7,6 +7,8 @@ import (
 
 type sockIf struct {
 	dst   SockaddrInet4
+	dst6  SockaddrInet6
+	bIPv6 bool
 	fd    int
 	bRead bool
 	buffs [maxBatch]Packet
@@ -25,6 +27,13 @@ func (c *sockIf) String() string {
 	return "rawSocket Intf"
 }
 
+func (c *sockIf) connStats() (res ConnStats) {
+	if fd := c.fd; fd >= 0 {
+		res.RcvBuf = sockRcvBuf(fd)
+	}
+	return
+}
+
 func (c *sockIf) Close() error {
 	if c.fd < 0 {
 		return errClosed
@@ -34,20 +43,27 @@ func (c *sockIf) Close() error {
 	return err
 }
 
-func (c *sockIf) Open(ip net.IP, port int, ifn *net.Interface) error {
+func (c *sockIf) Open(ip net.IP, port int, src net.IP, ifn *net.Interface) error {
 	if c.fd >= 0 {
 		return errOpened
 	}
 	var err error
-	copy(c.dst.Addr[:], ip.To4())
-	c.dst.Port = port
-	c.fd, err = Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
-	if err != nil {
+	if src, err = sourceAddr(ip, src); err != nil {
+		return err
+	}
+	if err = c.socket(ip, port); err != nil {
 		return err
 	}
 	ReserveRecvBuf(c.fd)
 	SetsockoptInt(c.fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
-	err = Bind(c.fd, &SockaddrInet4{Port: port})
+	if err := noMulticastAll(c.fd, c.bIPv6); err != nil {
+		log.Info("unset multicast all", err)
+	}
+	if c.bIPv6 {
+		err = Bind6(c.fd, &SockaddrInet6{Port: port})
+	} else {
+		err = Bind(c.fd, &SockaddrInet4{Port: port})
+	}
 	if err != nil {
 		Close(c.fd)
 		log.Error("syscall.Bind", err)
@@ -55,8 +71,7 @@ func (c *sockIf) Open(ip net.IP, port int, ifn *net.Interface) error {
 	}
 	c.bRead = true
 	// set Multicast
-	err = JoinMulticast(c.fd, c.dst.Addr[:], ifn)
-	if err != nil {
+	if err = c.join(ip, src, ifn); err != nil {
 		log.Info("add multi group", err)
 	}
 	for i := 0; i < maxBatch; i++ {
@@ -65,33 +80,74 @@ func (c *sockIf) Open(ip net.IP, port int, ifn *net.Interface) error {
 	return nil
 }
 
+// Join	receive group ip on port of Open also, same address family
+func (c *sockIf) Join(ip net.IP, src net.IP, ifn *net.Interface) error {
+	if c.fd < 0 || !c.bRead {
+		return errModeRW
+	}
+	if isIPv6(ip) != c.bIPv6 {
+		return errNotSupport
+	}
+	src, err := sourceAddr(ip, src)
+	if err != nil {
+		return err
+	}
+	return c.join(ip, src, ifn)
+}
+
+func (c *sockIf) join(ip net.IP, src net.IP, ifn *net.Interface) error {
+	switch {
+	case src != nil && c.bIPv6:
+		return JoinSourceMulticast6(c.fd, ip.To16(), src, ifn)
+	case src != nil:
+		return JoinSourceMulticast(c.fd, ip.To4(), src, ifn)
+	case c.bIPv6:
+		return JoinMulticast6(c.fd, ip.To16(), ifn)
+	}
+	return JoinMulticast(c.fd, ip.To4(), ifn)
+}
+
 func (c *sockIf) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface) (err error) {
 	if c.fd >= 0 {
 		return errOpened
 	}
-	copy(c.dst.Addr[:], ip.To4())
-	c.dst.Port = port
-	c.fd, err = Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
-	if err != nil {
+	if err = c.socket(ip, port); err != nil {
 		return
 	}
 
-	laddr := SockaddrInet4{Port: port}
+	lPort := port
 	SetsockoptInt(c.fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
 	if bLoop {
-		laddr.Port = 0
+		lPort = 0
+	}
+	if c.bIPv6 {
+		err = Bind6(c.fd, &SockaddrInet6{Port: lPort})
+	} else {
+		err = Bind(c.fd, &SockaddrInet4{Port: lPort})
 	}
-	err = Bind(c.fd, &laddr)
 	if err != nil {
 		Close(c.fd)
 		log.Error("syscall.Bind", err)
 		return
 	}
 	c.bRead = false
-	log.Info("Server listen", LocalAddr(c.fd))
+	if !c.bIPv6 {
+		log.Info("Server listen", LocalAddr(c.fd))
+	}
 	//ReserveRecvBuf(c.fd)
 	ReserveSendBuf(c.fd)
 	log.Infof("Try Multicast %s:%d", ip, port)
+	if c.bIPv6 {
+		if err := SetMulticastInterface6(c.fd, ifn); err != nil {
+			log.Info("set multicast interface", err)
+		}
+		if bLoop {
+			if err = SetMulticastLoop6(c.fd, true); err != nil {
+				log.Info("set multicast loopback", err)
+			}
+		}
+		return
+	}
 	if err := SetMulticastInterface(c.fd, ifn); err != nil {
 		log.Info("set multicast interface", err)
 	}
@@ -103,10 +159,33 @@ func (c *sockIf) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface) (
 	return
 }
 
+// socket	open AF_INET or AF_INET6 socket as group ip
+func (c *sockIf) socket(ip net.IP, port int) (err error) {
+	c.bIPv6 = isIPv6(ip)
+	af := syscall.AF_INET
+	if c.bIPv6 {
+		af = syscall.AF_INET6
+		copy(c.dst6.Addr[:], ip)
+		c.dst6.Port = port
+	} else {
+		copy(c.dst.Addr[:], ip.To4())
+		c.dst.Port = port
+	}
+	c.fd, err = Socket(af, syscall.SOCK_DGRAM, 0)
+	return
+}
+
 func (c *sockIf) Recv(buff []byte) (int, *net.UDPAddr, error) {
 	if !c.bRead {
 		return 0, nil, errModeRW
 	}
+	if c.bIPv6 {
+		n, remoteAddr, err := Recvfrom6(c.fd, buff, 0)
+		if err != nil {
+			return 0, nil, err
+		}
+		return n, remoteAddr.UDPAddr(), nil
+	}
 	n, remoteAddr, err := Recvfrom(c.fd, buff, 0)
 	if err != nil {
 		return 0, nil, err
@@ -121,6 +200,9 @@ func (c *sockIf) Send(buff []byte) (int, error) {
 	if c.bRead {
 		return 0, errModeRW
 	}
+	if c.bIPv6 {
+		return Sendto6(c.fd, buff, 0, &c.dst6)
+	}
 	return Sendto(c.fd, buff, 0, &c.dst)
 }
 
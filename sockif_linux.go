@@ -1,6 +1,25 @@
 package MoldUDP
 
-import "net"
+import (
+	"net"
+	"syscall"
+)
+
+const (
+	// ipMulticastAll	IP_MULTICAST_ALL of linux/in.h
+	ipMulticastAll = 49
+	// ipv6MulticastAll	IPV6_MULTICAST_ALL of linux/in6.h
+	ipv6MulticastAll = 29
+)
+
+// noMulticastAll	fd bound to any address receive groups joined by fd
+//			only, not groups joined by other sockets on same port
+func noMulticastAll(fd int, bIPv6 bool) error {
+	if bIPv6 {
+		return SetsockoptInt(fd, syscall.IPPROTO_IPV6, ipv6MulticastAll, 0)
+	}
+	return SetsockoptInt(fd, syscall.IPPROTO_IP, ipMulticastAll, 0)
+}
 
 func (c *sockIf) Enabled(opts int) bool {
 	if (opts & HasMmsg) != 0 {
@@ -13,6 +32,9 @@ func (c *sockIf) MSend(buffs []Packet) (int, error) {
 	if c.bRead {
 		return 0, errModeRW
 	}
+	if c.bIPv6 {
+		return Sendmmsg6(c.fd, buffs, &c.dst6)
+	}
 	return Sendmmsg(c.fd, buffs, &c.dst)
 }
 
@@ -22,6 +44,13 @@ func (c *sockIf) MRecv() ([]Packet, *net.UDPAddr, error) {
 	}
 	bufs := make([]Packet, maxBatch)
 	copy(bufs, c.buffs[:])
+	if c.bIPv6 {
+		n, remoteAddr, err := Recvmmsg6(c.fd, bufs, 0)
+		if err != nil || n == 0 {
+			return nil, nil, err
+		}
+		return bufs[:n], remoteAddr.UDPAddr(), nil
+	}
 	n, remoteAddr, err := Recvmmsg(c.fd, bufs, 0)
 	if err != nil {
 		return nil, nil, err
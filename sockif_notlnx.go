@@ -4,6 +4,11 @@ package MoldUDP
 
 import "net"
 
+// noMulticastAll	socket receive groups joined by itself only elsewhere
+func noMulticastAll(fd int, bIPv6 bool) error {
+	return nil
+}
+
 func (c *sockIf) Enabled(opts int) bool {
 	return false
 }
@@ -0,0 +1,555 @@
+package MoldUDP
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SoupBinTCP 4.x packet types
+const (
+	soupDebug        = '+'
+	soupAccepted     = 'A'
+	soupRejected     = 'J'
+	soupSequenced    = 'S'
+	soupSrvHeartbeat = 'H'
+	soupEnd          = 'Z'
+	soupLogin        = 'L'
+	soupUnsequenced  = 'U'
+	soupCliHeartbeat = 'R'
+	soupLogout       = 'O'
+)
+
+const (
+	// soupHeartbeat	heartbeat sent if nothing sent for soupHeartbeat
+	soupHeartbeat = time.Second
+	// soupTimeout		connection lost if nothing received for soupTimeout
+	soupTimeout = 15 * time.Second
+	// soupPoll		interval polling store for new messages
+	soupPoll = 20 * time.Millisecond
+	// soupChunk		max messages read from store once
+	soupChunk = 1024
+	// soupLoginLen	username 6, password 10, session 10, seqNo 20
+	soupLoginLen    = 46
+	soupAcceptedLen = 30
+	soupMaxPayload  = 0xffff - 1
+)
+
+var (
+	errSoupPacket    = errors.New("Invalid SoupBinTCP packet")
+	errSoupNotAuth   = errors.New("SoupBinTCP login not authorized")
+	errSoupNoSession = errors.New("SoupBinTCP session not available")
+)
+
+// soupConn	SoupBinTCP framing over TCP connection, packet with 2 bytes
+//			big endian length of type and payload followed by 1 byte type
+type soupConn struct {
+	conn  net.Conn
+	r     *bufio.Reader
+	w     *bufio.Writer
+	wLock sync.Mutex
+	buff  []byte
+}
+
+func newSoupConn(conn net.Conn) *soupConn {
+	return &soupConn{conn: conn, r: bufio.NewReader(conn),
+		w: bufio.NewWriter(conn), buff: make([]byte, 0x10000)}
+}
+
+// write	buffered write of packet, flush to send
+func (sc *soupConn) write(typ byte, payload []byte) error {
+	if len(payload) > soupMaxPayload {
+		return errMsgTooLarge
+	}
+	sc.wLock.Lock()
+	defer sc.wLock.Unlock()
+	var hdr [3]byte
+	binary.BigEndian.PutUint16(hdr[:2], uint16(len(payload)+1))
+	hdr[2] = typ
+	sc.conn.SetWriteDeadline(time.Now().Add(soupTimeout))
+	if _, err := sc.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := sc.w.Write(payload)
+	return err
+}
+
+func (sc *soupConn) flush() error {
+	sc.wLock.Lock()
+	defer sc.wLock.Unlock()
+	sc.conn.SetWriteDeadline(time.Now().Add(soupTimeout))
+	return sc.w.Flush()
+}
+
+// send		write packet and flush
+func (sc *soupConn) send(typ byte, payload []byte) error {
+	if err := sc.write(typ, payload); err != nil {
+		return err
+	}
+	return sc.flush()
+}
+
+// read		next packet, payload valid till next read
+func (sc *soupConn) read() (byte, []byte, error) {
+	sc.conn.SetReadDeadline(time.Now().Add(soupTimeout))
+	var hdr [2]byte
+	if _, err := io.ReadFull(sc.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	pLen := int(binary.BigEndian.Uint16(hdr[:]))
+	if pLen == 0 {
+		return 0, nil, errSoupPacket
+	}
+	buff := sc.buff[:pLen]
+	if _, err := io.ReadFull(sc.r, buff); err != nil {
+		return 0, nil, err
+	}
+	return buff[0], buff[1:], nil
+}
+
+func (sc *soupConn) Close() error {
+	return sc.conn.Close()
+}
+
+// soupAlpha	left justified, padded on right with spaces
+func soupAlpha(b []byte, s string, n int) []byte {
+	if len(s) > n {
+		s = s[:n]
+	}
+	b = append(b, s...)
+	for i := len(s); i < n; i++ {
+		b = append(b, ' ')
+	}
+	return b
+}
+
+// soupNumeric	right justified, padded on left with spaces
+func soupNumeric(b []byte, v uint64, n int) []byte {
+	return append(b, fmt.Sprintf("%*d", n, v)...)
+}
+
+func soupParseNum(b []byte) (uint64, error) {
+	s := strings.TrimSpace(string(b))
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// SoupServer struct for SoupBinTCP 4.x server
+//	stream sequenced messages from store to clients logged in,
+//	from sequence number requested, heartbeat while idle
+//	Username	if not blank, login with Username/Password required
+type SoupServer struct {
+	Session  string
+	Username string
+	Password string
+	ln       net.Listener
+	store    MessageStore
+	bClosed  int32
+	bEnd     int32
+	lock     sync.Mutex
+	conns    map[*soupConn]struct{}
+	nLogin   int64
+	nReject  int64
+	nSent    int64
+	nError   int64
+}
+
+// NewSoupServer	listen SoupBinTCP on TCP port
+//	session		session served, blank for session requested by client
+//	store		messages to serve, e.g. the one of Server.SetStore
+func NewSoupServer(session string, port int, store MessageStore) (*SoupServer, error) {
+	if len(session) > 10 {
+		return nil, errSessionLen
+	}
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{Port: port})
+	if err != nil {
+		return nil, err
+	}
+	log.Info("SoupServer listen", ln.Addr())
+	return &SoupServer{Session: session, ln: ln, store: store,
+		conns: map[*soupConn]struct{}{}}, nil
+}
+
+// LocalAddr	address of SoupBinTCP server listen on
+func (s *SoupServer) LocalAddr() *net.TCPAddr {
+	return s.ln.Addr().(*net.TCPAddr)
+}
+
+// EndSession	End of Session sent to clients after all messages of store,
+//				call after last message appended
+func (s *SoupServer) EndSession() {
+	atomic.StoreInt32(&s.bEnd, 1)
+}
+
+// Close	stop listen and disconnect clients
+func (s *SoupServer) Close() error {
+	if !atomic.CompareAndSwapInt32(&s.bClosed, 0, 1) {
+		return errClosed
+	}
+	err := s.ln.Close()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for sc := range s.conns {
+		sc.Close()
+	}
+	return err
+}
+
+// Serve	accept clients until Close
+func (s *SoupServer) Serve() error {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&s.bClosed) != 0 {
+				return nil
+			}
+			log.Error("SoupServer Accept", err)
+			return err
+		}
+		sc := newSoupConn(conn)
+		s.lock.Lock()
+		s.conns[sc] = struct{}{}
+		s.lock.Unlock()
+		go s.serveConn(sc)
+	}
+}
+
+func (s *SoupServer) serveConn(sc *soupConn) {
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, sc)
+		s.lock.Unlock()
+		sc.Close()
+	}()
+	seqNo, err := s.login(sc)
+	if err != nil {
+		atomic.AddInt64(&s.nError, 1)
+		log.Error("SoupServer login from", sc.conn.RemoteAddr(), err)
+		return
+	}
+	atomic.AddInt64(&s.nLogin, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			// client heartbeat, unsequenced data ignored
+			typ, _, err := sc.read()
+			if err != nil || typ == soupLogout {
+				return
+			}
+		}
+	}()
+	if err := s.stream(sc, seqNo, done); err != nil {
+		atomic.AddInt64(&s.nError, 1)
+		log.Error("SoupServer stream to", sc.conn.RemoteAddr(), err)
+	}
+}
+
+// login	wait login request, reply accepted or rejected
+//	return	sequence number to stream from
+func (s *SoupServer) login(sc *soupConn) (uint64, error) {
+	typ, payload, err := sc.read()
+	if err != nil {
+		return 0, err
+	}
+	if typ != soupLogin || len(payload) != soupLoginLen {
+		return 0, errSoupPacket
+	}
+	user := strings.TrimSpace(string(payload[:6]))
+	pass := strings.TrimSpace(string(payload[6:16]))
+	session := strings.TrimSpace(string(payload[16:26]))
+	seqNo, err := soupParseNum(payload[26:46])
+	if err != nil {
+		return 0, errSoupPacket
+	}
+	if s.Username != "" && (user != s.Username || pass != s.Password) {
+		atomic.AddInt64(&s.nReject, 1)
+		sc.send(soupRejected, []byte{'A'})
+		return 0, errSoupNotAuth
+	}
+	if s.Session != "" {
+		if session != "" && session != s.Session {
+			atomic.AddInt64(&s.nReject, 1)
+			sc.send(soupRejected, []byte{'S'})
+			return 0, errSoupNoSession
+		}
+		session = s.Session
+	}
+	// 0 or beyond the end for next message
+	if next := s.store.NextSeq(); seqNo == 0 || seqNo > next {
+		seqNo = next
+	}
+	reply := make([]byte, 0, soupAcceptedLen)
+	reply = soupAlpha(reply, session, 10)
+	reply = soupNumeric(reply, seqNo, 20)
+	return seqNo, sc.send(soupAccepted, reply)
+}
+
+// stream	sequenced messages from seqNo till logout or End of Session
+func (s *SoupServer) stream(sc *soupConn, seqNo uint64, done <-chan struct{}) error {
+	tick := time.NewTicker(soupPoll)
+	defer tick.Stop()
+	lastSend := time.Now()
+	for {
+		if msgs := s.store.Get(seqNo, soupChunk); len(msgs) > 0 {
+			for i := range msgs {
+				if err := sc.write(soupSequenced, msgs[i].Data); err != nil {
+					return err
+				}
+			}
+			seqNo += uint64(len(msgs))
+			atomic.AddInt64(&s.nSent, int64(len(msgs)))
+			lastSend = time.Now()
+			select {
+			case <-done:
+				return nil
+			default:
+			}
+			continue
+		}
+		if err := sc.flush(); err != nil {
+			return err
+		}
+		if atomic.LoadInt32(&s.bEnd) != 0 {
+			// messages appended before EndSession streamed first
+			if s.store.NextSeq() > seqNo {
+				continue
+			}
+			return sc.send(soupEnd, nil)
+		}
+		select {
+		case <-done:
+			return nil
+		case tt := <-tick.C:
+			if tt.Sub(lastSend) >= soupHeartbeat {
+				if err := sc.send(soupSrvHeartbeat, nil); err != nil {
+					return err
+				}
+				lastSend = tt
+			}
+		}
+	}
+}
+
+func (s *SoupServer) DumpStats() {
+	log.Infof("SoupBinTCP login: %d, rejected: %d, sent: %d, error: %d",
+		atomic.LoadInt64(&s.nLogin), atomic.LoadInt64(&s.nReject),
+		atomic.LoadInt64(&s.nSent), atomic.LoadInt64(&s.nError))
+}
+
+// SoupClient struct for SoupBinTCP 4.x client
+//	read only MessageStore for Option.Recovery, Get login again
+//	from sequence number requested if not the next one
+type SoupClient struct {
+	Session  string
+	addr     string
+	username string
+	password string
+	lock     sync.Mutex
+	sc       *soupConn
+	done     chan struct{}
+	nextSeq  uint64
+	bEnd     bool
+	nLogin   int
+	nRecv    int
+}
+
+// NewSoupClient	SoupBinTCP client of server addr(host:port), connect
+//					and login on first Get
+//	session		session to login, blank for current session of server
+func NewSoupClient(addr, username, password, session string) *SoupClient {
+	return &SoupClient{Session: session, addr: addr, username: username,
+		password: password}
+}
+
+// DialSoup	connect to SoupBinTCP server and login from seqNo,
+//			0 for next message generated
+func DialSoup(addr, username, password, session string, seqNo uint64) (*SoupClient, error) {
+	c := NewSoupClient(addr, username, password, session)
+	if err := c.Login(seqNo); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Login	login again from seqNo, previous connection logout
+func (c *SoupClient) Login(seqNo uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.login(seqNo)
+}
+
+func (c *SoupClient) login(seqNo uint64) error {
+	c.logout()
+	conn, err := net.DialTimeout("tcp", c.addr, soupTimeout)
+	if err != nil {
+		return err
+	}
+	sc := newSoupConn(conn)
+	req := make([]byte, 0, soupLoginLen)
+	req = soupAlpha(req, c.username, 6)
+	req = soupAlpha(req, c.password, 10)
+	req = soupAlpha(req, c.Session, 10)
+	req = soupNumeric(req, seqNo, 20)
+	if err := sc.send(soupLogin, req); err != nil {
+		sc.Close()
+		return err
+	}
+	typ, payload, err := sc.read()
+	switch {
+	case err != nil:
+	case typ == soupRejected && len(payload) == 1 && payload[0] == 'A':
+		err = errSoupNotAuth
+	case typ == soupRejected:
+		err = errSoupNoSession
+	case typ != soupAccepted || len(payload) != soupAcceptedLen:
+		err = errSoupPacket
+	default:
+		c.nextSeq, err = soupParseNum(payload[10:30])
+	}
+	if err != nil {
+		sc.Close()
+		return err
+	}
+	c.Session = strings.TrimSpace(string(payload[:10]))
+	c.sc, c.bEnd = sc, false
+	c.done = make(chan struct{})
+	c.nLogin++
+	go c.heartbeat(sc, c.done)
+	return nil
+}
+
+func (c *SoupClient) heartbeat(sc *soupConn, done <-chan struct{}) {
+	tick := time.NewTicker(soupHeartbeat)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+			if sc.send(soupCliHeartbeat, nil) != nil {
+				return
+			}
+		}
+	}
+}
+
+// logout	send logout request and close connection if connected
+func (c *SoupClient) logout() error {
+	if c.sc == nil {
+		return nil
+	}
+	close(c.done)
+	c.sc.send(soupLogout, nil)
+	err := c.sc.Close()
+	c.sc = nil
+	return err
+}
+
+// Close	logout from server
+func (c *SoupClient) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.logout()
+}
+
+// recv		next sequenced message, nil Data with ok false for server
+//			heartbeat, ErrEndOfSession for End of Session
+func (c *SoupClient) recv() (msg Message, ok bool, err error) {
+	if c.sc == nil {
+		if c.bEnd {
+			return msg, false, ErrEndOfSession
+		}
+		return msg, false, errClosed
+	}
+	for {
+		typ, payload, err := c.sc.read()
+		if err != nil {
+			c.logout()
+			return msg, false, err
+		}
+		switch typ {
+		case soupSequenced:
+			msg.Data = append([]byte{}, payload...)
+			c.nextSeq++
+			c.nRecv++
+			return msg, true, nil
+		case soupSrvHeartbeat:
+			return msg, false, nil
+		case soupEnd:
+			c.logout()
+			c.bEnd = true
+			return msg, false, ErrEndOfSession
+		}
+		// unsequenced and debug packets ignored
+	}
+}
+
+// Next		block until next sequenced message
+//	uint64	sequence number of message
+//	return	ErrEndOfSession for End of Session
+func (c *SoupClient) Next() (Message, uint64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for {
+		seqNo := c.nextSeq
+		msg, ok, err := c.recv()
+		if err != nil || ok {
+			return msg, seqNo, err
+		}
+	}
+}
+
+func (c *SoupClient) Append(seqNo uint64, msgs []Message) error {
+	return errNotSupport
+}
+
+// NextSeq	sequence number of next message to receive
+func (c *SoupClient) NextSeq() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.nextSeq
+}
+
+// Get		up to cnt messages from seqNo, stop at server heartbeat
+//			for all messages of server received, nil if not available
+func (c *SoupClient) Get(seqNo uint64, cnt int) []Message {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.sc == nil || seqNo != c.nextSeq {
+		if c.bEnd && seqNo == c.nextSeq {
+			return nil
+		}
+		if err := c.login(seqNo); err != nil {
+			log.Error("SoupClient login", err)
+			return nil
+		}
+		if c.nextSeq != seqNo {
+			return nil
+		}
+	}
+	var res []Message
+	for len(res) < cnt {
+		msg, ok, err := c.recv()
+		if err != nil || !ok {
+			break
+		}
+		res = append(res, msg)
+	}
+	return res
+}
+
+func (c *SoupClient) DumpStats() {
+	log.Infof("SoupBinTCP login: %d, received: %d, next seqNo: %d",
+		c.nLogin, c.nRecv, c.nextSeq)
+}
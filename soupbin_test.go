@@ -0,0 +1,235 @@
+package MoldUDP
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newSoupTestServer(t *testing.T, nMsgs int) (*SoupServer, []Message) {
+	store := NewMemStore(1)
+	msgs := make([]Message, nMsgs)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	store.Append(1, msgs)
+	ss, err := NewSoupServer("soup0", 0, store)
+	if err != nil {
+		t.Fatal("NewSoupServer", err)
+	}
+	go ss.Serve()
+	return ss, msgs
+}
+
+func TestSoupLogin(t *testing.T) {
+	ss, _ := newSoupTestServer(t, 100)
+	defer ss.Close()
+	ss.Username, ss.Password = "user", "secret"
+	addr := ss.LocalAddr().String()
+	tests := []struct {
+		name    string
+		user    string
+		pass    string
+		session string
+		seqNo   uint64
+		next    uint64
+		err     error
+	}{
+		{"login", "user", "secret", "soup0", 10, 10, nil},
+		{"current session", "user", "secret", "", 1, 1, nil},
+		{"next generated", "user", "secret", "", 0, 101, nil},
+		{"beyond end", "user", "secret", "", 1000, 101, nil},
+		{"bad password", "user", "wrong", "soup0", 1, 0, errSoupNotAuth},
+		{"bad session", "user", "secret", "soup1", 1, 0, errSoupNoSession},
+	}
+	for _, tt := range tests {
+		c, err := DialSoup(addr, tt.user, tt.pass, tt.session, tt.seqNo)
+		if err != tt.err {
+			t.Errorf("%s: DialSoup() error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if c.Session != "soup0" || c.NextSeq() != tt.next {
+			t.Errorf("%s: session %s next %d, want soup0 %d", tt.name, c.Session,
+				c.NextSeq(), tt.next)
+		}
+		c.Close()
+	}
+}
+
+func TestSoupClientGet(t *testing.T) {
+	ss, msgs := newSoupTestServer(t, 3000)
+	defer ss.Close()
+	c := NewSoupClient(ss.LocalAddr().String(), "", "", "soup0")
+	defer c.Close()
+	tests := []struct {
+		seqNo uint64
+		cnt   int
+		want  int
+	}{
+		{1, 1000, 1000},
+		{1001, 500, 500},
+		// login again from other seqNo
+		{2500, 100, 100},
+		// stop at heartbeat for all received
+		{2600, 1000, 401},
+		{3001, 10, 0},
+	}
+	for _, tt := range tests {
+		res := c.Get(tt.seqNo, tt.cnt)
+		if len(res) != tt.want {
+			t.Errorf("Get(%d, %d) %d messages, want %d", tt.seqNo, tt.cnt, len(res),
+				tt.want)
+			continue
+		}
+		for i := range res {
+			if string(res[i].Data) != string(msgs[int(tt.seqNo)-1+i].Data) {
+				t.Errorf("Get(%d, %d) message %d: %s", tt.seqNo, tt.cnt,
+					tt.seqNo+uint64(i), res[i].Data)
+				break
+			}
+		}
+	}
+	if c.nLogin != 2 {
+		t.Errorf("%d logins, want 2", c.nLogin)
+	}
+	ss.EndSession()
+	seqNo := uint64(1)
+	cc, err := DialSoup(ss.LocalAddr().String(), "", "", "", seqNo)
+	if err != nil {
+		t.Fatal("DialSoup", err)
+	}
+	defer cc.Close()
+	for {
+		msg, sn, err := cc.Next()
+		if err != nil {
+			if err != ErrEndOfSession {
+				t.Error("Next", err)
+			}
+			break
+		}
+		if sn != seqNo || string(msg.Data) != string(msgs[seqNo-1].Data) {
+			t.Errorf("Next() message %d: %s, want %d", sn, msg.Data, seqNo)
+		}
+		seqNo++
+	}
+	if seqNo != uint64(len(msgs))+1 {
+		t.Errorf("Next() got %d messages before End of Session", seqNo-1)
+	}
+}
+
+// endStore	MessageStore calls last once Get found nothing, as publisher
+//			appends last messages and ends session meanwhile
+type endStore struct {
+	MessageStore
+	last sync.Once
+	fn   func()
+}
+
+func (s *endStore) Get(seqNo uint64, cnt int) []Message {
+	res := s.MessageStore.Get(seqNo, cnt)
+	if len(res) == 0 {
+		s.last.Do(s.fn)
+	}
+	return res
+}
+
+func TestSoupEndSession(t *testing.T) {
+	msgs := make([]Message, 110)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	store := &endStore{MessageStore: NewMemStore(1)}
+	store.Append(1, msgs[:100])
+	ss, err := NewSoupServer("soup0", 0, store)
+	if err != nil {
+		t.Fatal("NewSoupServer", err)
+	}
+	defer ss.Close()
+	store.fn = func() {
+		store.Append(101, msgs[100:])
+		ss.EndSession()
+	}
+	go ss.Serve()
+	cc, err := DialSoup(ss.LocalAddr().String(), "", "", "", 1)
+	if err != nil {
+		t.Fatal("DialSoup", err)
+	}
+	defer cc.Close()
+	seqNo := uint64(1)
+	for {
+		msg, sn, err := cc.Next()
+		if err != nil {
+			if err != ErrEndOfSession {
+				t.Error("Next", err)
+			}
+			break
+		}
+		if sn != seqNo || string(msg.Data) != string(msgs[seqNo-1].Data) {
+			t.Errorf("Next() message %d: %s, want %d", sn, msg.Data, seqNo)
+		}
+		seqNo++
+	}
+	if seqNo != uint64(len(msgs))+1 {
+		t.Errorf("Next() got %d messages before End of Session", seqNo-1)
+	}
+}
+
+func TestClientSoupRecovery(t *testing.T) {
+	const port = 6330
+	// gap too large for retransmission request
+	const gapFirst, gapLast = 1001, 1000 + nakWindow + 1000
+	msgs := make([]Message, gapLast+1000)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("%d", i+1))
+	}
+	srvStore := NewMemStore(1)
+	ss, err := NewSoupServer("bulk0", 0, srvStore)
+	if err != nil {
+		t.Fatal("NewSoupServer", err)
+	}
+	go ss.Serve()
+	defer ss.Close()
+	conn := &dropSend{McastConn: NewIf("mem"), drop: func(n int, pkt Packet) bool {
+		var head Header
+		DecodeHead(pkt, &head)
+		return head.SeqNo >= gapFirst && head.SeqNo <= gapLast
+	}}
+	srv, err := NewServer("239.192.168.31", port, "bulk0", &Option{}, conn, false)
+	if err != nil {
+		t.Fatal("NewServer", err)
+	}
+	srv.SetStore(srvStore)
+	rc := NewSoupClient(ss.LocalAddr().String(), "", "", "bulk0")
+	defer rc.Close()
+	opt := Option{Recovery: rc}
+	cc, err := NewClient("239.192.168.31", port, &opt, NewIf("mem"), true)
+	if err != nil {
+		t.Fatal("NewClient", err)
+	}
+	go func() {
+		for j := 0; j < len(msgs); j += 100 {
+			srv.Send(msgs[j : j+100])
+		}
+		srv.Close()
+	}()
+	tm := time.AfterFunc(10*time.Second, cc.Stop)
+	defer tm.Stop()
+	seqNo := uint64(1)
+	err = cc.Subscribe(func(sn uint64, msg Message) {
+		if sn != seqNo || string(msg.Data) != string(msgs[seqNo-1].Data) {
+			t.Errorf("message %d: %s, want %d", sn, msg.Data, seqNo)
+		}
+		seqNo++
+	})
+	if err != ErrEndOfSession || seqNo != uint64(len(msgs))+1 {
+		t.Errorf("Subscribe() %v, got %d messages", err, seqNo-1)
+	}
+	cc.Close()
+	if cc.nRecovered != gapLast-gapFirst+1 || cc.nRequest != 0 {
+		t.Errorf("%d messages recovered, %d requests", cc.nRecovered, cc.nRequest)
+	}
+}
@@ -0,0 +1,45 @@
+package MoldUDP
+
+import (
+	"net"
+	"os"
+)
+
+// mcastIfAddr	IPv4 address of interface joining group, LADDR env
+//				if ifn is nil, 0.0.0.0 for default
+func mcastIfAddr(ifn *net.Interface) []byte {
+	ifAddr := make([]byte, net.IPv4len)
+	if ifn != nil {
+		if adr, err := getIfAddr(ifn); err == nil && adr.To4() != nil {
+			copy(ifAddr, adr.To4())
+			log.Infof("Use %s for Multicast interface", adr)
+		}
+	} else {
+		// try os.Getenv
+		laddr := os.Getenv("LADDR")
+		log.Info("Try joinMC env LADDR:", laddr)
+		if adr := net.ParseIP(laddr); adr != nil && adr.To4() != nil {
+			copy(ifAddr, adr.To4())
+			log.Infof("Use %s for Multicast interface", adr)
+		}
+	}
+	return ifAddr
+}
+
+// sourceAddr	source address of SSM publisher in same family as group,
+//				nil for any-source multicast
+func sourceAddr(group, src net.IP) (net.IP, error) {
+	if src == nil || src.IsUnspecified() {
+		return nil, nil
+	}
+	if isIPv6(group) {
+		if !isIPv6(src) {
+			return nil, errSource
+		}
+		return src, nil
+	}
+	if src4 := src.To4(); src4 != nil {
+		return src4, nil
+	}
+	return nil, errSource
+}
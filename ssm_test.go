@@ -0,0 +1,151 @@
+package MoldUDP
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSourceAddr(t *testing.T) {
+	group4 := net.IPv4(232, 1, 1, 1)
+	group6 := net.ParseIP("ff3e::1234")
+	tests := []struct {
+		group, src net.IP
+		want       string
+		err        error
+	}{
+		{group4, nil, "<nil>", nil},
+		{group4, net.IPv4zero, "<nil>", nil},
+		{group4, net.IPv4(10, 0, 0, 1), "10.0.0.1", nil},
+		{group4, net.ParseIP("2001:db8::1"), "<nil>", errSource},
+		{group6, net.ParseIP("2001:db8::1"), "2001:db8::1", nil},
+		{group6, net.IPv4(10, 0, 0, 1), "<nil>", errSource},
+		{group6, net.IPv6unspecified, "<nil>", nil},
+	}
+	for _, tt := range tests {
+		src, err := sourceAddr(tt.group, tt.src)
+		if src.String() != tt.want || err != tt.err {
+			t.Errorf("sourceAddr(%v, %v) = %v, %v, want %s, %v", tt.group, tt.src,
+				src, err, tt.want, tt.err)
+		}
+		if src != nil && !isIPv6(tt.group) && len(src) != net.IPv4len {
+			t.Errorf("sourceAddr(%v, %v) length %d", tt.group, tt.src, len(src))
+		}
+	}
+}
+
+func TestMemIfSource(t *testing.T) {
+	group := net.IPv4(232, 192, 168, 10)
+	rcvs := []McastConn{NewIf("mem"), NewIf("mem")}
+	srcs := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv4(10, 0, 0, 1)}
+	for i, conn := range rcvs {
+		if err := conn.Open(group, 5858, srcs[i], nil); err != nil {
+			t.Fatal("Open", err)
+		}
+		defer conn.Close()
+	}
+	snd := NewIf("mem")
+	if err := snd.OpenSend(group, 5858, false, nil); err != nil {
+		t.Fatal("OpenSend", err)
+	}
+	defer snd.Close()
+	snd.Send([]byte("hello"))
+	if got := memRecvAll(t, rcvs[0], 1); got[0] != "hello" {
+		t.Errorf("Recv() = %v", got)
+	}
+	if c := rcvs[1].(*memIf); len(c.rx) != 0 {
+		t.Errorf("%d packets from other source", len(c.rx))
+	}
+}
+
+func TestPcapSource(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ssm.pcap")
+	pw, err := NewPcapWriter(fileName)
+	if err != nil {
+		t.Fatal("NewPcapWriter", err)
+	}
+	group := net.IPv4(232, 1, 1, 1)
+	dst := &net.UDPAddr{IP: group, Port: 5858}
+	srcs := []*net.UDPAddr{{IP: net.IPv4(10, 0, 0, 1), Port: 5859},
+		{IP: net.IPv4(10, 0, 0, 2), Port: 5859}}
+	for i := 0; i < 6; i++ {
+		pw.WritePacket([]byte(fmt.Sprint(i)), srcs[i%2], dst, time.Now())
+	}
+	pw.Close()
+	conn := NewPcapIf(fileName)
+	if err := conn.Open(group, 5858, srcs[1].IP, nil); err != nil {
+		t.Fatal("Open", err)
+	}
+	defer conn.Close()
+	var got []string
+	conn.Listen(func(buff []byte, rAddr *net.UDPAddr) {
+		got = append(got, string(buff))
+	})
+	if fmt.Sprint(got) != "[1 3 5]" {
+		t.Errorf("got %v, want [1 3 5]", got)
+	}
+}
+
+// TestSourceMulticast	SSM join on loopback, zsock needs CAP_NET_RAW
+func TestSourceMulticast(t *testing.T) {
+	ifn, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skip("no loopback interface", err)
+	}
+	group := net.IPv4(232, 1, 1, 1)
+	for i, name := range []string{"net", "sock", "zsock"} {
+		for j, src := range []string{"127.0.0.1", "127.0.0.2"} {
+			port := 5880 + 4*i + 2*j
+			rConn := NewIf(name)
+			if err := rConn.Open(group, port, net.ParseIP(src), ifn); err != nil {
+				t.Logf("%s: SSM not available: %v", name, err)
+				break
+			}
+			got := make(chan string, 1)
+			go func() {
+				if rConn.Enabled(HasRingBuffer) {
+					rConn.Listen(func(buff []byte, rAddr *net.UDPAddr) {
+						select {
+						case got <- string(buff):
+						default:
+						}
+					})
+					return
+				}
+				buff := make([]byte, 64)
+				for {
+					n, _, err := rConn.Recv(buff)
+					if err != nil {
+						return
+					}
+					if n > 0 {
+						got <- string(buff[:n])
+						return
+					}
+				}
+			}()
+			sConn := NewIf("net")
+			if err := sConn.OpenSend(group, port, true, ifn); err != nil {
+				t.Fatal("OpenSend", err)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if _, err := sConn.Send([]byte("hello")); err != nil {
+				t.Skip("multicast send", err)
+			}
+			select {
+			case s := <-got:
+				if j != 0 {
+					t.Errorf("%s: Recv %q from other source", name, s)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if j == 0 {
+					t.Logf("%s: no multicast loopback", name)
+				}
+			}
+			sConn.Close()
+			rConn.Close()
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package MoldUDP
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// kinds of packet error counted
+const (
+	errKindDecode = iota
+	errKindSession
+	errKindMessageCnt
+	errKindUnmarshal
+	nErrKinds
+)
+
+// latencyBounds	upper bounds of retransmission latency buckets
+var latencyBounds = [...]time.Duration{
+	100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second,
+}
+
+// clientCounters	counters of Client, updated atomically by receive
+//					goroutines and worker
+type clientCounters struct {
+	nRecvs     int64
+	nRetrans   int64
+	nMessages  int64
+	nRequest   int64
+	nMissed    int64
+	nRepeats   int64
+	nMerges    int64
+	nRecovered int64
+	nRollover  int64
+	nGaps      int64
+	nGapMsgs   int64
+	nErrs      [nErrKinds]int64
+	latency    latencyHist
+}
+
+func (cc *clientCounters) countErr(kind int) {
+	atomic.AddInt64(&cc.nErrs[kind], 1)
+}
+
+// nError	errors of all kinds
+func (cc *clientCounters) nError() (res int64) {
+	for i := range cc.nErrs {
+		res += atomic.LoadInt64(&cc.nErrs[i])
+	}
+	return
+}
+
+type latencyHist struct {
+	counts [len(latencyBounds) + 1]int64
+	sum    int64
+}
+
+func (h *latencyHist) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+func (h *latencyHist) snapshot() LatencyHist {
+	res := LatencyHist{Bounds: latencyBounds[:],
+		Counts: make([]int64, len(h.counts)),
+		Sum:    time.Duration(atomic.LoadInt64(&h.sum))}
+	for i := range h.counts {
+		res.Counts[i] = atomic.LoadInt64(&h.counts[i])
+		res.Count += res.Counts[i]
+	}
+	return res
+}
+
+// LatencyHist	histogram of latency
+//	Bounds	upper bounds of buckets
+//	Counts	observations of each bucket, not cumulative, last one for
+//			beyond all Bounds
+type LatencyHist struct {
+	Bounds []time.Duration
+	Counts []int64
+	Count  int64
+	Sum    time.Duration
+}
+
+// Mean		mean latency, 0 for no observation
+func (h *LatencyHist) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// ErrorStats	packets with error by kind
+//	Decode		header not decoded
+//	Session		packet of other session rejected
+//	MessageCnt	invalid MessageCnt of packet
+//	Unmarshal	messages of payload not decoded
+type ErrorStats struct {
+	Decode     int64
+	Session    int64
+	MessageCnt int64
+	Unmarshal  int64
+}
+
+// ClientStats	snapshot of Client statistics
+//	SeqNo		next sequence number to deliver
+//	Recvs		packets received by all lines and retransmission
+//	Retrans		packets received by retransmission
+//	Messages	messages delivered in order
+//	Missed		packets received with messages before missing
+//	Gaps		gaps of sequence detected, GapMessages messages of them
+//	Requests	retransmission requests sent
+//	Repeats		packets already received
+//	Merges		packets cached received again
+//	Recovered	messages from late join or bulk recovery
+//	Rollovers	session rollovers followed
+//	Errors		packets with error, ErrorKinds by kind
+//	Latency		retransmission latency, from first request to gap filled
+//	Cache		occupancy of out of order cache
+type ClientStats struct {
+	Session     string
+	SeqNo       uint64
+	Recvs       int64
+	Retrans     int64
+	Messages    int64
+	Missed      int64
+	Gaps        int64
+	GapMessages int64
+	Requests    int64
+	Repeats     int64
+	Merges      int64
+	Recovered   int64
+	Rollovers   int64
+	Errors      int64
+	ErrorKinds  ErrorStats
+	Latency     LatencyHist
+	Cache       CacheStats
+}
+
+// Stats	snapshot of statistics, safe from any goroutine
+func (c *Client) Stats() ClientStats {
+	seqNo, n := c.LastSeq()
+	st := ClientStats{Session: c.Session(), SeqNo: seqNo + uint64(n),
+		Recvs:       atomic.LoadInt64(&c.nRecvs),
+		Retrans:     atomic.LoadInt64(&c.nRetrans),
+		Messages:    atomic.LoadInt64(&c.nMessages),
+		Missed:      atomic.LoadInt64(&c.nMissed),
+		Gaps:        atomic.LoadInt64(&c.nGaps),
+		GapMessages: atomic.LoadInt64(&c.nGapMsgs),
+		Requests:    atomic.LoadInt64(&c.nRequest),
+		Repeats:     atomic.LoadInt64(&c.nRepeats),
+		Merges:      atomic.LoadInt64(&c.nMerges),
+		Recovered:   atomic.LoadInt64(&c.nRecovered),
+		Rollovers:   atomic.LoadInt64(&c.nRollover),
+		Errors:      c.nError(),
+		Latency:     c.latency.snapshot(),
+		Cache:       c.cache.Stats(),
+	}
+	st.ErrorKinds = ErrorStats{
+		Decode:     atomic.LoadInt64(&c.nErrs[errKindDecode]),
+		Session:    atomic.LoadInt64(&c.nErrs[errKindSession]),
+		MessageCnt: atomic.LoadInt64(&c.nErrs[errKindMessageCnt]),
+		Unmarshal:  atomic.LoadInt64(&c.nErrs[errKindUnmarshal]),
+	}
+	return st
+}
+
+// trackGap	count gap before seqNo beyond highest sequence received,
+//			seqNext after messages of packet, called by worker
+func (c *Client) trackGap(seqNo, seqNext uint64) {
+	top := c.seqTop
+	if top < c.seqNo {
+		top = c.seqNo
+	}
+	if seqNo > top {
+		atomic.AddInt64(&c.nGaps, 1)
+		atomic.AddInt64(&c.nGapMsgs, int64(seqNo-top))
+	}
+	if seqNext > c.seqTop {
+		c.seqTop = seqNext
+	}
+}
+
+// trackReq	retransmission request sent, latency measured from first
+//			request till its gap filled
+func (c *Client) trackReq(req []byte) {
+	if !c.reqAt.IsZero() {
+		return
+	}
+	var head Header
+	if decodeHead(req, &head, c.curSession) == nil {
+		c.reqAt, c.reqEnd = time.Now(), head.SeqNo+uint64(head.MessageCnt)
+	}
+}
+
+// trackFill	observe latency of request if gap filled
+func (c *Client) trackFill() {
+	if !c.reqAt.IsZero() && c.seqNo >= c.reqEnd {
+		c.latency.observe(time.Since(c.reqAt))
+		c.reqAt = time.Time{}
+	}
+}
@@ -0,0 +1,83 @@
+package MoldUDP
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLatencyHist(t *testing.T) {
+	var h latencyHist
+	tests := []struct {
+		d      time.Duration
+		bucket int
+	}{
+		{50 * time.Microsecond, 0},
+		{100 * time.Microsecond, 0},
+		{3 * time.Millisecond, 5},
+		{time.Second, 12},
+		{time.Minute, len(latencyBounds)},
+	}
+	var sum time.Duration
+	for _, tt := range tests {
+		h.observe(tt.d)
+		sum += tt.d
+	}
+	res := h.snapshot()
+	if res.Count != int64(len(tests)) || res.Sum != sum ||
+		res.Mean() != sum/time.Duration(len(tests)) {
+		t.Errorf("snapshot() count %d sum %v mean %v", res.Count, res.Sum, res.Mean())
+	}
+	want := make([]int64, len(latencyBounds)+1)
+	for _, tt := range tests {
+		want[tt.bucket]++
+	}
+	if fmt.Sprint(res.Counts) != fmt.Sprint(want) {
+		t.Errorf("snapshot() counts %v, want %v", res.Counts, want)
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	msgs := make([]Message, 40)
+	for i := range msgs {
+		msgs[i].Data = []byte(fmt.Sprintf("message %d", i+1))
+	}
+	pkts := make([]Packet, 4)
+	for i := range pkts {
+		pkts[i] = buildPacket("test0", uint64(10*i+1), 0, msgs[10*i:10*i+10])
+	}
+	c := newTestClient(1)
+	feedPackets(c, pkts, []int{0, 2, 3})
+	c.trackReq(buildPacket("test0", 11, 10, nil))
+	// heartbeat of next sequence number 51, gap 41-50
+	feedPackets(c, []Packet{buildPacket("test0", 51, 0, nil)}, []int{0})
+	feedPackets(c, pkts, []int{1, 0})
+	// errors of each kind
+	c.gotBuff([]byte("short"), 5, nil)
+	for _, pkt := range []Packet{buildPacket("test1", 1, 0, msgs[:1]),
+		buildPacket("test0", 61, maxMessages, nil)} {
+		var head Header
+		DecodeHead(pkt, &head)
+		c.parseBuff(pkt, len(pkt), &head, nil, false)
+	}
+	bad := buildPacket("test0", 41, 0, msgs[:2])
+	bad[len(bad)-1] = 0xff
+	bad[headSize+1] = 0xff
+	feedPackets(c, []Packet{bad}, []int{0})
+
+	st := c.Stats()
+	want := ClientStats{Session: "test0", SeqNo: 41, Recvs: 10,
+		Retrans: 9, Messages: 40, Missed: 3, Gaps: 2,
+		GapMessages: 20, Repeats: 1, Errors: 4,
+		ErrorKinds: ErrorStats{Decode: 1, Session: 1, MessageCnt: 1, Unmarshal: 1}}
+	st.Latency, st.Cache = LatencyHist{}, CacheStats{}
+	if fmt.Sprintf("%+v", st) != fmt.Sprintf("%+v", want) {
+		t.Errorf("Stats() = %+v\nwant %+v", st, want)
+	}
+	if lat := c.Stats().Latency; lat.Count != 1 {
+		t.Errorf("retransmission latency count %d, want 1", lat.Count)
+	}
+	if cs := c.Stats().Cache; cs.Cached != 0 || cs.MaxCached != 20 {
+		t.Errorf("cache stats %+v", cs)
+	}
+}
@@ -0,0 +1,82 @@
+package MoldUDP
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	errStoreGap = errors.New("Store append with sequence gap")
+)
+
+// MessageStore	store of sequenced messages, sequence number 1 based
+type MessageStore interface {
+	// Append	store msgs, first message with sequence number seqNo
+	Append(seqNo uint64, msgs []Message) error
+	// Get		up to cnt messages start from seqNo, nil if not stored
+	Get(seqNo uint64, cnt int) []Message
+	// NextSeq	sequence number of next message to append
+	NextSeq() uint64
+}
+
+type memStore struct {
+	lock sync.RWMutex
+	base uint64
+	msgs []Message
+}
+
+// NewMemStore	in memory MessageStore, first message with seqNo nextSeq
+func NewMemStore(nextSeq uint64) MessageStore {
+	if nextSeq == 0 {
+		nextSeq++
+	}
+	return &memStore{base: nextSeq}
+}
+
+func (ms *memStore) NextSeq() uint64 {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+	return ms.base + uint64(len(ms.msgs))
+}
+
+// Append	copy message data, messages already stored are skipped
+func (ms *memStore) Append(seqNo uint64, msgs []Message) error {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	next := ms.base + uint64(len(ms.msgs))
+	if seqNo > next {
+		return errStoreGap
+	}
+	if seqNo+uint64(len(msgs)) <= next {
+		return nil
+	}
+	msgs = msgs[int(next-seqNo):]
+	bLen := 0
+	for i := range msgs {
+		bLen += len(msgs[i].Data)
+	}
+	// one allocation for all message data
+	buff := make([]byte, bLen)
+	off := 0
+	for i := range msgs {
+		mLen := len(msgs[i].Data)
+		copy(buff[off:off+mLen], msgs[i].Data)
+		ms.msgs = append(ms.msgs, Message{Data: buff[off : off+mLen : off+mLen]})
+		off += mLen
+	}
+	return nil
+}
+
+func (ms *memStore) Get(seqNo uint64, cnt int) []Message {
+	ms.lock.RLock()
+	defer ms.lock.RUnlock()
+	next := ms.base + uint64(len(ms.msgs))
+	if seqNo < ms.base || seqNo >= next || cnt <= 0 {
+		return nil
+	}
+	off := int(seqNo - ms.base)
+	if off+cnt > len(ms.msgs) {
+		cnt = len(ms.msgs) - off
+	}
+	return ms.msgs[off : off+cnt : off+cnt]
+}
@@ -5,7 +5,6 @@ package MoldUDP
 import (
 	"fmt"
 	"net"
-	"os"
 	"runtime"
 	"strings"
 	"syscall"
@@ -40,6 +39,56 @@ static inline int iniSocket() {
 static inline void copyAddr(struct sockaddr_in *addr, void *dstAddr) {
 	memcpy(dstAddr, &addr->sin_addr, 4);
 }
+
+static inline void newSockaddrIn6(int port, const void *addr, unsigned int scope,
+			struct sockaddr_in6 *saddr)
+{
+	memset(saddr, 0, sizeof(*saddr));
+	saddr->sin6_family = AF_INET6;
+	saddr->sin6_port = htons(port);
+	saddr->sin6_scope_id = scope;
+	memcpy(& saddr->sin6_addr, addr, 16);
+}
+
+static inline void copyAddr6(struct sockaddr_in6 *addr, void *dstAddr) {
+	memcpy(dstAddr, &addr->sin6_addr, 16);
+}
+
+static inline int setGroup6(int fd, int opt, const void *addr, unsigned int ifIndex) {
+	struct ipv6_mreq mreq;
+	memcpy(&mreq.ipv6mr_multiaddr, addr, 16);
+	mreq.ipv6mr_interface = ifIndex;
+	return setsockopt((SOCKET)fd, IPPROTO_IPV6, opt, (const char *)&mreq,
+			sizeof(mreq));
+}
+static inline int joinSource(int fd, const void *group, const void *src,
+			const void *ifAddr)
+{
+	struct ip_mreq_source mreq;
+	memset(&mreq, 0, sizeof(mreq));
+	memcpy(&mreq.imr_multiaddr, group, 4);
+	memcpy(&mreq.imr_sourceaddr, src, 4);
+	memcpy(&mreq.imr_interface, ifAddr, 4);
+	return setsockopt((SOCKET)fd, IPPROTO_IP, IP_ADD_SOURCE_MEMBERSHIP, (const char *)&mreq,
+			sizeof(mreq));
+}
+
+static inline int joinSource6(int fd, const void *group, const void *src,
+			unsigned int ifIndex)
+{
+	struct group_source_req req;
+	struct sockaddr_in6 *sin6;
+	memset(&req, 0, sizeof(req));
+	req.gsr_interface = ifIndex;
+	sin6 = (struct sockaddr_in6 *)&req.gsr_group;
+	sin6->sin6_family = AF_INET6;
+	memcpy(&sin6->sin6_addr, group, 16);
+	sin6 = (struct sockaddr_in6 *)&req.gsr_source;
+	sin6->sin6_family = AF_INET6;
+	memcpy(&sin6->sin6_addr, src, 16);
+	return setsockopt((SOCKET)fd, IPPROTO_IPV6, MCAST_JOIN_SOURCE_GROUP, (const char *)&req,
+			sizeof(req));
+}
 */
 import "C"
 
@@ -281,20 +330,7 @@ func ReserveSendBuf(fd int) {
 func JoinMulticast(fd int, maddr []byte, ifn *net.Interface) (err error) {
 	var mreq = [8]byte{}
 	copy(mreq[:4], maddr)
-	if ifn != nil {
-		if adr, err := getIfAddr(ifn); err == nil {
-			copy(mreq[4:], adr.To4())
-			log.Infof("Use %s for Multicast interface", adr)
-		}
-	} else {
-		// try os.Getenv
-		laddr := os.Getenv("LADDR")
-		log.Info("Try joinMC env LADDR:", laddr)
-		if adr := net.ParseIP(laddr); adr != nil {
-			copy(mreq[4:], adr.To4())
-			log.Infof("Use %s for Multicast interface", adr)
-		}
-	}
+	copy(mreq[4:], mcastIfAddr(ifn))
 	optLen := C.int(unsafe.Sizeof(mreq))
 	res := C.setsockopt(C.SOCKET(fd), C.IPPROTO_IP, C.IP_ADD_MEMBERSHIP,
 		(*C.char)(unsafe.Pointer(&mreq)), optLen)
@@ -355,3 +391,113 @@ func SetBroadcast(fd int, bLoop bool) error {
 	}
 	return SetsockoptInt(fd, C.SOL_SOCKET, C.SO_BROADCAST, iVal)
 }
+
+func Bind6(fd int, laddr *SockaddrInet6) (err error) {
+	saddr := C.struct_sockaddr_in6{}
+	C.newSockaddrIn6(C.int(laddr.Port), unsafe.Pointer(&laddr.Addr[0]),
+		C.uint(laddr.ZoneId), &saddr)
+	ret := C.bind(C.SOCKET(fd), (*C.struct_sockaddr)(unsafe.Pointer(&saddr)),
+		C.socklen_t(unsafe.Sizeof(saddr)))
+	if ret < 0 {
+		err = syscall.Errno(C.errNo())
+	}
+	return
+}
+
+func Recvfrom6(fd int, p []byte, flags int) (n int, from *SockaddrInet6, err error) {
+	raddr := C.struct_sockaddr_in6{}
+	raddrLen := C.socklen_t(unsafe.Sizeof(raddr))
+	ret := C.recvfrom(C.SOCKET(fd), (*C.char)(unsafe.Pointer(&p[0])),
+		C.int(len(p)), C.int(flags),
+		(*C.struct_sockaddr)(unsafe.Pointer(&raddr)), &raddrLen)
+	if ret < 0 {
+		errN := C.errNo()
+		if errN != 0 && errN != C.EAGAIN && errN != C.EWOULDBLOCK {
+			err = syscall.Errno(C.errNo())
+		}
+	} else {
+		n = int(ret)
+	}
+	from = &SockaddrInet6{Port: int(C.ntohs(raddr.sin6_port)),
+		ZoneId: uint32(raddr.sin6_scope_id)}
+	C.copyAddr6(&raddr, unsafe.Pointer(&from.Addr[0]))
+	return
+}
+
+func Sendto6(fd int, p []byte, flags int, to *SockaddrInet6) (ret int, err error) {
+	taddr := C.struct_sockaddr_in6{}
+	C.newSockaddrIn6(C.int(to.Port), unsafe.Pointer(&to.Addr[0]),
+		C.uint(to.ZoneId), &taddr)
+	ret = int(C.sendto(C.SOCKET(fd), (*C.char)(unsafe.Pointer(&p[0])),
+		C.int(len(p)), C.int(flags), (*C.struct_sockaddr)(unsafe.Pointer(&taddr)),
+		C.int(unsafe.Sizeof(taddr))))
+	if ret < 0 {
+		errN := C.errNo()
+		if errN != 0 && errN != C.EAGAIN && errN != C.EWOULDBLOCK {
+			err = syscall.Errno(C.errNo())
+		}
+	}
+	return
+}
+
+// JoinMulticast6	join IPv6 group maddr via IPV6_JOIN_GROUP, default
+//					interface if ifn is nil
+func JoinMulticast6(fd int, maddr []byte, ifn *net.Interface) (err error) {
+	ifIndex := 0
+	if ifn != nil {
+		ifIndex = ifn.Index
+	}
+	res := C.setGroup6(C.int(fd), C.IPV6_JOIN_GROUP, unsafe.Pointer(&maddr[0]),
+		C.uint(ifIndex))
+	if res != 0 {
+		err = syscall.Errno(C.errNo())
+	}
+	return
+}
+
+func ExitMulticast6(fd int, maddr net.IP) {
+	C.setGroup6(C.int(fd), C.IPV6_LEAVE_GROUP, unsafe.Pointer(&maddr.To16()[0]), 0)
+}
+
+func SetMulticastInterface6(fd int, ifn *net.Interface) error {
+	if ifn == nil {
+		return nil
+	}
+	log.Info("Set out IPv6 Multicast interface to", ifn.Name)
+	return SetsockoptInt(fd, C.IPPROTO_IPV6, C.IPV6_MULTICAST_IF, ifn.Index)
+}
+
+func SetMulticastLoop6(fd int, bLoop bool) error {
+	var iVal = 0
+	if bLoop {
+		iVal = 1
+	}
+	return SetsockoptInt(fd, C.IPPROTO_IPV6, C.IPV6_MULTICAST_LOOP, iVal)
+}
+
+// JoinSourceMulticast	join source specific group maddr via
+//						IP_ADD_SOURCE_MEMBERSHIP, only packets from src
+func JoinSourceMulticast(fd int, maddr, src []byte, ifn *net.Interface) (err error) {
+	ifAddr := mcastIfAddr(ifn)
+	res := C.joinSource(C.int(fd), unsafe.Pointer(&maddr[0]), unsafe.Pointer(&src[0]),
+		unsafe.Pointer(&ifAddr[0]))
+	if res != 0 {
+		err = syscall.Errno(C.errNo())
+	}
+	return
+}
+
+// JoinSourceMulticast6	join IPv6 source specific group maddr via
+//						MCAST_JOIN_SOURCE_GROUP, only packets from src
+func JoinSourceMulticast6(fd int, maddr, src []byte, ifn *net.Interface) (err error) {
+	ifIndex := 0
+	if ifn != nil {
+		ifIndex = ifn.Index
+	}
+	res := C.joinSource6(C.int(fd), unsafe.Pointer(&maddr[0]),
+		unsafe.Pointer(&src[0]), C.uint(ifIndex))
+	if res != 0 {
+		err = syscall.Errno(C.errNo())
+	}
+	return
+}
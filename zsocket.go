@@ -184,8 +184,8 @@ type ZSocket struct {
 	numBlocks      int
 	blockSize      int
 	raw            []byte
-	nPackets       uint
-	nDrops         uint
+	nPackets       uint64
+	nDrops         uint64
 	listening      int32
 	frameNum       int32
 	frameSize      uint16
@@ -409,8 +409,8 @@ func (h *ZSocket) updateSocketStats() error {
 		if err != nil {
 			return err
 		}
-		h.nPackets += uint(ssv3.tp_packets)
-		h.nDrops += uint(ssv3.tp_drops)
+		atomic.AddUint64(&h.nPackets, uint64(ssv3.tp_packets))
+		atomic.AddUint64(&h.nDrops, uint64(ssv3.tp_drops))
 	} else {
 		var ss C.struct_tpacket_stats
 		socklen := unsafe.Sizeof(ss)
@@ -420,12 +420,24 @@ func (h *ZSocket) updateSocketStats() error {
 		if err != nil {
 			return err
 		}
-		h.nPackets += uint(ss.tp_packets)
-		h.nDrops += uint(ss.tp_drops)
+		atomic.AddUint64(&h.nPackets, uint64(ss.tp_packets))
+		atomic.AddUint64(&h.nDrops, uint64(ss.tp_drops))
 	}
 	return nil
 }
 
+// Drops	packets received and dropped by kernel for full ring-buffer,
+//			socket counters accumulated
+func (zs *ZSocket) Drops() (nPackets, nDrops uint64) {
+	zs.updateSocketStats()
+	return atomic.LoadUint64(&zs.nPackets), atomic.LoadUint64(&zs.nDrops)
+}
+
+// RingSize	bytes of RX/TX ring-buffer
+func (zs *ZSocket) RingSize() int {
+	return zs.blockSize * zs.numBlocks
+}
+
 // Listen to all specified packets in the RX ring-buffer
 func (zs *ZSocket) Listen(fx CallbackFunc) error {
 	if !zs.rxEnabled {
@@ -459,6 +471,10 @@ func (zs *ZSocket) listenV3(fx CallbackFunc) error {
 				// should be timeout
 				continue
 			}
+			if pfd[0].revents&C.POLLNVAL > 0 {
+				// socket closed
+				return errClosed
+			}
 			if pfd[0].revents&C.POLLERR > 0 {
 				// get error poll
 				continue
@@ -495,6 +511,10 @@ func (zs *ZSocket) listenV1(fx CallbackFunc) error {
 		if e1 != nil {
 			return e1
 		}
+		if pfd[0].revents&C.POLLNVAL > 0 {
+			// socket closed
+			return errClosed
+		}
 	}
 }
 
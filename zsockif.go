@@ -15,6 +15,9 @@ type zsockIf struct {
 	src   HardwareAddr
 	dstIP [4]byte
 	srcIP [4]byte
+	dst6  [16]byte
+	src6  [16]byte
+	bIPv6 bool
 	bRead bool
 	port  int
 	fake  bool
@@ -43,6 +46,15 @@ func (c *zsockIf) String() string {
 	return "ZSocket Intf"
 }
 
+// connStats	drops of PACKET_STATISTICS, ring-buffer as receive buffer
+func (c *zsockIf) connStats() (res ConnStats) {
+	if zs := c.zs; zs != nil {
+		_, res.Drops = zs.Drops()
+		res.RcvBuf = zs.RingSize()
+	}
+	return
+}
+
 func (c *zsockIf) Close() error {
 	if c.zs == nil {
 		return errClosed
@@ -52,12 +64,20 @@ func (c *zsockIf) Close() error {
 	return err
 }
 
-func (c *zsockIf) Open(ip net.IP, port int, ifn *net.Interface) (err error) {
+func (c *zsockIf) Open(ip net.IP, port int, src net.IP, ifn *net.Interface) (err error) {
 	if c.zs != nil {
 		return errOpened
 	}
+	if src, err = sourceAddr(ip, src); err != nil {
+		return
+	}
+	c.bIPv6 = isIPv6(ip)
+	ethType := ETH_IP
+	if c.bIPv6 {
+		ethType = ETH_IPV6
+	}
 	//c.zs, err = NewZSocket(ifn.Index, ENABLE_RX, 1024, 16384, ETH_IP)
-	c.zs, err = NewZSocket(ifn.Index, ENABLE_RX, 2048, 8192, ETH_IP)
+	c.zs, err = NewZSocket(ifn.Index, ENABLE_RX, 2048, 8192, ethType)
 	if err != nil {
 		return
 	}
@@ -68,10 +88,20 @@ func (c *zsockIf) Open(ip net.IP, port int, ifn *net.Interface) (err error) {
 	//log.Info("Using zsocket, max PacketSize:", c.zs.MaxPacketSize())
 	fd := c.zs.Fd()
 	//ReserveRecvBuf(fd)
-	if err := setBPF(fd, port); err != nil {
+	if src != nil {
+		if err := setBPFSrc(fd, port, src); err != nil {
+			log.Info("setBPFSrc", err)
+		}
+	} else if err := setBPF(fd, port); err != nil {
 		log.Info("setBPF", err)
 	}
-	if err := JoinPacketMulticast(fd, ip.To4(), ifn); err != nil {
+	if c.bIPv6 {
+		if err := JoinPacketMulticast(fd, ip, ifn); err != nil {
+			log.Info("add Packet multicast group", err)
+		} else {
+			copy(c.dst6[:], ip)
+		}
+	} else if err := JoinPacketMulticast(fd, ip.To4(), ifn); err != nil {
 		log.Info("add Packet multicast group", err)
 	} else {
 		copy(c.dstIP[:], ip.To4())
@@ -84,7 +114,12 @@ func (c *zsockIf) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface)
 	if c.zs != nil {
 		return errOpened
 	}
-	c.zs, err = NewZSocket(ifn.Index, ENABLE_TX|DISABLE_TX_LOSS, 2048, 4096, ETH_IP)
+	c.bIPv6 = isIPv6(ip)
+	ethType := ETH_IP
+	if c.bIPv6 {
+		ethType = ETH_IPV6
+	}
+	c.zs, err = NewZSocket(ifn.Index, ENABLE_TX|DISABLE_TX_LOSS, 2048, 4096, ethType)
 	if err != nil {
 		// if in testing, no return now
 		if !c.fake {
@@ -94,12 +129,20 @@ func (c *zsockIf) OpenSend(ip net.IP, port int, bLoop bool, ifn *net.Interface)
 	c.port = port
 	c.src = HardwareAddr(make([]byte, 6))
 	copy(c.src, ifn.HardwareAddr)
-	if adr, err := getIfAddr(ifn); err == nil {
-		copy(c.srcIP[:], adr.To4())
-		log.Infof("Use %s for Multicast interface", adr)
-	}
-	if dst := ip.To4(); dst != nil {
-		copy(c.dstIP[:], dst)
+	if c.bIPv6 {
+		if adr, err := getIfAddr6(ifn); err == nil {
+			copy(c.src6[:], adr)
+			log.Infof("Use %s for Multicast interface", adr)
+		}
+		copy(c.dst6[:], ip)
+	} else {
+		if adr, err := getIfAddr(ifn); err == nil {
+			copy(c.srcIP[:], adr.To4())
+			log.Infof("Use %s for Multicast interface", adr)
+		}
+		if dst := ip.To4(); dst != nil {
+			copy(c.dstIP[:], dst)
+		}
 	}
 	c.dst = GetMulticastHWAddr(ip)
 	log.Info("Using zsocket, via", c.src, "mcast on", c.dst)
@@ -129,6 +172,9 @@ func (c *zsockIf) copyFx(dst, src []byte, l int) uint16 {
 	}
 	copy(dst, c.dst)
 	copy(dst[6:], c.src)
+	if c.bIPv6 {
+		return uint16(buildRawUDP6(dst, src[:l], c.port, c.src6[:], c.dst6[:]))
+	}
 	//dst[12] = 8
 	//dst[13] = 0
 	buildRawUDP(dst, l, c.port, c.srcIP[:], c.dstIP[:])
@@ -191,9 +237,33 @@ func (c *zsockIf) Listen(fx func([]byte, *net.UDPAddr)) {
 	// or not you want the tx ring, rx ring, or both enabled, and what nettype you are listening
 	// for.
 	rAddr := net.UDPAddr{IP: net.IPv4zero}
+	if c.bIPv6 {
+		rAddr.IP = make(net.IP, net.IPv6len)
+	}
 	c.zs.Listen(func(fb []byte, frameLen, capturedLen uint16) {
 		ln := capturedLen
 		f := nettypes.Frame(fb[:ln])
+		if c.bIPv6 {
+			if f.MACEthertype(0) != nettypes.IPv6 {
+				tryLog("MAC EtherType dismatch")
+				return
+			}
+			mPay, _ := f.MACPayload(0)
+			udp := ip6UDP(mPay)
+			if udp == nil {
+				tryLog("IPv6 packet not UDP or too short")
+				return
+			}
+			if int(coder.Uint16(udp[2:])) != c.port {
+				tryLog("UDP port dismatch")
+				return
+			}
+			copy(rAddr.IP, mPay[8:24])
+			rAddr.Port = int(coder.Uint16(udp))
+			// we don't verify checksum
+			fx(udp[8:], &rAddr)
+			return
+		}
 		if f.MACEthertype(0) != nettypes.IPv4 {
 			tryLog("MAC EtherType dismatch")
 			return